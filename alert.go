@@ -0,0 +1,57 @@
+package queue
+
+// WithUtilizationAlert calls fn with the current utilization, ByteLen
+// divided by Capacity, whenever an enqueue or dequeue moves it across
+// threshold in either direction
+//
+// The alert is edge-triggered: it fires once when utilization rises to or
+// above threshold and once when it falls back below, not on every
+// operation in between. A queue that is already above threshold when it
+// is opened fires only once it falls below. fn runs in its own goroutine
+// so that it cannot hold up the queue, which means calls for crossings in
+// quick succession may arrive out of order.
+func WithUtilizationAlert(threshold float64, fn func(util float64)) Option {
+	return func(ls *Queue) {
+		ls.alert = &utilizationAlert{threshold: threshold, fn: fn}
+	}
+}
+
+// utilizationAlert holds the state behind WithUtilizationAlert
+type utilizationAlert struct {
+	threshold float64
+	fn        func(util float64)
+	above     bool // whether utilization was at or above threshold when last checked
+}
+
+// ByteLen returns the number of bytes occupied by the frames of the live
+// elements
+func (ls *Queue) ByteLen() uint32 {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	return ls.usedBytes()
+}
+
+// utilization returns the fraction of the capacity occupied by live
+// element frames
+func (ls *Queue) utilization() float64 {
+	if ls.header.fileLength == 0 {
+		return 0
+	}
+	return float64(ls.usedBytes()) / float64(ls.header.fileLength)
+}
+
+// checkUtilization fires the utilization alert, if any, when utilization
+// has crossed its threshold since it was last checked
+func (ls *Queue) checkUtilization() {
+	a := ls.alert
+	if a == nil {
+		return
+	}
+
+	util := ls.utilization()
+	if above := util >= a.threshold; above != a.above {
+		a.above = above
+		go a.fn(util)
+	}
+}
@@ -0,0 +1,56 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUtilizationAlert(t *testing.T) {
+	assert := assert.New(t)
+
+	alerts := make(chan float64, 10)
+	q := NewQueue(NewMemBuffer(), WithCapacity(1000), WithUtilizationAlert(0.55, func(util float64) {
+		alerts <- util
+	}))
+
+	next := func() float64 {
+		select {
+		case util := <-alerts:
+			return util
+		case <-time.After(time.Second):
+			t.Fatal("alert did not fire")
+			return 0
+		}
+	}
+	none := func() {
+		select {
+		case util := <-alerts:
+			t.Fatalf("unexpected alert at %v", util)
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+
+	// each frame takes 100 bytes, a tenth of the capacity
+	for i := 0; i < 5; i++ {
+		assert.Nil(q.Enqueue(nBytes(96)))
+	}
+	none()
+
+	assert.Nil(q.Enqueue(nBytes(96)))
+	assert.InDelta(0.6, next(), 1e-9)
+
+	assert.Nil(q.Enqueue(nBytes(96)))
+	none()
+
+	// falling back below the threshold fires once more
+	for i := 0; i < 3; i++ {
+		_, err := q.Dequeue()
+		assert.Nil(err)
+	}
+	assert.InDelta(0.5, next(), 1e-9)
+	none()
+
+	assert.Equal(uint32(400), q.ByteLen())
+}
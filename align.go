@@ -0,0 +1,34 @@
+package queue
+
+// WithAlignment pads each element frame with zeros up to a multiple of n
+// bytes, so that elements start on n-byte boundaries of the element region
+//
+// The element region starts right after the header, at offset 128 unless
+// a user header is reserved, so element offsets in the file are aligned
+// for any n dividing 128. Padding
+// is not recorded in the file, so a queue written with an alignment must
+// be reopened with the same alignment.
+func WithAlignment(n uint32) Option {
+	return func(ls *Queue) {
+		ls.alignment = n
+	}
+}
+
+// align rounds length up to a multiple of the configured alignment
+func (ls *Queue) align(length uint32) uint32 {
+	if ls.alignment <= 1 {
+		return length
+	}
+	if rem := length % ls.alignment; rem != 0 {
+		length += ls.alignment - rem
+	}
+	return length
+}
+
+// pad extends frame with zeros to its aligned length
+func (ls *Queue) pad(frame []byte) []byte {
+	if padded := ls.align(uint32(len(frame))); padded > uint32(len(frame)) {
+		frame = append(frame, make([]byte, padded-uint32(len(frame)))...)
+	}
+	return frame
+}
@@ -0,0 +1,51 @@
+package queue
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlignment(t *testing.T) {
+	assert := assert.New(t)
+
+	m := NewMemBuffer()
+	q := NewQueue(m, WithCapacity(headerLength+84), WithAlignment(8))
+
+	var values [][]byte
+	for _, n := range []int{1, 3, 4, 7, 13} {
+		v := nBytes(n)
+		values = append(values, v)
+
+		offset, err := q.EnqueueAt(v)
+		assert.Nil(err)
+		assert.Zero(offset%8, "element of %d bytes at offset %d", n, offset)
+		assert.Zero(q.header.tailPosition % 8)
+	}
+
+	elements, err := q.Elements()
+	assert.Nil(err)
+	assert.Equal(values, elements)
+	assert.Nil(q.HealthCheck())
+
+	// the 17 byte frame would fit in the 20 bytes left at the end of the
+	// buffer, but its padded length does not, so it wraps
+	for _, v := range values[:3] {
+		front, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Equal(v, front)
+	}
+
+	offset, err := q.EnqueueAt(nBytes(13))
+	assert.Nil(err)
+	assert.Equal(headerLength, offset)
+	assert.True(q.isWrapped())
+
+	// reopening with the same alignment finds the same elements
+	q = NewQueue(m, WithAlignment(8))
+	assert.Equal(3, q.Len())
+	for i := 0; i < 3; i++ {
+		_, err := q.Dequeue()
+		assert.Nil(err)
+	}
+}
@@ -0,0 +1,19 @@
+package queue
+
+// WithAppendOnly turns the queue into an append-only log: elements are
+// only ever written at the tail, and space freed by dequeues is never
+// reused, so offsets returned by EnqueueAt stay valid for the life of the
+// file
+//
+// Enqueue returns ErrQueueFull once the tail reaches the end of the
+// buffer, even if elements have been dequeued, and WithOverwriteOldest
+// has no effect. Elements are never relocated by compaction.
+//
+// The mode is not recorded in the file, so it must be requested every
+// time the queue is opened; opening the file without it resumes reusing
+// freed space.
+func WithAppendOnly() Option {
+	return func(ls *Queue) {
+		ls.appendOnly = true
+	}
+}
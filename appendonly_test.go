@@ -0,0 +1,57 @@
+package queue
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendOnly(t *testing.T) {
+	assert := assert.New(t)
+
+	f := NewMemBuffer()
+	q := NewQueue(f, WithCapacity(headerLength+240), WithAppendOnly())
+
+	// enqueue and dequeue until the log is full, leaving the queue empty
+	// at times so that a wrapping queue would reuse the front of the buffer
+	offsets := map[uint32][]byte{}
+	var err error
+	for i := 0; err == nil; i++ {
+		v := nBytes(i%7 + 1)
+
+		var offset uint32
+		offset, err = q.EnqueueAt(v)
+		if err != nil {
+			break
+		}
+		assert.NotContains(offsets, offset)
+		offsets[offset] = v
+
+		if i%2 == 1 {
+			for q.Len() > 0 {
+				_, err := q.Dequeue()
+				assert.Nil(err)
+			}
+		}
+	}
+	assert.Equal(ErrQueueFull, err)
+	assert.Greater(len(offsets), 20)
+
+	// every element is still where it was written
+	for offset, v := range offsets {
+		got, err := q.ReadElementAt(offset)
+		assert.Nil(err)
+		assert.Equal(v, got)
+	}
+
+	// the log stays full even when empty
+	for q.Enqueue(nil) == nil {
+	}
+	for q.Len() > 0 {
+		_, err := q.Dequeue()
+		assert.Nil(err)
+	}
+	assert.Equal(ErrQueueFull, q.Enqueue(nil))
+
+	assert.NotNil(q.Shrink(headerLength + 120))
+}
@@ -0,0 +1,194 @@
+package queue
+
+import "errors"
+
+var (
+	ErrBatchDone  = errors.New("batch has already been committed or aborted")
+	ErrStaleBatch = errors.New("elements were removed since the batch was peeked")
+)
+
+// Batch is a window of elements at the head of a queue returned by
+// Queue.PeekBatch, which stay in the queue until the batch is committed
+type Batch struct {
+	q         *Queue
+	headMoves uint64   // value of Queue.headMoves when the batch was peeked
+	frames    []uint32 // frame length of each element
+	bodies    [][]byte // stored bodies, used to clean up spilled elements
+	elements  [][]byte // decoded payloads
+	done      bool
+}
+
+// Elements returns the payloads of the peeked elements in FIFO order
+func (b *Batch) Elements() [][]byte {
+	return b.elements
+}
+
+// Commit removes the peeked elements from the queue with a single header
+// update
+//
+// Commit fails with ErrStaleBatch, removing nothing, if any element was
+// dequeued, evicted, or moved after the batch was peeked. Elements
+// enqueued in the meantime do not affect the batch.
+func (b *Batch) Commit() error {
+	ls := b.q
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if b.done {
+		return ErrBatchDone
+	}
+	if ls.headMoves != b.headMoves {
+		return ErrStaleBatch
+	}
+
+	return b.remove()
+}
+
+// remove removes the peeked elements from the queue and marks the batch
+// done once the header has been synced
+//
+// Queue.mu must be held by the caller
+func (b *Batch) remove() error {
+	ls := b.q
+	if len(b.frames) == 0 {
+		b.done = true
+		return nil
+	}
+
+	original, originalMoves := ls.header, ls.headMoves
+	var freed [][2]uint32
+	for _, frameLength := range b.frames {
+		freedStart, freedEnd := ls.advanceHead(frameLength)
+		freed = append(freed, [2]uint32{freedStart, freedEnd})
+	}
+
+	if err := ls.syncHeader(); err != nil {
+		ls.header, ls.headMoves = original, originalMoves
+		return err
+	}
+	b.done = true
+	ls.cond.Broadcast()
+
+	for _, body := range b.bodies {
+		ls.removeSpilled(body)
+	}
+
+	for _, v := range b.elements {
+		ls.recordDequeue(len(v))
+	}
+
+	if ls.zeroOnDequeue {
+		for _, region := range freed {
+			if err := ls.zero(region[0], region[1]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Abort releases the batch, leaving its elements in the queue
+func (b *Batch) Abort() error {
+	b.q.mu.Lock()
+	defer b.q.mu.Unlock()
+
+	if b.done {
+		return ErrBatchDone
+	}
+	b.done = true
+
+	return nil
+}
+
+// PeekBatch returns a Batch holding up to n elements from the head of the
+// queue without removing them, or ErrQueueEmpty if the queue is empty
+//
+// The elements are removed only when the batch is committed, so a consumer
+// can process the whole batch before acknowledging it.
+func (ls *Queue) PeekBatch(n int) (*Batch, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	return ls.peekBatch(n, nil)
+}
+
+// DequeueUntil removes and returns elements from the head of the queue in
+// FIFO order until maxCount elements have been taken or the next element
+// would bring the total payload length above maxBytes, syncing the header
+// once
+//
+// The element that would exceed maxBytes is left at the head of the queue,
+// so the result is empty if the head element alone is larger than
+// maxBytes. ErrQueueEmpty is returned if the queue is empty.
+func (ls *Queue) DequeueUntil(maxCount int, maxBytes uint32) ([][]byte, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	var total uint64
+	b, err := ls.peekBatch(maxCount, func(v []byte) bool {
+		if total+uint64(len(v)) > uint64(maxBytes) {
+			return false
+		}
+		total += uint64(len(v))
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := b.remove(); err != nil {
+		return nil, err
+	}
+
+	return b.elements, nil
+}
+
+// peekBatch returns a Batch holding up to n elements from the head of the
+// queue, stopping early at the first element that accept, if not nil,
+// returns false for
+func (ls *Queue) peekBatch(n int, accept func(v []byte) bool) (*Batch, error) {
+	if ls.consumer != nil {
+		return nil, errConsumerOffsetBatch
+	}
+	if err := ls.checkDequeue(); err != nil {
+		return nil, err
+	}
+
+	return ls.headBatch(n, accept)
+}
+
+// headBatch is like peekBatch, but does not check whether elements may be
+// dequeued
+func (ls *Queue) headBatch(n int, accept func(v []byte) bool) (*Batch, error) {
+	b := &Batch{q: ls, headMoves: ls.headMoves}
+	if n < 1 {
+		return b, nil
+	}
+
+	var decodeErr error
+	err := ls.walk(func(_, frameLength uint32, body []byte) bool {
+		v, err := ls.decodeElement(body)
+		if err != nil {
+			decodeErr = err
+			return false
+		}
+
+		if accept != nil && !accept(v) {
+			return false
+		}
+
+		b.frames = append(b.frames, frameLength)
+		b.bodies = append(b.bodies, body)
+		b.elements = append(b.elements, v)
+		return len(b.elements) < n
+	})
+	if err != nil {
+		return nil, err
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+
+	return b, nil
+}
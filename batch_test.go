@@ -0,0 +1,171 @@
+package queue
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeekBatch(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("commit", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithCapacity(headerLength+64))
+		for _, v := range []string{"a", "bb", "ccc", "dddd"} {
+			assert.Nil(q.Enqueue([]byte(v)))
+		}
+
+		b, err := q.PeekBatch(3)
+		assert.Nil(err)
+		assert.Equal([][]byte{[]byte("a"), []byte("bb"), []byte("ccc")}, b.Elements())
+		assert.Equal(4, q.Len())
+
+		// enqueues while the batch is open do not affect it
+		assert.Nil(q.Enqueue([]byte("e")))
+
+		seq := q.headerSeq
+		assert.Nil(b.Commit())
+		assert.Equal(seq+1, q.headerSeq)
+		assert.Equal(ErrBatchDone, b.Commit())
+		assert.Equal(ErrBatchDone, b.Abort())
+
+		elements, err := q.Elements()
+		assert.Nil(err)
+		assert.Equal([][]byte{[]byte("dddd"), []byte("e")}, elements)
+
+		q2 := NewQueue(q.rws)
+		elements, err = q2.Elements()
+		assert.Nil(err)
+		assert.Equal([][]byte{[]byte("dddd"), []byte("e")}, elements)
+	})
+
+	t.Run("commit wrapped", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithCapacity(headerLength+32))
+		assert.Nil(q.Enqueue(nBytes(12)))
+		assert.Nil(q.Enqueue(nBytes(4)))
+		_, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Nil(q.Enqueue(nBytes(2)))
+
+		b, err := q.PeekBatch(10)
+		assert.Nil(err)
+		assert.Len(b.Elements(), 2)
+		assert.Nil(b.Commit())
+		assert.Equal(0, q.Len())
+		assert.Equal(q.dataStart(), q.header.headPosition)
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+		assert.Nil(q.Enqueue([]byte("a")))
+		assert.Nil(q.Enqueue([]byte("b")))
+
+		b, err := q.PeekBatch(2)
+		assert.Nil(err)
+		assert.Nil(b.Abort())
+		assert.Equal(ErrBatchDone, b.Commit())
+
+		elements, err := q.Elements()
+		assert.Nil(err)
+		assert.Equal([][]byte{[]byte("a"), []byte("b")}, elements)
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+		assert.Nil(q.Enqueue([]byte("a")))
+
+		b, err := q.PeekBatch(1)
+		assert.Nil(err)
+
+		// empty the queue and refill it so the head returns to the same offset
+		_, err = q.Dequeue()
+		assert.Nil(err)
+		assert.Nil(q.Enqueue([]byte("b")))
+
+		assert.Equal(ErrStaleBatch, b.Commit())
+		assert.Equal(1, q.Len())
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+		_, err := q.PeekBatch(1)
+		assert.Equal(ErrQueueEmpty, err)
+	})
+
+	t.Run("sync failure", func(t *testing.T) {
+		f := newFlakyReadWriteSeeker(NewMemBuffer())
+		q := NewQueue(f)
+		assert.Nil(q.Enqueue([]byte("a")))
+
+		b, err := q.PeekBatch(1)
+		assert.Nil(err)
+
+		f.writeFailures = 1
+		err = b.Commit()
+		var ioErr *IOError
+		assert.True(errors.As(err, &ioErr))
+		assert.Equal(1, q.Len())
+
+		assert.Nil(b.Commit())
+		assert.Equal(0, q.Len())
+	})
+}
+
+func TestDequeueUntil(t *testing.T) {
+	assert := assert.New(t)
+
+	sizes := []int{3, 5, 2, 7, 1, 4}
+	fill := func() *Queue {
+		q := NewQueue(NewMemBuffer())
+		for _, size := range sizes {
+			assert.Nil(q.Enqueue(nBytes(size)))
+		}
+		return q
+	}
+
+	lengths := func(vs [][]byte) []int {
+		ls := []int{}
+		for _, v := range vs {
+			ls = append(ls, len(v))
+		}
+		return ls
+	}
+
+	for _, tc := range []struct {
+		maxCount int
+		maxBytes uint32
+		want     []int
+	}{
+		{maxCount: 10, maxBytes: 10, want: []int{3, 5, 2}}, // exactly the budget
+		{maxCount: 10, maxBytes: 9, want: []int{3, 5}},     // 2 more would exceed it
+		{maxCount: 10, maxBytes: 16, want: []int{3, 5, 2}}, // 7 does not fit after 10
+		{maxCount: 10, maxBytes: 17, want: []int{3, 5, 2, 7}},
+		{maxCount: 2, maxBytes: 100, want: []int{3, 5}},
+		{maxCount: 10, maxBytes: 100, want: sizes},
+		{maxCount: 10, maxBytes: 2, want: []int{}}, // head alone is too large
+		{maxCount: 0, maxBytes: 100, want: []int{}},
+	} {
+		q := fill()
+		seq := q.headerSeq
+
+		vs, err := q.DequeueUntil(tc.maxCount, tc.maxBytes)
+		assert.Nil(err)
+		assert.Equal(tc.want, lengths(vs), "count %d, bytes %d", tc.maxCount, tc.maxBytes)
+		assert.Equal(len(sizes)-len(tc.want), q.Len())
+
+		if len(tc.want) > 0 {
+			assert.Equal(seq+1, q.headerSeq)
+		} else {
+			assert.Equal(seq, q.headerSeq)
+		}
+
+		// the remaining elements are untouched
+		rest, err := q.Elements()
+		assert.Nil(err)
+		assert.Equal(sizes[len(tc.want):], lengths(rest))
+	}
+
+	_, err := NewQueue(NewMemBuffer()).DequeueUntil(1, 1)
+	assert.Equal(ErrQueueEmpty, err)
+}
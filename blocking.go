@@ -0,0 +1,138 @@
+package queue
+
+import (
+	"context"
+)
+
+// EnqueueContext adds a value to the queue like Enqueue, but when the queue
+// is full it waits until dequeues free enough space, ctx is done, or the
+// queue is closed
+func (ls *Queue) EnqueueContext(ctx context.Context, v []byte) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	for {
+		if ls.isClosed() {
+			return ErrClosed
+		}
+
+		_, err := ls.enqueue(v)
+		if err != ErrQueueFull {
+			return err
+		}
+
+		if err := ls.wait(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// DequeueContext removes and returns the item at the front of the queue
+// like Dequeue, but when the queue is empty or paused it waits until an
+// element is enqueued or dequeuing resumes, ctx is done, or the queue is
+// closed
+func (ls *Queue) DequeueContext(ctx context.Context) ([]byte, error) {
+	if err := ls.pace(ctx); err != nil {
+		return nil, err
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	for {
+		if ls.isClosed() {
+			return nil, ErrClosed
+		}
+
+		v, err := ls.dequeue()
+		if err != ErrQueueEmpty && err != ErrPaused {
+			return v, err
+		}
+
+		if err := ls.wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// WaitUntilBelow blocks until the queue holds fewer than n elements, ctx
+// is done, or the queue is closed, returning ctx.Err() or ErrClosed in the
+// latter cases
+func (ls *Queue) WaitUntilBelow(ctx context.Context, n int) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	for int(ls.unread()) >= n {
+		if ls.isClosed() {
+			return ErrClosed
+		}
+		if err := ls.wait(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// wait blocks on Queue.cond until the queue changes or ctx is done,
+// returning ctx.Err() in the latter case
+//
+// Queue.mu must be held by the caller
+func (ls *Queue) wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// wake the waiter when the context is done
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			ls.mu.Lock()
+			ls.cond.Broadcast()
+			ls.mu.Unlock()
+		case <-stop:
+		}
+	}()
+
+	ls.cond.Wait()
+	return ctx.Err()
+}
+
+// BlockingQueue wraps a Queue with channel-like semantics: Put waits while
+// the queue is full and Take waits while it is empty
+//
+// Waiting goroutines sleep until the queue changes rather than polling,
+// and give up with ErrClosed once the queue is closed.
+type BlockingQueue struct {
+	q *Queue
+}
+
+// NewBlockingQueue returns a BlockingQueue over q
+func NewBlockingQueue(q *Queue) *BlockingQueue {
+	return &BlockingQueue{q: q}
+}
+
+// Put adds v to the queue, waiting while the queue is full until dequeues
+// free enough space, ctx is done, or the queue is closed
+func (bq *BlockingQueue) Put(ctx context.Context, v []byte) error {
+	return bq.q.EnqueueContext(ctx, v)
+}
+
+// Take removes and returns the item at the front of the queue, waiting
+// while the queue is empty or paused until an element is enqueued or
+// dequeuing resumes, ctx is done, or the queue is closed
+func (bq *BlockingQueue) Take(ctx context.Context) ([]byte, error) {
+	return bq.q.DequeueContext(ctx)
+}
+
+// Len returns the number of elements in the queue
+func (bq *BlockingQueue) Len() int {
+	return bq.q.Len()
+}
+
+// Close closes the underlying queue, waking every waiting Put and Take
+func (bq *BlockingQueue) Close() error {
+	return bq.q.Close()
+}
@@ -0,0 +1,268 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnqueueContext(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("full queue unblocks a producer once a consumer dequeues", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f)
+
+		large := nBytes(1500)
+		assert.Nil(q.Enqueue(large))
+		assert.Nil(q.Enqueue(large))
+		assert.Equal(ErrQueueFull, q.Enqueue(large))
+
+		waiting := nBytes(1500)
+		done := make(chan error)
+		go func() {
+			done <- q.EnqueueContext(context.Background(), waiting)
+		}()
+
+		select {
+		case <-done:
+			t.Fatal("enqueue should block while the queue is full")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		_, err = q.Dequeue()
+		assert.Nil(err)
+
+		select {
+		case err := <-done:
+			assert.Nil(err)
+		case <-time.After(time.Second):
+			t.Fatal("enqueue should unblock after a dequeue")
+		}
+
+		_, err = q.Dequeue()
+		assert.Nil(err)
+		front, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Equal(waiting, front)
+	})
+
+	t.Run("cancelled context stops waiting", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f)
+
+		large := nBytes(1500)
+		assert.Nil(q.Enqueue(large))
+		assert.Nil(q.Enqueue(large))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		err = q.EnqueueContext(ctx, large)
+		assert.Equal(context.DeadlineExceeded, err)
+	})
+
+	t.Run("close wakes the waiter", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+
+		large := nBytes(1500)
+		assert.Nil(q.Enqueue(large))
+		assert.Nil(q.Enqueue(large))
+
+		done := make(chan error)
+		go func() {
+			done <- q.EnqueueContext(context.Background(), large)
+		}()
+
+		time.Sleep(10 * time.Millisecond)
+		assert.Nil(q.Close())
+		assert.Equal(ErrClosed, <-done)
+	})
+}
+
+func TestDequeueContext(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("empty queue unblocks a consumer once a producer enqueues", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f)
+
+		got := make(chan []byte)
+		go func() {
+			v, err := q.DequeueContext(context.Background())
+			assert.Nil(err)
+			got <- v
+		}()
+
+		time.Sleep(20 * time.Millisecond)
+		assert.Nil(q.Enqueue([]byte("a")))
+
+		select {
+		case v := <-got:
+			assert.Equal([]byte("a"), v)
+		case <-time.After(time.Second):
+			t.Fatal("dequeue should unblock after an enqueue")
+		}
+	})
+
+	t.Run("cancelled context stops waiting", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err = q.DequeueContext(ctx)
+		assert.Equal(context.Canceled, err)
+	})
+
+	t.Run("close wakes the waiter", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+
+		done := make(chan error)
+		go func() {
+			_, err := q.DequeueContext(context.Background())
+			done <- err
+		}()
+
+		time.Sleep(10 * time.Millisecond)
+		assert.Nil(q.Close())
+		assert.Equal(ErrClosed, <-done)
+	})
+}
+
+func TestWaitUntilBelow(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("waiter unblocks once consumers drain below the watermark", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+		for i := 0; i < 5; i++ {
+			assert.Nil(q.Enqueue([]byte("x")))
+		}
+
+		done := make(chan error)
+		go func() {
+			done <- q.WaitUntilBelow(context.Background(), 2)
+		}()
+
+		for q.Len() > 2 {
+			_, err := q.Dequeue()
+			assert.Nil(err)
+		}
+
+		select {
+		case <-done:
+			t.Fatal("waiter should block until fewer than 2 elements remain")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		_, err := q.Dequeue()
+		assert.Nil(err)
+
+		select {
+		case err := <-done:
+			assert.Nil(err)
+		case <-time.After(time.Second):
+			t.Fatal("waiter should unblock once the queue is below the watermark")
+		}
+	})
+
+	t.Run("already below the watermark", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+		assert.Nil(q.WaitUntilBelow(context.Background(), 1))
+	})
+
+	t.Run("cancelled context stops waiting", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+		assert.Nil(q.Enqueue([]byte("x")))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.Equal(context.DeadlineExceeded, q.WaitUntilBelow(ctx, 1))
+	})
+
+	t.Run("close wakes the waiter", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+		assert.Nil(q.Enqueue([]byte("x")))
+
+		done := make(chan error)
+		go func() {
+			done <- q.WaitUntilBelow(context.Background(), 1)
+		}()
+
+		time.Sleep(10 * time.Millisecond)
+		assert.Nil(q.Close())
+		assert.Equal(ErrClosed, <-done)
+	})
+}
+
+func TestBlockingQueue(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("producer and consumer proceed in lockstep", func(t *testing.T) {
+		// room for a single element
+		q := NewQueue(NewMemBuffer(), WithCapacity(headerLength+16))
+		bq := NewBlockingQueue(q)
+		ctx := context.Background()
+
+		const n = 100
+		done := make(chan error)
+		go func() {
+			for i := 0; i < n; i++ {
+				if err := bq.Put(ctx, []byte(fmt.Sprintf("%03d", i))); err != nil {
+					done <- err
+					return
+				}
+			}
+			done <- nil
+		}()
+
+		for i := 0; i < n; i++ {
+			v, err := bq.Take(ctx)
+			assert.Nil(err)
+			assert.Equal([]byte(fmt.Sprintf("%03d", i)), v)
+			assert.LessOrEqual(bq.Len(), 1)
+		}
+		assert.Nil(<-done)
+
+		// consumers wait on the condition variable rather than polling
+		polls := q.Stats().EmptyPolls
+		assert.LessOrEqual(polls, uint64(2*n))
+	})
+
+	t.Run("cancelled take", func(t *testing.T) {
+		bq := NewBlockingQueue(NewQueue(NewMemBuffer()))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		_, err := bq.Take(ctx)
+		assert.Equal(context.DeadlineExceeded, err)
+	})
+
+	t.Run("close wakes waiters", func(t *testing.T) {
+		bq := NewBlockingQueue(NewQueue(NewMemBuffer()))
+
+		done := make(chan error)
+		go func() {
+			_, err := bq.Take(context.Background())
+			done <- err
+		}()
+
+		time.Sleep(10 * time.Millisecond)
+		assert.Nil(bq.Close())
+		assert.Equal(ErrClosed, <-done)
+	})
+}
@@ -0,0 +1,18 @@
+package queue
+
+import (
+	"encoding/binary"
+)
+
+// WithByteOrder sets the byte order, binary.BigEndian or binary.LittleEndian,
+// of the header fields and default element length prefixes of a newly
+// created queue
+//
+// The byte order is recorded in the file header and honored when the
+// queue is reopened, so the option has no effect on existing queues.
+// Queues are big-endian by default.
+func WithByteOrder(order binary.ByteOrder) Option {
+	return func(ls *Queue) {
+		ls.byteOrder = order
+	}
+}
@@ -0,0 +1,60 @@
+package queue
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestByteOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	values := [][]byte{[]byte("a"), []byte("bc")}
+
+	for _, tc := range []struct {
+		name          string
+		writer, other binary.ByteOrder
+	}{
+		{"little-endian writer", binary.LittleEndian, binary.BigEndian},
+		{"big-endian writer", binary.BigEndian, binary.LittleEndian},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewMemBuffer()
+			q := NewQueue(m, WithByteOrder(tc.writer))
+			for _, v := range values {
+				assert.Nil(q.Enqueue(v))
+			}
+
+			// the element length prefix is written in the writer's order
+			assert.Equal(uint32(1), tc.writer.Uint32(m.Bytes()[headerLength:]))
+
+			// the reader's configured order does not override the file's
+			q = NewQueue(m, WithByteOrder(tc.other))
+			elements, err := q.Elements()
+			assert.Nil(err)
+			assert.Equal(values, elements)
+
+			assert.Nil(q.Enqueue([]byte("def")))
+			assert.Nil(q.HealthCheck())
+			for _, v := range append(values, []byte("def")) {
+				front, err := q.Dequeue()
+				assert.Nil(err)
+				assert.Equal(v, front)
+			}
+		})
+	}
+
+	t.Run("unsupported byte order", func(t *testing.T) {
+		_, err := New(NewMemBuffer(), WithByteOrder(nil))
+		assert.Nil(err)
+
+		_, err = New(NewMemBuffer(), WithByteOrder(swappedOrder{}))
+		assert.NotNil(err)
+	})
+}
+
+// swappedOrder is a binary.ByteOrder that the queue does not support
+type swappedOrder struct{ binary.ByteOrder }
+
+func (swappedOrder) String() string { return "swapped" }
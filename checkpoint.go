@@ -0,0 +1,58 @@
+package queue
+
+// WithHeaderCheckpointEvery writes the file header to the backing store
+// only on every nth header update, such as an enqueue or a dequeue,
+// instead of after every operation, keeping the authoritative header in
+// memory in between
+//
+// Flush and Close always write the header. A crash between checkpoints
+// loses up to n operations: the queue reopens in the state of the last
+// header written, so recently enqueued elements are missing and recently
+// dequeued elements are delivered again. The header is also written early
+// whenever an element would overwrite bytes the last written header still
+// treats as live, so the file stays consistent after a crash. Values of n
+// below 2 write the header after every operation.
+func WithHeaderCheckpointEvery(n int) Option {
+	return func(ls *Queue) {
+		ls.checkpointEvery = n
+	}
+}
+
+// checkpointing reports whether header writes may be deferred
+func (ls *Queue) checkpointing() bool {
+	return ls.checkpointEvery > 1
+}
+
+// checkpoint writes the in-memory header if updates to it have not yet
+// been written
+func (ls *Queue) checkpoint() error {
+	if ls.unsynced == 0 {
+		return nil
+	}
+	return ls.writeHeader()
+}
+
+// protect writes the in-memory header before the region [start, end) is
+// overwritten if the last header written still treats part of the region
+// as live
+func (ls *Queue) protect(start, end uint32) error {
+	if !ls.checkpointing() || !ls.durable.overlaps(start, end) {
+		return nil
+	}
+	return ls.checkpoint()
+}
+
+// overlaps reports whether [start, end) overlaps the live elements of h
+func (h fileHeader) overlaps(start, end uint32) bool {
+	if h.queueSize == 0 || end <= start {
+		return false
+	}
+
+	within := func(from, to uint32) bool {
+		return start < to && from < end
+	}
+	if h.wrapPosition != 0 {
+		return within(h.headPosition, h.wrapPosition) || within(h.dataStart(), h.tailPosition)
+	}
+	return within(h.headPosition, h.tailPosition)
+}
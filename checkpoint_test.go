@@ -0,0 +1,76 @@
+package queue
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderCheckpointEvery(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("close persists the final state", func(t *testing.T) {
+		f := NewMemBuffer()
+		q := NewQueue(f, WithHeaderCheckpointEvery(10))
+
+		for i := 0; i < 7; i++ {
+			assert.Nil(q.Enqueue([]byte(fmt.Sprintf("element %d", i))))
+		}
+		_, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Nil(q.Close())
+
+		q = NewQueue(f)
+		assert.Equal(6, q.Len())
+		front, err := q.Peek()
+		assert.Nil(err)
+		assert.Equal([]byte("element 1"), front)
+	})
+
+	t.Run("flush persists the final state", func(t *testing.T) {
+		f := NewMemBuffer()
+		q := NewQueue(f, WithHeaderCheckpointEvery(10))
+
+		assert.Nil(q.Enqueue([]byte("a")))
+		assert.Equal(0, NewQueue(f).Len())
+
+		assert.Nil(q.Flush())
+		assert.Equal(1, NewQueue(f).Len())
+	})
+
+	t.Run("crash loses at most n operations", func(t *testing.T) {
+		f := NewMemBuffer()
+		q := NewQueue(f, WithHeaderCheckpointEvery(4))
+
+		for i := 1; i <= 10; i++ {
+			assert.Nil(q.Enqueue([]byte{byte(i)}))
+
+			// reopening without closing sees the last checkpoint
+			crashed := NewQueue(f)
+			assert.Equal(i/4*4, crashed.Len())
+		}
+	})
+
+	t.Run("reused space stays consistent after a crash", func(t *testing.T) {
+		f := NewMemBuffer()
+		q := NewQueue(f, WithHeaderCheckpointEvery(100))
+
+		assert.Nil(q.Enqueue([]byte("first")))
+		assert.Nil(q.Enqueue([]byte("second")))
+		assert.Nil(q.Flush())
+
+		// the emptied queue starts over at the front of the buffer, where
+		// the last header written still expects its elements, so the
+		// dequeues are written first
+		for i := 0; i < 2; i++ {
+			_, err := q.Dequeue()
+			assert.Nil(err)
+		}
+		assert.Nil(q.Enqueue([]byte("third, which is longer")))
+
+		elements, err := NewQueue(f).Elements()
+		assert.Nil(err)
+		assert.Empty(elements)
+	})
+}
@@ -0,0 +1,108 @@
+package queue
+
+// WithCompactOnOpen compacts an existing queue file when it is opened,
+// moving its elements to the front of the buffer so that wrapping gaps
+// left by a previous run are reclaimed
+//
+// Compaction rewrites every element, trading a one-time cost at startup
+// for a contiguous layout, and is skipped if the elements already start
+// at the front of the buffer or the queue is read-only or append-only.
+func WithCompactOnOpen() Option {
+	return func(ls *Queue) {
+		ls.compactOnOpen = true
+	}
+}
+
+// WithCompactOnClose makes Close shrink the queue file to its live
+// contents, compacting the elements to the front of the buffer and
+// truncating the backing file, when it supports Truncate, after them
+//
+// The reduced capacity is recorded in the file header, so a reopened
+// queue works within it; it is full until elements are dequeued or Grow
+// adds space. An append-only queue is truncated after its tail without
+// moving its elements, and a queue whose elements wrap is not truncated.
+// An empty queue keeps its capacity, so it can still be enqueued to after
+// it is reopened.
+func WithCompactOnClose() Option {
+	return func(ls *Queue) {
+		ls.compactOnClose = true
+	}
+}
+
+// shrinkWrap compacts the live elements, unless the queue is append-only,
+// and shrinks the capacity to end after them, but not below the minimum
+// capacity New accepts
+func (ls *Queue) shrinkWrap() error {
+	if ls.header.queueSize == 0 {
+		return nil
+	}
+	if !ls.appendOnly && ls.fragmented() {
+		if err := ls.compact(); err != nil {
+			return err
+		}
+	}
+	if ls.isWrapped() {
+		return nil
+	}
+
+	end := ls.header.tailPosition
+	if min := ls.dataStart() + elementHeaderLength; end < min {
+		end = min
+	}
+	return ls.shrink(end)
+}
+
+// fragmented reports whether the live elements do not start at the front
+// of the buffer
+func (ls *Queue) fragmented() bool {
+	return ls.header.headPosition != ls.dataStart()
+}
+
+// compact rewrites the live elements contiguously at the front of the
+// buffer, removing the gaps left by wrapping
+//
+// Elements are rewritten in place, so a crash part way through
+// compaction can corrupt elements that were being moved.
+func (ls *Queue) compact() error {
+	var frames []byte
+	var readErr error
+	err := ls.walk(func(pos, frameLength uint32, _ []byte) bool {
+		frame := make([]byte, frameLength)
+		if err := ls.readAt(frame, int64(pos)); err != nil {
+			readErr = ioError(OpElementRead, int64(pos), err)
+			return false
+		}
+		frames = append(frames, frame...)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if readErr != nil {
+		return readErr
+	}
+
+	start := ls.dataStart()
+	if err := ls.protect(start, start+uint32(len(frames))); err != nil {
+		return err
+	}
+	if _, err := ls.writeAt(frames, int64(start)); err != nil {
+		return ioError(OpElementWrite, int64(start), err)
+	}
+
+	header := ls.header
+	header.headPosition = start
+	header.tailPosition = start + uint32(len(frames))
+	header.wrapPosition = 0
+
+	original := ls.header
+	ls.header = header
+	if err := ls.writeHeader(); err != nil {
+		ls.header = original
+		return err
+	}
+	ls.headMoves++
+	ls.relocations++
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package queue
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompactOnOpen(t *testing.T) {
+	assert := assert.New(t)
+
+	f := NewMemBuffer()
+	q := NewQueue(f, WithCapacity(headerLength+32))
+
+	// leave a gap at the front and wrap the tail around into it
+	for i := 1; i <= 4; i++ {
+		assert.Nil(q.Enqueue(nBytes(i)))
+	}
+	for i := 0; i < 3; i++ {
+		_, err := q.Dequeue()
+		assert.Nil(err)
+	}
+	assert.Nil(q.Enqueue(nBytes(8)))
+	assert.True(q.isWrapped())
+
+	want, err := q.Elements()
+	assert.Nil(err)
+
+	q = NewQueue(f, WithCompactOnOpen())
+	assert.False(q.isWrapped())
+	assert.Equal(q.dataStart(), q.header.headPosition)
+	assert.Equal(q.dataStart()+q.usedBytes(), q.header.tailPosition)
+
+	got, err := q.Elements()
+	assert.Nil(err)
+	assert.Equal(want, got)
+
+	// the compacted layout is persisted
+	got, err = NewQueue(f).Elements()
+	assert.Nil(err)
+	assert.Equal(want, got)
+
+	// already compact queues are left alone
+	seq := q.headerSeq
+	q = NewQueue(f, WithCompactOnOpen())
+	assert.Equal(seq, q.headerSeq)
+}
+
+func TestCompactOnClose(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := ioutil.TempFile("", "test-*")
+	assert.Nil(err)
+	defer os.Remove(f.Name())
+
+	q := NewQueue(f, WithCompactOnClose(), WithPreallocate())
+	for _, v := range []string{"a", "b", "c", "d"} {
+		assert.Nil(q.Enqueue([]byte(v)))
+	}
+	for i := 0; i < 2; i++ {
+		_, err := q.Dequeue()
+		assert.Nil(err)
+	}
+	live := q.ByteLen()
+	assert.Nil(q.Close())
+
+	info, err := os.Stat(f.Name())
+	assert.Nil(err)
+	assert.Equal(int64(headerLength+live), info.Size())
+
+	f, err = os.OpenFile(f.Name(), os.O_RDWR, 0)
+	assert.Nil(err)
+	defer f.Close()
+
+	// the reopened queue works within the reduced capacity
+	q = NewQueue(f)
+	assert.Equal(uint32(headerLength+live), q.Capacity())
+	assert.Equal(ErrQueueFull, q.Enqueue([]byte("e")))
+
+	elements, err := q.Elements()
+	assert.Nil(err)
+	assert.Equal([][]byte{[]byte("c"), []byte("d")}, elements)
+
+	_, err = q.Dequeue()
+	assert.Nil(err)
+	assert.Nil(q.Enqueue([]byte("e")))
+}
+
+func TestCompactOnCloseEmpty(t *testing.T) {
+	assert := assert.New(t)
+
+	f := NewMemBuffer()
+	q := NewQueue(f, WithCompactOnClose())
+	assert.Nil(q.Enqueue([]byte("a")))
+	_, err := q.Dequeue()
+	assert.Nil(err)
+	assert.Nil(q.Close())
+
+	// a drained queue keeps its capacity
+	q = NewQueue(f)
+	assert.Equal(uint32(defaultCapacity), q.Capacity())
+	assert.Nil(q.Enqueue([]byte("b")))
+
+	// a queue holding less than the minimum capacity is shrunk to it
+	q = NewQueue(f, WithCompactOnClose())
+	assert.Nil(q.Close())
+
+	q = NewQueue(f)
+	assert.Equal(headerLength+elementHeaderLength, q.Capacity())
+	v, err := q.Dequeue()
+	assert.Nil(err)
+	assert.Equal([]byte("b"), v)
+	assert.Nil(q.Enqueue([]byte("c")))
+}
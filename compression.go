@@ -0,0 +1,160 @@
+package queue
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// ErrFlagMismatch is returned when a queue file is opened with compression
+// or spillover enabled but was created without either, or the reverse
+var ErrFlagMismatch = errors.New("compression and spillover settings do not match the queue file")
+
+// element flags stored in the first byte of an element body
+// when compression or spillover is enabled
+const (
+	elementRaw        byte = 0
+	elementCompressed byte = 1
+	elementSpilled    byte = 2
+)
+
+// CompressionCodec compresses and decompresses element payloads
+type CompressionCodec interface {
+	Compress(src []byte) ([]byte, error)
+	Decompress(src []byte) ([]byte, error)
+}
+
+// WithCompression compresses element payloads with codec before they are
+// written and decompresses them when they are read
+//
+// Each element carries a 1-byte flag recording whether it was compressed,
+// so payloads that do not shrink under compression are stored as-is.
+// Whether elements carry the flag is recorded in the header, and opening
+// the file with a different setting fails with ErrFlagMismatch.
+func WithCompression(codec CompressionCodec) Option {
+	return func(ls *Queue) {
+		ls.codec = codec
+	}
+}
+
+// encodeElement transforms a payload into the body stored on disk
+func (ls *Queue) encodeElement(v []byte) ([]byte, error) {
+	if !ls.flagged() {
+		return v, nil
+	}
+
+	if ls.codec == nil {
+		return append([]byte{elementRaw}, v...), nil
+	}
+
+	compressed, err := ls.codec.Compress(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(compressed) < len(v) {
+		return append([]byte{elementCompressed}, compressed...), nil
+	}
+	return append([]byte{elementRaw}, v...), nil
+}
+
+// decodeElement transforms a body read from disk back into its payload
+func (ls *Queue) decodeElement(b []byte) ([]byte, error) {
+	v, _, err := ls.decodeTagged(b)
+	return v, err
+}
+
+// decodeTagged transforms a body read from disk back into its payload and,
+// in a tagged queue, its tags
+func (ls *Queue) decodeTagged(b []byte) ([]byte, map[string]string, error) {
+	v, err := ls.decodePayload(b)
+	if err != nil || !ls.tagged() {
+		return v, nil, err
+	}
+	return splitTags(v)
+}
+
+// decodePayload transforms a body read from disk back into the bytes
+// passed to encodeElement
+func (ls *Queue) decodePayload(b []byte) ([]byte, error) {
+	b, _, err := ls.unnumber(b)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ls.flagged() {
+		return b, nil
+	}
+
+	if len(b) == 0 {
+		return nil, fmt.Errorf("element is missing its flag")
+	}
+
+	switch b[0] {
+	case elementRaw:
+		return b[1:], nil
+	case elementCompressed:
+		if ls.codec == nil {
+			return nil, fmt.Errorf("element is compressed but no codec is configured")
+		}
+		return ls.codec.Decompress(b[1:])
+	case elementSpilled:
+		return ls.readSpilled(b[1:])
+	default:
+		return nil, fmt.Errorf("unknown element flag %d", b[0])
+	}
+}
+
+// flagged reports whether element bodies begin with a flag byte
+func (ls *Queue) flagged() bool {
+	return ls.header.flags&headerFlagged != 0
+}
+
+// checkFlagged returns ErrFlagMismatch unless compression or spillover is
+// enabled exactly when the header records that element bodies are flagged
+func (ls *Queue) checkFlagged() error {
+	if (ls.codec != nil || ls.spillDir != "") != ls.flagged() {
+		return ErrFlagMismatch
+	}
+	return nil
+}
+
+// flateCodec is a CompressionCodec backed by compress/flate
+type flateCodec struct {
+	level int
+}
+
+// NewFlateCodec returns a CompressionCodec using DEFLATE at the given
+// compression level, e.g. flate.DefaultCompression
+func NewFlateCodec(level int) (CompressionCodec, error) {
+	if level < flate.HuffmanOnly || level > flate.BestCompression {
+		return nil, fmt.Errorf("invalid flate compression level %d", level)
+	}
+	return flateCodec{level: level}, nil
+}
+
+func (c flateCodec) Compress(src []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, c.level)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(src); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (c flateCodec) Decompress(src []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(src))
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
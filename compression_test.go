@@ -0,0 +1,105 @@
+package queue
+
+import (
+	"bytes"
+	"compress/flate"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompression(t *testing.T) {
+	assert := assert.New(t)
+
+	codec, err := NewFlateCodec(flate.DefaultCompression)
+	assert.Nil(err)
+
+	t.Run("compressible payload round trips and shrinks", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f, WithCompression(codec))
+
+		payload := bytes.Repeat([]byte(`{"key":"value"},`), 64)
+		assert.Nil(q.Enqueue(payload))
+
+		stored := q.header.tailPosition - headerLength
+		assert.Less(stored, uint32(len(payload)))
+
+		front, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Equal(payload, front)
+	})
+
+	t.Run("incompressible payload round trips uncompressed", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f, WithCompression(codec))
+
+		payload := nBytes(256)
+		assert.Nil(q.Enqueue(payload))
+
+		// length prefix, flag byte, and the raw payload
+		stored := q.header.tailPosition - headerLength
+		assert.Equal(uint32(4+1+len(payload)), stored)
+
+		front, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Equal(payload, front)
+	})
+
+	t.Run("compressed elements survive reopen", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f, WithCompression(codec))
+
+		small := []byte("a")
+		large := bytes.Repeat([]byte("abc"), 500)
+		assert.Nil(q.Enqueue(small))
+		assert.Nil(q.Enqueue(large))
+
+		q = NewQueue(f, WithCompression(codec))
+
+		front, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Equal(small, front)
+
+		front, err = q.Dequeue()
+		assert.Nil(err)
+		assert.Equal(large, front)
+	})
+
+	t.Run("reopening with a different setting is rejected", func(t *testing.T) {
+		compressed := NewMemBuffer()
+		q := NewQueue(compressed, WithCompression(codec))
+		assert.Nil(q.Enqueue([]byte("hello")))
+
+		_, err := New(compressed)
+		assert.Equal(ErrFlagMismatch, err)
+
+		plain := NewMemBuffer()
+		q = NewQueue(plain)
+		assert.Nil(q.Enqueue([]byte("\x00xy")))
+
+		_, err = New(plain, WithCompression(codec))
+		assert.Equal(ErrFlagMismatch, err)
+		_, err = New(plain, WithSpillDir(t.TempDir()))
+		assert.Equal(ErrFlagMismatch, err)
+
+		// spillover uses the same flag byte, so uncompressed elements read back
+		// with either option
+		q, err = New(compressed, WithSpillDir(t.TempDir()))
+		assert.Nil(err)
+		front, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Equal([]byte("hello"), front)
+	})
+
+	t.Run("invalid flate level", func(t *testing.T) {
+		_, err := NewFlateCodec(42)
+		assert.NotNil(err)
+	})
+}
@@ -0,0 +1,105 @@
+package queue
+
+import (
+	"errors"
+)
+
+var errConsumerOffsetBatch = errors.New("batches are not supported with a consumer offset")
+
+// WithConsumerOffset makes a newly created queue keep its elements when
+// they are dequeued, recording instead how many elements the consumer has
+// read, so that a reopened queue resumes dequeuing where it left off while
+// the elements stay in the file for other tools to re-read
+//
+// The consumer offset is persisted in a slot after the cursor table with
+// every dequeue. Len, Peek, PeekAt, PeekTail, Elements, ForEach, Find,
+// and the Dequeue methods only see elements the consumer has not yet
+// read, but dequeuing never frees space, so the queue
+// fills up unless elements are evicted, for example with
+// WithOverwriteOldest. PeekBatch, DequeueUntil, and Subscribe are not
+// supported. Whether a queue keeps a consumer offset is recorded in the
+// file, so the option has no effect when reopening an existing queue, but
+// it must be passed to Repair to recover the queue.
+func WithConsumerOffset() Option {
+	return func(ls *Queue) {
+		ls.consumerOffset = true
+	}
+}
+
+// hasConsumerOffset reports whether the file keeps a consumer offset
+func (h fileHeader) hasConsumerOffset() bool {
+	return h.flags&headerConsumerOffset != 0
+}
+
+// loadConsumer reads the consumer offset from its slot, after the cursor
+// slots, if the queue keeps one
+func (ls *Queue) loadConsumer() error {
+	ls.consumer = nil
+	if !ls.header.hasConsumerOffset() {
+		return nil
+	}
+
+	slot := make([]byte, cursorSlotLength)
+	offset := int64(ls.cursorTableStart() + ls.header.cursorSlots*cursorSlotLength)
+	if err := ls.readAt(slot, offset); err != nil {
+		return ioError(OpHeaderRead, offset, err)
+	}
+
+	ls.consumer = &Cursor{q: ls, slot: ls.header.cursorSlots, read: ls.header.order().Uint64(slot[8:])}
+	return nil
+}
+
+// unread returns the number of elements that have not been dequeued,
+// which excludes elements the consumer has read when the queue keeps a
+// consumer offset
+func (ls *Queue) unread() uint32 {
+	if ls.consumer == nil {
+		return ls.header.queueSize
+	}
+	return ls.header.queueSize - ls.consumer.consumed()
+}
+
+// walkUnread is walk over the elements that have not been dequeued, which
+// starts at the consumer offset when the queue keeps one
+func (ls *Queue) walkUnread(fn func(pos, frameLength uint32, body []byte) bool) error {
+	if ls.consumer == nil || ls.unread() == 0 {
+		return ls.walkFrom(ls.header.headPosition, ls.unread(), fn)
+	}
+
+	pos, err := ls.consumer.position()
+	if err != nil {
+		return err
+	}
+	return ls.walkFrom(pos, ls.unread(), fn)
+}
+
+// consumeNumbered is dequeueNumbered for a queue with a consumer offset,
+// advancing the offset past the next unread element instead of removing it
+func (ls *Queue) consumeNumbered(pred func([]byte) bool) ([]byte, uint64, map[string]string, bool, error) {
+	body, pos, frameLength, err := ls.consumer.peek()
+	if err != nil {
+		return nil, 0, nil, false, err
+	}
+
+	_, seq, err := ls.unnumber(body)
+	if err != nil {
+		return nil, 0, nil, false, err
+	}
+	v, tags, err := ls.decodeTagged(body)
+	if err != nil {
+		return nil, 0, nil, false, err
+	}
+
+	if pred != nil && !pred(v) {
+		return nil, 0, nil, false, nil
+	}
+
+	if err := ls.consumer.advance(pos, frameLength); err != nil {
+		return nil, 0, nil, false, err
+	}
+	ls.cond.Broadcast()
+
+	ls.recordDequeue(len(v))
+
+	return v, seq, tags, true, nil
+}
@@ -0,0 +1,103 @@
+package queue
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsumerOffset(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("resume after reopening", func(t *testing.T) {
+		f := NewMemBuffer()
+		q := NewQueue(f, WithConsumerOffset(), WithCursors(1))
+
+		var want [][]byte
+		for i := 0; i < 10; i++ {
+			v := []byte(fmt.Sprintf("element %d", i))
+			want = append(want, v)
+			assert.Nil(q.Enqueue(v))
+		}
+
+		// consume half the elements
+		for _, v := range want[:5] {
+			got, err := q.Dequeue()
+			assert.Nil(err)
+			assert.Equal(v, got)
+		}
+		assert.Equal(5, q.Len())
+
+		q = NewQueue(f)
+		assert.Equal(5, q.Len())
+		front, err := q.Peek()
+		assert.Nil(err)
+		assert.Equal(want[5], front)
+
+		for _, v := range want[5:] {
+			got, err := q.Dequeue()
+			assert.Nil(err)
+			assert.Equal(v, got)
+		}
+		_, err = q.Dequeue()
+		assert.Equal(ErrQueueEmpty, err)
+		assert.Equal(0, q.Len())
+
+		// the consumed elements are still in the file for cursors to read,
+		// but no longer listed
+		elements, err := q.Elements()
+		assert.Nil(err)
+		assert.Empty(elements)
+
+		c, err := q.NewCursor()
+		assert.Nil(err)
+		for _, v := range want {
+			got, err := c.Next()
+			assert.Nil(err)
+			assert.Equal(v, got)
+		}
+	})
+
+	t.Run("dequeuing frees no space", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithConsumerOffset(), WithCapacity(headerLength+cursorSlotLength+32))
+
+		assert.Nil(q.Enqueue(nBytes(12)))
+		assert.Nil(q.Enqueue(nBytes(12)))
+		_, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Equal(ErrQueueFull, q.Enqueue(nBytes(12)))
+	})
+
+	t.Run("evicted elements are skipped", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithConsumerOffset(), WithOverwriteOldest(), WithCapacity(headerLength+cursorSlotLength+64))
+
+		for i := 0; i < 20; i++ {
+			assert.Nil(q.Enqueue([]byte(fmt.Sprintf("%02d", i))))
+			if i == 2 {
+				_, err := q.Dequeue()
+				assert.Nil(err)
+			}
+		}
+
+		// the oldest elements, read or not, were evicted to make room, so
+		// the consumer resumes at the head
+		elements, err := q.Elements()
+		assert.Nil(err)
+		assert.NotEqual([]byte("00"), elements[0])
+		assert.Equal(len(elements), q.Len())
+
+		got, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Equal(elements[0], got)
+		assert.Equal(len(elements)-1, q.Len())
+	})
+
+	t.Run("batches are not supported", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithConsumerOffset())
+		assert.Nil(q.Enqueue([]byte("a")))
+
+		_, err := q.DequeueUntil(1, 10)
+		assert.NotNil(err)
+	})
+}
@@ -0,0 +1,320 @@
+package queue
+
+import (
+	"errors"
+)
+
+// cursorSlotLength is the length of each persisted cursor slot
+//
+// Slot layout, in the byte order of the file:
+//
+//	0 in use flag (1 byte)
+//	1 reserved, zero (7 bytes)
+//	8 number of elements read by the cursor since the queue was created (8 bytes)
+const cursorSlotLength uint32 = 16
+
+var (
+	ErrNoCursorSlots = errors.New("no free cursor slot")
+	ErrCursorClosed  = errors.New("cursor is closed")
+)
+
+// WithCursors reserves n cursor slots after the user metadata block of a
+// newly created queue, allowing up to n cursors to be open at once
+//
+// The reservation is recorded in the file header, so reopening a queue
+// keeps its reservation regardless of this option.
+func WithCursors(n uint32) Option {
+	return func(ls *Queue) {
+		ls.cursorSlots = n
+	}
+}
+
+// Cursor reads the elements of a queue independently of other cursors
+// and of Dequeue
+//
+// Space is reclaimed only once every open cursor has read an element,
+// which is then removed from the queue. The number of elements a cursor
+// has read is persisted, so a reopened queue resumes each cursor where it
+// left off; see Queue.Cursors.
+type Cursor struct {
+	q           *Queue
+	slot        uint32
+	read        uint64 // number of elements read since the queue was created
+	pos         uint32 // offset of the next element to read, when known
+	relocations uint64 // value of Queue.relocations when pos was computed
+	known       bool   // whether pos is known
+	closed      bool
+}
+
+// NewCursor opens a cursor positioned at the head of the queue, or returns
+// ErrNoCursorSlots if every slot reserved with WithCursors is in use
+func (ls *Queue) NewCursor() (*Cursor, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if ls.readOnly {
+		return nil, ErrReadOnly
+	}
+
+	slot := uint32(len(ls.cursors))
+	for i, c := range ls.cursors {
+		if c == nil {
+			slot = uint32(i)
+			break
+		}
+	}
+	if slot >= ls.header.cursorSlots {
+		return nil, ErrNoCursorSlots
+	}
+
+	c := &Cursor{q: ls, slot: slot, read: ls.header.removed}
+	if err := ls.syncCursor(c); err != nil {
+		return nil, err
+	}
+
+	if slot == uint32(len(ls.cursors)) {
+		ls.cursors = append(ls.cursors, c)
+	} else {
+		ls.cursors[slot] = c
+	}
+
+	return c, nil
+}
+
+// Cursors returns the open cursors in slot order, including those
+// persisted by a previous run
+func (ls *Queue) Cursors() []*Cursor {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	var cursors []*Cursor
+	for _, c := range ls.cursors {
+		if c != nil {
+			cursors = append(cursors, c)
+		}
+	}
+	return cursors
+}
+
+// ID returns the slot of the cursor, which is stable across reopens
+func (c *Cursor) ID() int {
+	return int(c.slot)
+}
+
+// Next returns the next element for the cursor, or ErrQueueEmpty if the
+// cursor has read every element in the queue
+//
+// A cursor that falls behind the head of the queue, because elements were
+// dequeued or evicted before it read them, skips to the head.
+func (c *Cursor) Next() ([]byte, error) {
+	ls := c.q
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if ls.readOnly {
+		return nil, ErrReadOnly
+	}
+	if c.closed {
+		return nil, ErrCursorClosed
+	}
+
+	if c.consumed() == ls.header.queueSize {
+		return nil, ErrQueueEmpty
+	}
+
+	body, pos, frameLength, err := c.peek()
+	if err != nil {
+		return nil, err
+	}
+	v, err := ls.decodeElement(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.advance(pos, frameLength); err != nil {
+		return nil, err
+	}
+
+	if err := ls.reclaim(); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// peek returns the body of the next element for the cursor along with the
+// offset and length of its frame
+//
+// The cursor must not have read every element.
+func (c *Cursor) peek() (body []byte, pos, frameLength uint32, err error) {
+	ls := c.q
+	pos, err = c.position()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if ls.isWrapped() && pos == ls.header.wrapPosition {
+		pos = ls.dataStart()
+	}
+
+	body, frameLength, err = ls.readElement(pos, ls.header.fileLength)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return body, pos, frameLength, nil
+}
+
+// advance moves the cursor past the element whose frame, of frameLength
+// bytes, starts at pos and persists its slot
+func (c *Cursor) advance(pos, frameLength uint32) error {
+	ls := c.q
+	original := *c
+	c.read = ls.header.removed + uint64(c.consumed()) + 1
+	c.pos, c.relocations, c.known = pos+frameLength, ls.relocations, true
+	if err := ls.syncCursor(c); err != nil {
+		*c = original
+		return err
+	}
+	return nil
+}
+
+// Close releases the slot of the cursor, so that it no longer holds back
+// the reclaiming of space
+func (c *Cursor) Close() error {
+	ls := c.q
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if ls.readOnly {
+		return ErrReadOnly
+	}
+	if c.closed {
+		return ErrCursorClosed
+	}
+
+	c.closed = true
+	if err := ls.syncCursor(c); err != nil {
+		c.closed = false
+		return err
+	}
+	ls.cursors[c.slot] = nil
+
+	return ls.reclaim()
+}
+
+// consumed returns the number of live elements the cursor has read
+func (c *Cursor) consumed() uint32 {
+	h := c.q.header
+	if c.read <= h.removed {
+		return 0
+	}
+	if n := c.read - h.removed; n < uint64(h.queueSize) {
+		return uint32(n)
+	}
+	return h.queueSize
+}
+
+// position returns the offset of the next element for the cursor, walking
+// from the head when it is not known
+func (c *Cursor) position() (uint32, error) {
+	ls := c.q
+	n := c.consumed()
+	if n == 0 {
+		return ls.header.headPosition, nil
+	}
+	if c.known && c.relocations == ls.relocations {
+		return c.pos, nil
+	}
+
+	var pos uint32
+	i := uint32(0)
+	err := ls.walk(func(p, frameLength uint32, _ []byte) bool {
+		pos = p + frameLength
+		i++
+		return i < n
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return pos, nil
+}
+
+// syncCursor writes the slot of c to the cursor table
+func (ls *Queue) syncCursor(c *Cursor) error {
+	slot := make([]byte, cursorSlotLength)
+	if !c.closed {
+		slot[0] = 1
+		ls.header.order().PutUint64(slot[8:], c.read)
+	}
+
+	offset := int64(ls.cursorTableStart() + c.slot*cursorSlotLength)
+	if _, err := ls.writeAt(slot, offset); err != nil {
+		return ioError(OpHeaderSync, offset, err)
+	}
+	if err := ls.verifyWrite(slot, offset); err != nil {
+		return ioError(OpHeaderSync, offset, err)
+	}
+
+	return nil
+}
+
+// loadCursors reads the open cursors from the cursor table
+func (ls *Queue) loadCursors() error {
+	ls.cursors = nil
+	if ls.header.cursorSlots == 0 {
+		return nil
+	}
+
+	table := make([]byte, ls.header.cursorSlots*cursorSlotLength)
+	offset := int64(ls.cursorTableStart())
+	if err := ls.readAt(table, offset); err != nil {
+		return ioError(OpHeaderRead, offset, err)
+	}
+
+	for i := uint32(0); i < ls.header.cursorSlots; i++ {
+		slot := table[i*cursorSlotLength : (i+1)*cursorSlotLength]
+		if slot[0] == 0 {
+			continue
+		}
+		for uint32(len(ls.cursors)) < i {
+			ls.cursors = append(ls.cursors, nil)
+		}
+		ls.cursors = append(ls.cursors, &Cursor{q: ls, slot: i, read: ls.header.order().Uint64(slot[8:])})
+	}
+
+	return nil
+}
+
+// reclaim removes the elements at the head of the queue that every open
+// cursor has read, syncing the header once
+func (ls *Queue) reclaim() error {
+	min := ls.header.queueSize
+	open := false
+	for _, c := range ls.cursors {
+		if c == nil {
+			continue
+		}
+		open = true
+		if n := c.consumed(); n < min {
+			min = n
+		}
+	}
+	if !open || min == 0 {
+		return nil
+	}
+
+	// reclaiming is not a dequeue, so it goes ahead while dequeuing is
+	// paused
+	b, err := ls.headBatch(int(min), nil)
+	if err != nil {
+		return err
+	}
+
+	return b.remove()
+}
+
+// cursorTableStart returns the offset of the cursor slots, after the
+// header and any user metadata block
+func (ls *Queue) cursorTableStart() uint32 {
+	return headerLength + ls.header.userHeaderLength
+}
@@ -0,0 +1,131 @@
+package queue
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursors(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("reclaim after every cursor reads", func(t *testing.T) {
+		f := NewMemBuffer()
+		q := NewQueue(f, WithCursors(2))
+
+		var want [][]byte
+		for i := 0; i < 5; i++ {
+			v := []byte(fmt.Sprintf("element %d", i))
+			want = append(want, v)
+			assert.Nil(q.Enqueue(v))
+		}
+
+		a, err := q.NewCursor()
+		assert.Nil(err)
+		b, err := q.NewCursor()
+		assert.Nil(err)
+
+		_, err = q.NewCursor()
+		assert.Equal(ErrNoCursorSlots, err)
+
+		// the first cursor reads everything, but nothing is reclaimed
+		for _, v := range want {
+			got, err := a.Next()
+			assert.Nil(err)
+			assert.Equal(v, got)
+		}
+		_, err = a.Next()
+		assert.Equal(ErrQueueEmpty, err)
+		assert.Equal(5, q.Len())
+
+		// each element is reclaimed once the second cursor reads it
+		for i, v := range want[:3] {
+			got, err := b.Next()
+			assert.Nil(err)
+			assert.Equal(v, got)
+			assert.Equal(len(want)-i-1, q.Len())
+		}
+
+		// cursor positions survive a reopen
+		q = NewQueue(f)
+		cursors := q.Cursors()
+		assert.Len(cursors, 2)
+		a, b = cursors[0], cursors[1]
+		assert.Equal(0, a.ID())
+		assert.Equal(1, b.ID())
+
+		_, err = a.Next()
+		assert.Equal(ErrQueueEmpty, err)
+		for _, v := range want[3:] {
+			got, err := b.Next()
+			assert.Nil(err)
+			assert.Equal(v, got)
+		}
+		assert.Equal(0, q.Len())
+
+		// elements enqueued later are read by both cursors
+		assert.Nil(q.Enqueue([]byte("late")))
+		got, err := a.Next()
+		assert.Nil(err)
+		assert.Equal([]byte("late"), got)
+		assert.Equal(1, q.Len())
+
+		// closing the lagging cursor releases the element it held back
+		assert.Nil(b.Close())
+		assert.Equal(0, q.Len())
+		_, err = b.Next()
+		assert.Equal(ErrCursorClosed, err)
+
+		q = NewQueue(f)
+		assert.Len(q.Cursors(), 1)
+	})
+
+	t.Run("wrapping", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithCapacity(headerLength+2*cursorSlotLength+64), WithCursors(2))
+
+		a, err := q.NewCursor()
+		assert.Nil(err)
+		b, err := q.NewCursor()
+		assert.Nil(err)
+
+		// the second cursor lags behind, keeping elements live as the
+		// buffer wraps
+		var want [][]byte
+		wrapped := false
+		for i := 0; i < 50; i++ {
+			v := []byte(fmt.Sprintf("%02d", i))
+			want = append(want, v)
+			assert.Nil(q.Enqueue(v))
+			wrapped = wrapped || q.IsWrapped()
+
+			got, err := a.Next()
+			assert.Nil(err)
+			assert.Equal(v, got)
+
+			if i >= 3 {
+				got, err = b.Next()
+				assert.Nil(err)
+				assert.Equal(want[i-3], got)
+				assert.Equal(3, q.Len())
+			}
+		}
+		assert.True(wrapped)
+	})
+
+	t.Run("behind the head", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithCursors(1))
+
+		c, err := q.NewCursor()
+		assert.Nil(err)
+
+		assert.Nil(q.Enqueue([]byte("a")))
+		assert.Nil(q.Enqueue([]byte("b")))
+		_, err = q.Dequeue()
+		assert.Nil(err)
+
+		got, err := c.Next()
+		assert.Nil(err)
+		assert.Equal([]byte("b"), got)
+	})
+}
@@ -0,0 +1,64 @@
+package queue
+
+// WithValidator makes dequeues check each head element with fn, diverting
+// the elements fn returns an error for instead of returning them
+//
+// A rejected element is removed from the queue, after being enqueued to
+// the queue given to WithDeadLetter, if any, and the dequeue carries on
+// with the next element. Without a dead letter queue, rejected elements
+// are discarded. Validation applies to Dequeue and the dequeues built on
+// it, such as TryDequeue, DequeueContext, DequeueIf, and DequeueInto, but
+// not to batches, subscriptions, or cursors.
+func WithValidator(fn func([]byte) error) Option {
+	return func(ls *Queue) {
+		ls.validator = fn
+	}
+}
+
+// WithDeadLetter sets the queue that elements rejected by the validator
+// set with WithValidator are moved to, payload intact
+//
+// Each rejected element is enqueued to dlq before it is removed, so a
+// failure never loses it; if dlq does not accept the element, for example
+// with ErrQueueFull, the dequeue fails with that error and the element
+// stays at the head. The queue holds its lock while enqueuing to dlq, so
+// dlq must not send elements back to the queue.
+func WithDeadLetter(dlq *Queue) Option {
+	return func(ls *Queue) {
+		ls.deadLetter = dlq
+	}
+}
+
+// dequeueValid is dequeueHead, diverting head elements the validator
+// rejects until it reaches one it accepts
+func (ls *Queue) dequeueValid(pred func([]byte) bool) ([]byte, uint64, map[string]string, bool, error) {
+	for {
+		var rejected []byte
+		v, seq, tags, ok, err := ls.dequeueHead(func(v []byte) bool {
+			if ls.validator(v) != nil {
+				rejected = v
+				return false
+			}
+			return pred == nil || pred(v)
+		})
+		if err != nil || rejected == nil {
+			return v, seq, tags, ok, err
+		}
+
+		if err := ls.divert(rejected); err != nil {
+			return nil, 0, nil, false, err
+		}
+	}
+}
+
+// divert moves v, the rejected head element, to the dead letter queue
+func (ls *Queue) divert(v []byte) error {
+	if ls.deadLetter != nil {
+		if err := ls.deadLetter.Enqueue(v); err != nil {
+			return err
+		}
+	}
+
+	_, _, _, _, err := ls.dequeueHead(nil)
+	return err
+}
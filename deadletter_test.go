@@ -0,0 +1,100 @@
+package queue
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeadLetter(t *testing.T) {
+	assert := assert.New(t)
+
+	errBad := errors.New("bad element")
+	validate := func(v []byte) error {
+		if bytes.HasPrefix(v, []byte("bad")) {
+			return errBad
+		}
+		return nil
+	}
+
+	t.Run("routes rejected elements", func(t *testing.T) {
+		dlq := NewQueue(NewMemBuffer())
+		q := NewQueue(NewMemBuffer(), WithValidator(validate), WithDeadLetter(dlq))
+
+		for _, v := range []string{"bad-1", "good-1", "bad-2", "bad-3", "good-2", "bad-4"} {
+			assert.Nil(q.Enqueue([]byte(v)))
+		}
+
+		v, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Equal([]byte("good-1"), v)
+
+		v, ok, err := q.TryDequeue()
+		assert.Nil(err)
+		assert.True(ok)
+		assert.Equal([]byte("good-2"), v)
+
+		// the trailing bad element is removed on the way to finding the
+		// queue empty
+		_, err = q.Dequeue()
+		assert.Equal(ErrQueueEmpty, err)
+		assert.Equal(0, q.Len())
+
+		elements, err := dlq.Elements()
+		assert.Nil(err)
+		assert.Equal([][]byte{[]byte("bad-1"), []byte("bad-2"), []byte("bad-3"), []byte("bad-4")}, elements)
+	})
+
+	t.Run("without dead letter queue", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithValidator(validate))
+
+		assert.Nil(q.Enqueue([]byte("bad-1")))
+		assert.Nil(q.Enqueue([]byte("good-1")))
+
+		v, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Equal([]byte("good-1"), v)
+		assert.Equal(0, q.Len())
+	})
+
+	t.Run("full dead letter queue", func(t *testing.T) {
+		dlq := NewQueue(NewMemBuffer(), WithMaxElements(1))
+		q := NewQueue(NewMemBuffer(), WithValidator(validate), WithDeadLetter(dlq))
+
+		assert.Nil(q.Enqueue([]byte("bad-1")))
+		assert.Nil(q.Enqueue([]byte("bad-2")))
+		assert.Nil(q.Enqueue([]byte("good-1")))
+
+		// the element the dead letter queue refuses stays at the head
+		_, err := q.Dequeue()
+		assert.Equal(ErrQueueFull, err)
+		assert.Equal(2, q.Len())
+
+		v, err := q.Peek()
+		assert.Nil(err)
+		assert.Equal([]byte("bad-2"), v)
+
+		_, err = dlq.Dequeue()
+		assert.Nil(err)
+
+		v, err = q.Dequeue()
+		assert.Nil(err)
+		assert.Equal([]byte("good-1"), v)
+	})
+
+	t.Run("dequeue into", func(t *testing.T) {
+		dlq := NewQueue(NewMemBuffer())
+		q := NewQueue(NewMemBuffer(), WithValidator(validate), WithDeadLetter(dlq))
+
+		assert.Nil(q.Enqueue([]byte("bad-1")))
+		assert.Nil(q.Enqueue([]byte("good-1")))
+
+		buf := make([]byte, 16)
+		n, err := q.DequeueInto(buf)
+		assert.Nil(err)
+		assert.Equal([]byte("good-1"), buf[:n])
+		assert.Equal(1, dlq.Len())
+	})
+}
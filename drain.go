@@ -0,0 +1,32 @@
+package queue
+
+// WithDrainOnClose makes Close hand every remaining element to fn, in
+// FIFO order, before closing the backing store
+//
+// Each element is removed from the queue once fn accepts it. If fn returns
+// an error, Close stops draining and returns the error, leaving that
+// element and the rest in the queue.
+func WithDrainOnClose(fn func([]byte) error) Option {
+	return func(ls *Queue) {
+		ls.drain = fn
+	}
+}
+
+// drainAll dequeues every element through Queue.drain
+func (ls *Queue) drainAll() error {
+	for ls.unread() > 0 {
+		var fnErr error
+		_, _, err := ls.dequeueIf(func(v []byte) bool {
+			fnErr = ls.drain(v)
+			return fnErr == nil
+		})
+		if err != nil {
+			return err
+		}
+		if fnErr != nil {
+			return fnErr
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package queue
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrainOnClose(t *testing.T) {
+	assert := assert.New(t)
+
+	values := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+
+	t.Run("remaining elements are delivered", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		var drained [][]byte
+		q := NewQueue(f, WithDrainOnClose(func(v []byte) error {
+			drained = append(drained, v)
+			return nil
+		}))
+		for _, v := range values {
+			assert.Nil(q.Enqueue(v))
+		}
+		_, err = q.Dequeue()
+		assert.Nil(err)
+
+		assert.Nil(q.Close())
+		assert.Equal(values[1:], drained)
+
+		// the file is closed
+		_, err = f.Stat()
+		assert.NotNil(err)
+	})
+
+	t.Run("a failing callback stops draining", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		failure := errors.New("downstream unavailable")
+		var drained [][]byte
+		q := NewQueue(f, WithDrainOnClose(func(v []byte) error {
+			if len(drained) == 1 {
+				return failure
+			}
+			drained = append(drained, v)
+			return nil
+		}))
+		for _, v := range values {
+			assert.Nil(q.Enqueue(v))
+		}
+
+		assert.Equal(failure, q.Close())
+		assert.Equal(values[:1], drained)
+
+		elements, err := NewQueue(f).Elements()
+		assert.Nil(err)
+		assert.Equal(values[1:], elements)
+	})
+}
@@ -0,0 +1,35 @@
+package queue
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// OpenDSN returns a Queue backed by the storage named by dsn
+//
+// Supported schemes are:
+//
+//	file:///path/to/queue  a file, created if it does not exist
+//	mem:                   a MemBuffer that lives only as long as the Queue
+//
+// Files are opened as with Open, so a file is closed when the Queue is
+// closed, or straight away if the queue cannot be initialized.
+func OpenDSN(dsn string, opts ...Option) (*Queue, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("parse dsn: %w", err)
+	}
+
+	switch u.Scheme {
+	case "file":
+		if u.Path == "" {
+			return nil, fmt.Errorf("dsn %q has no file path", dsn)
+		}
+
+		return Open(u.Path, opts...)
+	case "mem":
+		return New(NewMemBuffer(), opts...)
+	default:
+		return nil, fmt.Errorf("unsupported dsn scheme %q", u.Scheme)
+	}
+}
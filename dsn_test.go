@@ -0,0 +1,60 @@
+package queue
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenDSN(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "dsn-*")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "queue")
+
+	for _, dsn := range []string{"file://" + path, "mem:"} {
+		t.Run(dsn, func(t *testing.T) {
+			q, err := OpenDSN(dsn, WithCapacity(headerLength+64))
+			assert.Nil(err)
+
+			for _, v := range []string{"a", "bc", "def"} {
+				assert.Nil(q.Enqueue([]byte(v)))
+			}
+			assert.Equal(ErrQueueFull, q.Enqueue(nBytes(48)))
+
+			for _, v := range []string{"a", "bc", "def"} {
+				front, err := q.Dequeue()
+				assert.Nil(err)
+				assert.Equal([]byte(v), front)
+			}
+
+			_, err = q.Dequeue()
+			assert.Equal(ErrQueueEmpty, err)
+		})
+	}
+
+	t.Run("file is reopened", func(t *testing.T) {
+		q, err := OpenDSN("file://" + path)
+		assert.Nil(err)
+		assert.Nil(q.Enqueue([]byte("persisted")))
+
+		q, err = OpenDSN("file://" + path)
+		assert.Nil(err)
+		front, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Equal([]byte("persisted"), front)
+	})
+
+	t.Run("invalid dsn", func(t *testing.T) {
+		for _, dsn := range []string{"s3://bucket/queue", "file://", "::"} {
+			_, err := OpenDSN(dsn)
+			assert.NotNil(err, dsn)
+		}
+	})
+}
@@ -0,0 +1,34 @@
+package queue
+
+import (
+	"errors"
+	"io"
+)
+
+var ErrElementSize = errors.New("element does not match the fixed element size")
+
+// WithFixedElementSize makes a newly created queue hold elements of
+// exactly n bytes, which are stored without a length prefix
+//
+// Enqueuing an element of any other length fails with ErrElementSize.
+// The size is recorded in the file header and overrides any Framer when
+// the queue is reopened. Fixed size elements cannot be combined with
+// compression or spillover, and cannot be enqueued with EnqueueReader.
+func WithFixedElementSize(n uint32) Option {
+	return func(ls *Queue) {
+		ls.elementSize = n
+	}
+}
+
+// fixedFramer stores bodies of a known length without any framing
+type fixedFramer struct {
+	length uint32
+}
+
+func (f fixedFramer) Frame(body []byte) []byte {
+	return append([]byte(nil), body...)
+}
+
+func (f fixedFramer) Unframe(r io.Reader) ([]byte, error) {
+	return readBody(r, f.length)
+}
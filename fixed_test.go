@@ -0,0 +1,58 @@
+package queue
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixedElementSize(t *testing.T) {
+	assert := assert.New(t)
+
+	f := NewMemBuffer()
+	q := NewQueue(f, WithCapacity(headerLength+32), WithFixedElementSize(8))
+
+	// four elements fill the buffer exactly, without length prefixes
+	for i := 0; i < 4; i++ {
+		assert.Nil(q.Enqueue(nBytes(8)))
+	}
+	assert.Equal(ErrQueueFull, q.Enqueue(nBytes(8)))
+
+	v, err := q.Dequeue()
+	assert.Nil(err)
+	assert.Len(v, 8)
+
+	for _, n := range []int{0, 7, 9} {
+		err := q.Enqueue(nBytes(n))
+		assert.True(errors.Is(err, ErrElementSize), "size %d", n)
+	}
+	assert.Equal(3, q.Len())
+
+	// the size is read back from the header
+	q = NewQueue(f)
+	assert.Equal(uint32(8), q.header.elementSize)
+	assert.Nil(q.Enqueue(nBytes(8)))
+	assert.True(errors.Is(q.Enqueue(nBytes(4)), ErrElementSize))
+
+	sizes, err := q.ElementSizes()
+	assert.Nil(err)
+	assert.Equal([]uint32{8, 8, 8, 8}, sizes)
+
+	t.Run("with sequence numbers", func(t *testing.T) {
+		f := NewMemBuffer()
+		q := NewQueue(f, WithFixedElementSize(4), WithSequenceNumbers())
+		assert.Nil(q.Enqueue([]byte("abcd")))
+		assert.Nil(q.Enqueue([]byte("efgh")))
+
+		v, seq, err := NewQueue(f).DequeueWithSeq()
+		assert.Nil(err)
+		assert.Equal([]byte("abcd"), v)
+		assert.Equal(uint64(1), seq)
+	})
+
+	t.Run("with compression", func(t *testing.T) {
+		_, err := New(NewMemBuffer(), WithFixedElementSize(4), WithCompression(flateCodecOrPanic()))
+		assert.NotNil(err)
+	})
+}
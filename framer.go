@@ -0,0 +1,188 @@
+package queue
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Framer controls how element bodies are laid out in the backing file
+//
+// Frame returns the bytes written for a body, and Unframe reads one frame
+// back from r and returns the body. Unframe must consume exactly the bytes
+// produced by Frame, since the queue uses the number of bytes read to find
+// the next element.
+type Framer interface {
+	Frame(body []byte) []byte
+	Unframe(r io.Reader) ([]byte, error)
+}
+
+// WithFramer replaces the default 4-byte big-endian length prefix framing
+// of elements with a custom Framer
+//
+// A queue written with a custom Framer must be reopened with the same Framer.
+func WithFramer(f Framer) Option {
+	return func(ls *Queue) {
+		ls.framer = f
+	}
+}
+
+// lengthPrefixFramer frames a body with a 4-byte length prefix in the
+// given byte order, or big-endian if order is nil
+type lengthPrefixFramer struct {
+	order binary.ByteOrder
+}
+
+func (f lengthPrefixFramer) byteOrder() binary.ByteOrder {
+	if f.order == nil {
+		return binary.BigEndian
+	}
+	return f.order
+}
+
+func (f lengthPrefixFramer) Frame(body []byte) []byte {
+	frame := make([]byte, 4+len(body))
+	f.byteOrder().PutUint32(frame[:4], uint32(len(body)))
+	copy(frame[4:], body)
+	return frame
+}
+
+func (f lengthPrefixFramer) Unframe(r io.Reader) ([]byte, error) {
+	var prefix [4]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return nil, err
+	}
+
+	return readBody(r, f.byteOrder().Uint32(prefix[:]))
+}
+
+// readBody reads a body of the given length following a length prefix
+func readBody(r io.Reader, length uint32) ([]byte, error) {
+	if exceedsLimit(r, length) {
+		return nil, shortBodyError{length: length}
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, shortBodyError{length: length}
+		}
+		return nil, err
+	}
+
+	return body, nil
+}
+
+// shortBodyError is returned by the built-in framers when fewer bytes
+// than the length recorded in a frame's prefix can be read
+type shortBodyError struct {
+	length uint32
+}
+
+func (e shortBodyError) Error() string {
+	return fmt.Sprintf("expected %d byte element body: %v", e.length, io.ErrUnexpectedEOF)
+}
+
+func (e shortBodyError) Unwrap() error {
+	return io.ErrUnexpectedEOF
+}
+
+// TruncatedElementError reports an element whose frame ends before all of
+// its bytes could be read, such as when the backing file was cut short by
+// a crash in the middle of a write
+//
+// It matches ErrTruncatedElement with errors.Is.
+type TruncatedElementError struct {
+	Offset uint32 // offset of the element's frame
+	Length uint32 // body length recorded in the frame, or 0 if unknown
+}
+
+func (e *TruncatedElementError) Error() string {
+	if e.Length == 0 {
+		return fmt.Sprintf("%v at offset %d", ErrTruncatedElement, e.Offset)
+	}
+	return fmt.Sprintf("%v at offset %d: expected %d byte body", ErrTruncatedElement, e.Offset, e.Length)
+}
+
+func (e *TruncatedElementError) Unwrap() error {
+	return ErrTruncatedElement
+}
+
+// checkLength returns an error wrapping ErrCorruptElement if the element
+// at pos records a body of length bytes, which could not fit anywhere in
+// the element region
+func (ls *Queue) checkLength(pos, length uint32) error {
+	if limit := ls.header.fileLength - ls.dataStart(); length > limit {
+		return fmt.Errorf("%w at offset %d: %d byte body exceeds the %d byte element region", ErrCorruptElement, pos, length, limit)
+	}
+	return nil
+}
+
+// exceedsLimit reports whether r is known to hold fewer than n bytes,
+// allowing framers to reject corrupt lengths before allocating
+func exceedsLimit(r io.Reader, n uint32) bool {
+	cr, ok := r.(*countingReader)
+	return ok && int64(n) > cr.limit-int64(cr.n)
+}
+
+// countingReader counts the bytes read through it from a reader
+// holding at most limit bytes
+type countingReader struct {
+	r     io.Reader
+	n     uint32
+	limit int64
+	err   error // first error other than io.EOF returned by r
+}
+
+func (cr *countingReader) Read(b []byte) (int, error) {
+	n, err := cr.r.Read(b)
+	cr.n += uint32(n)
+	if err != nil && err != io.EOF && cr.err == nil {
+		cr.err = err
+	}
+	return n, err
+}
+
+// readElement reads the element framed at pos, reading no further than end,
+// and returns its body along with the number of bytes its frame occupies
+func (ls *Queue) readElement(pos, end uint32) ([]byte, uint32, error) {
+	var body []byte
+	var frameLength uint32
+	err := ls.retry(isIOError, func() (err error) {
+		body, frameLength, err = ls.readElementOnce(pos, end)
+		return err
+	})
+	return body, frameLength, err
+}
+
+func (ls *Queue) readElementOnce(pos, end uint32) ([]byte, uint32, error) {
+	limit := int64(end) - int64(pos)
+	src, err := ls.readerAt(int64(pos), limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	r := &countingReader{r: src, limit: limit}
+	body, err := ls.framer.Unframe(r)
+	if err != nil && r.err != nil {
+		return nil, 0, ioError(OpElementRead, int64(pos), err)
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		truncated := &TruncatedElementError{Offset: pos}
+		var short shortBodyError
+		if errors.As(err, &short) {
+			if err := ls.checkLength(pos, short.length); err != nil {
+				return nil, 0, err
+			}
+			truncated.Length = short.length
+		}
+		return nil, 0, truncated
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// padding after the frame belongs to the element
+	return body, ls.align(r.n), nil
+}
@@ -0,0 +1,95 @@
+package queue
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// delimitedFramer frames a body with a 2-byte length prefix
+// and a trailing newline
+type delimitedFramer struct{}
+
+func (delimitedFramer) Frame(body []byte) []byte {
+	frame := make([]byte, 2+len(body)+1)
+	binary.LittleEndian.PutUint16(frame[:2], uint16(len(body)))
+	copy(frame[2:], body)
+	frame[len(frame)-1] = '\n'
+	return frame
+}
+
+func (delimitedFramer) Unframe(r io.Reader) ([]byte, error) {
+	var prefix [2]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return nil, err
+	}
+
+	body := make([]byte, binary.LittleEndian.Uint16(prefix[:])+1)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	if body[len(body)-1] != '\n' {
+		return nil, errors.New("missing frame delimiter")
+	}
+
+	return body[:len(body)-1], nil
+}
+
+func TestFramer(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("custom framer round trips", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f, WithFramer(delimitedFramer{}))
+
+		values := [][]byte{[]byte("a"), []byte("bc"), []byte(""), []byte("def")}
+		for _, v := range values {
+			assert.Nil(q.Enqueue(v))
+		}
+
+		q = NewQueue(f, WithFramer(delimitedFramer{}))
+
+		for _, v := range values {
+			front, err := q.Dequeue()
+			assert.Nil(err)
+			assert.Equal(v, front)
+		}
+	})
+
+	t.Run("custom framer controls the on-disk layout", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f, WithFramer(delimitedFramer{}))
+		assert.Nil(q.Enqueue([]byte("first")))
+		assert.Nil(q.Enqueue([]byte("second")))
+
+		_, err = f.Seek(int64(headerLength), io.SeekStart)
+		assert.Nil(err)
+
+		r := bufio.NewReader(f)
+		for _, want := range []string{"first", "second"} {
+			line, err := r.ReadBytes('\n')
+			assert.Nil(err)
+			assert.Equal(want, string(line[2:len(line)-1]))
+		}
+	})
+
+	t.Run("default framer uses a big-endian length prefix", func(t *testing.T) {
+		frame := lengthPrefixFramer{}.Frame([]byte("abc"))
+		assert.Equal([]byte{0, 0, 0, 3, 'a', 'b', 'c'}, frame)
+
+		body, err := lengthPrefixFramer{}.Unframe(bytes.NewReader(frame))
+		assert.Nil(err)
+		assert.Equal([]byte("abc"), body)
+	})
+}
@@ -0,0 +1,67 @@
+package queue
+
+// FullAction tells Enqueue how to handle an element that does not fit in
+// a full queue
+type FullAction int
+
+const (
+	// ActionReject fails the enqueue with ErrQueueFull
+	ActionReject FullAction = iota
+	// ActionDropOldest evicts elements from the head of the queue until
+	// the incoming element fits
+	ActionDropOldest
+	// ActionBlock waits until the queue changes and then retries the
+	// enqueue, consulting the policy again if the queue is still full, or
+	// fails with ErrClosed once the queue is closed
+	ActionBlock
+)
+
+// FullPolicy chooses how to handle incoming, an element that does not fit
+// in the full queue q
+type FullPolicy func(incoming []byte, q *Queue) FullAction
+
+// WithFullPolicy makes Enqueue and EnqueueAt call fn whenever an element
+// does not fit, and handle the element according to the returned action
+//
+// fn is called without the queue locked, so it may inspect q, for example
+// with Len or Peek. The queue may change between fn returning and the
+// action being applied. WithOverwriteOldest takes precedence, since a
+// queue that evicts its oldest elements is never full.
+func WithFullPolicy(fn func(incoming []byte, q *Queue) FullAction) Option {
+	return func(ls *Queue) {
+		ls.fullPolicy = fn
+	}
+}
+
+// enqueueWithPolicy adds v to the queue, consulting the full policy when
+// there is no room for it
+//
+// Queue.mu must be held by the caller
+func (ls *Queue) enqueueWithPolicy(v []byte, tags map[string]string) (uint32, error) {
+	for {
+		offset, err := ls.enqueueEvict(v, tags, ls.overwriteOldest)
+		if err != ErrQueueFull || ls.fullPolicy == nil {
+			return offset, err
+		}
+
+		moves := ls.headMoves
+		ls.mu.Unlock()
+		action := ls.fullPolicy(v, ls)
+		ls.mu.Lock()
+
+		switch action {
+		case ActionDropOldest:
+			return ls.enqueueEvict(v, tags, true)
+		case ActionBlock:
+			// retry straight away if elements were removed while fn ran
+			if ls.headMoves == moves && !ls.isClosed() {
+				ls.cond.Wait()
+			}
+			if ls.isClosed() {
+				return 0, ErrClosed
+			}
+		default:
+			return 0, ErrQueueFull
+		}
+	}
+}
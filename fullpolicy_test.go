@@ -0,0 +1,104 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFullPolicy(t *testing.T) {
+	assert := assert.New(t)
+
+	// room for two 4 byte payloads
+	full := func(policy FullPolicy) *Queue {
+		q := NewQueue(NewMemBuffer(), WithCapacity(headerLength+16), WithFullPolicy(policy))
+		assert.Nil(q.Enqueue([]byte("aaaa")))
+		assert.Nil(q.Enqueue([]byte("bbbb")))
+		return q
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		var incoming []byte
+		q := full(func(v []byte, q *Queue) FullAction {
+			incoming = v
+			assert.Equal(2, q.Len())
+			return ActionReject
+		})
+
+		assert.Equal(ErrQueueFull, q.Enqueue([]byte("cccc")))
+		assert.Equal([]byte("cccc"), incoming)
+		assert.Equal(2, q.Len())
+	})
+
+	t.Run("drop oldest", func(t *testing.T) {
+		q := full(func([]byte, *Queue) FullAction {
+			return ActionDropOldest
+		})
+
+		assert.Nil(q.Enqueue([]byte("cccc")))
+		elements, err := q.Elements()
+		assert.Nil(err)
+		assert.Equal([][]byte{[]byte("bbbb"), []byte("cccc")}, elements)
+		assert.Equal(uint64(1), q.Evicted())
+	})
+
+	t.Run("block", func(t *testing.T) {
+		calls := 0
+		q := full(func([]byte, *Queue) FullAction {
+			calls++
+			return ActionBlock
+		})
+
+		done := make(chan error)
+		go func() {
+			done <- q.Enqueue([]byte("cccc"))
+		}()
+
+		select {
+		case <-done:
+			t.Fatal("enqueue returned while the queue was full")
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		v, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Equal([]byte("aaaa"), v)
+		assert.Nil(<-done)
+		assert.Equal(1, calls)
+
+		elements, err := q.Elements()
+		assert.Nil(err)
+		assert.Equal([][]byte{[]byte("bbbb"), []byte("cccc")}, elements)
+	})
+
+	t.Run("close wakes a blocked enqueue", func(t *testing.T) {
+		q := full(func([]byte, *Queue) FullAction {
+			return ActionBlock
+		})
+
+		done := make(chan error)
+		go func() {
+			done <- q.Enqueue([]byte("cccc"))
+		}()
+
+		time.Sleep(10 * time.Millisecond)
+		assert.Nil(q.Close())
+		assert.Equal(ErrClosed, <-done)
+	})
+
+	t.Run("content based", func(t *testing.T) {
+		q := full(func(v []byte, _ *Queue) FullAction {
+			if v[0] == '!' {
+				return ActionDropOldest
+			}
+			return ActionReject
+		})
+
+		assert.Equal(ErrQueueFull, q.Enqueue([]byte("cccc")))
+		assert.Nil(q.Enqueue([]byte("!!!!")))
+		elements, err := q.Elements()
+		assert.Nil(err)
+		assert.Equal([][]byte{[]byte("bbbb"), []byte("!!!!")}, elements)
+	})
+}
@@ -5,4 +5,5 @@ go 1.15
 require (
 	github.com/leanovate/gopter v0.2.9
 	github.com/stretchr/testify v1.6.1
+	golang.org/x/time v0.0.0-20210220033141-f8bda1e9f3ba
 )
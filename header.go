@@ -0,0 +1,300 @@
+package queue
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+)
+
+// The file header is double-buffered: it is stored in two slots, each
+// holding a complete copy of the header along with a sequence number and
+// a checksum. Writes alternate between the slots, so a torn write can only
+// damage the slot being written while the other still holds the previous
+// consistent header. Readers pick the valid slot with the highest sequence.
+//
+// Slot layout, big-endian:
+//
+//	0  fileLength
+//	4  queueSize
+//	8  headPosition
+//	12 tailPosition
+//	16 wrapPosition
+//	20 userHeaderLength
+//	24 byte order flag (1 byte)
+//	25 layout version (1 byte)
+//	26 feature flags (1 byte), since version 1
+//	27 next element sequence number (8 bytes), since version 1
+//	35 fixed element size, or 0 (4 bytes), since version 2
+//	39 number of cursor slots (4 bytes), since version 3
+//	43 number of elements ever removed from the head (8 bytes), since version 3
+//	51 log2 of the block size the element region is aligned to, or 0 (1 byte), since version 4
+//	52 sequence (8 bytes)
+//	60 CRC-32 of bytes 0-59
+//
+// Every multi-byte field in the slot, and the length prefix of every
+// element when the default framing is used, is written in the byte order
+// recorded by the flag, which is big-endian for files written before the
+// flag existed.
+//
+// Files written before the layout was versioned hold version 0. Fields are
+// only read from slots whose version includes them, so older files open
+// with those fields zero, and are upgraded to headerVersion the next time
+// the header is written. Slots with a version newer than headerVersion are
+// treated as invalid.
+//
+// Files written before the header was double-buffered start with a single
+// 16-byte header instead and are not opened; Migrate upgrades them from
+// LegacyVersion.
+const headerSlotLength uint32 = 64
+
+// headerVersion is the layout version written to the header
+const headerVersion byte = 4
+
+// byte order flags stored in the header
+const (
+	orderBigEndian    byte = 0
+	orderLittleEndian byte = 1
+)
+
+// feature flags stored in the header
+const (
+	headerSequenced      byte = 1 << iota // elements carry a sequence number
+	headerTimestamped                     // elements carry the time they were enqueued
+	headerTagged                          // element payloads carry a tag block
+	headerConsumerOffset                  // dequeues advance a persisted consumer offset
+	headerFlagged                         // element bodies begin with a compression or spill flag
+)
+
+type fileHeader struct {
+	fileLength   uint32 // total length of the buffer backing a queue
+	queueSize    uint32 // total number of elements in a queue
+	headPosition uint32 // offset at which the first-in element can be found
+	tailPosition uint32 // offset at which the last-in  element can be found
+	wrapPosition uint32 // offset at which elements stop before wrapping to the front, or 0 when not wrapped
+
+	userHeaderLength uint32 // length of the user metadata block reserved after the header
+	byteOrder        byte   // byte order flag of the file
+	flags            byte   // feature flags of the file
+	nextSequence     uint64 // sequence number of the next element enqueued when sequenced
+	elementSize      uint32 // payload length of every element, or 0 if lengths vary
+	cursorSlots      uint32 // number of cursor slots reserved after the user metadata block
+	removed          uint64 // number of elements ever removed from the head
+	blockShift       byte   // log2 of the block size the element region starts on, or 0
+}
+
+// dataStart returns the offset at which the element region begins, after
+// the header, any user metadata block, and any cursor slots, rounded up to
+// the block size if the header is padded
+func (h fileHeader) dataStart() uint32 {
+	slots := h.cursorSlots
+	if h.hasConsumerOffset() {
+		slots++
+	}
+	start := headerLength + h.userHeaderLength + slots*cursorSlotLength
+	if h.blockShift != 0 {
+		block := uint32(1) << h.blockShift
+		start = (start + block - 1) &^ (block - 1)
+	}
+	return start
+}
+
+// order returns the byte order of the file described by the header
+func (h fileHeader) order() binary.ByteOrder {
+	if h.byteOrder == orderLittleEndian {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
+
+// HeaderView is a copy of a queue's file header, for debugging and tools
+type HeaderView struct {
+	FileLength       uint32 // total length of the buffer, including the header
+	QueueSize        uint32 // number of elements in the queue
+	HeadPosition     uint32 // offset of the first-in element
+	TailPosition     uint32 // offset at which the next element will be written
+	WrapPosition     uint32 // offset at which elements wrap to the front, or 0 when not wrapped
+	UserHeaderLength uint32 // length of the reserved user metadata block
+	ByteOrder        binary.ByteOrder
+}
+
+// Header returns a copy of the cached file header
+func (ls *Queue) Header() HeaderView {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	h := ls.header
+	return HeaderView{
+		FileLength:       h.fileLength,
+		QueueSize:        h.queueSize,
+		HeadPosition:     h.headPosition,
+		TailPosition:     h.tailPosition,
+		WrapPosition:     h.wrapPosition,
+		UserHeaderLength: h.userHeaderLength,
+		ByteOrder:        h.order(),
+	}
+}
+
+// syncHeader writes the in-memory queue header to Queue.rws, or defers
+// the write until the next checkpoint with WithHeaderCheckpointEvery
+func (ls *Queue) syncHeader() error {
+	if ls.readOnly {
+		return ErrReadOnly
+	}
+
+	if ls.checkpointing() && ls.headerSeq != 0 {
+		ls.unsynced++
+		if ls.unsynced < ls.checkpointEvery {
+			ls.resolveTracked()
+			return nil
+		}
+	}
+
+	return ls.writeHeader()
+}
+
+// writeHeader writes the in-memory queue header to Queue.rws
+//
+// The header is written to the slot not holding the most recent header,
+// so the previous header survives if the write is torn.
+func (ls *Queue) writeHeader() error {
+	if ls.readOnly {
+		return ErrReadOnly
+	}
+
+	seq := ls.headerSeq + 1
+	slot := encodeHeaderSlot(ls.header, seq)
+
+	// Write header
+	offset := int64(seq%2) * int64(headerSlotLength)
+	if _, err := ls.writeAt(slot, offset); err != nil {
+		return ioError(OpHeaderSync, offset, err)
+	}
+	if err := ls.verifyWrite(slot, offset); err != nil {
+		return ioError(OpHeaderSync, offset, err)
+	}
+
+	ls.headerSeq = seq
+	ls.durable = ls.header
+	ls.unsynced = 0
+	ls.resolveTracked()
+	return nil
+}
+
+// readHeader returns the most recent valid header in Queue.rws along with
+// its sequence number
+//
+// io.EOF is returned for an empty file, and ErrInvalidHeader if neither
+// header slot holds a valid header.
+func (ls *Queue) readHeader() (fileHeader, uint64, error) {
+	// the second slot is missing until the header has been written twice
+	headerBytes := make([]byte, headerLength)
+	err := ls.readAt(headerBytes, 0)
+	if err == io.EOF {
+		return fileHeader{}, 0, err
+	}
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return fileHeader{}, 0, ioError(OpHeaderRead, 0, err)
+	}
+
+	var (
+		header fileHeader
+		seq    uint64
+		found  bool
+	)
+	for i := uint32(0); i < 2; i++ {
+		h, s, ok := decodeHeaderSlot(headerBytes[i*headerSlotLength : (i+1)*headerSlotLength])
+		if ok && (!found || s > seq) {
+			header, seq, found = h, s, true
+		}
+	}
+
+	if !found {
+		return fileHeader{}, 0, ErrInvalidHeader
+	}
+
+	return header, seq, nil
+}
+
+func encodeHeaderSlot(h fileHeader, seq uint64) []byte {
+	return encodeHeaderSlotVersion(h, seq, headerVersion)
+}
+
+// encodeHeaderSlotVersion encodes h in the layout of the given version,
+// leaving out fields the version does not include
+func encodeHeaderSlotVersion(h fileHeader, seq uint64, version byte) []byte {
+	order := h.order()
+
+	slot := make([]byte, headerSlotLength)
+	order.PutUint32(slot[:4], h.fileLength)
+	order.PutUint32(slot[4:8], h.queueSize)
+	order.PutUint32(slot[8:12], h.headPosition)
+	order.PutUint32(slot[12:16], h.tailPosition)
+	order.PutUint32(slot[16:20], h.wrapPosition)
+	order.PutUint32(slot[20:24], h.userHeaderLength)
+	slot[24] = h.byteOrder
+	slot[25] = version
+	if version >= 1 {
+		slot[26] = h.flags
+		order.PutUint64(slot[27:35], h.nextSequence)
+	}
+	if version >= 2 {
+		order.PutUint32(slot[35:39], h.elementSize)
+	}
+	if version >= 3 {
+		order.PutUint32(slot[39:43], h.cursorSlots)
+		order.PutUint64(slot[43:51], h.removed)
+	}
+	if version >= 4 {
+		slot[51] = h.blockShift
+	}
+	order.PutUint64(slot[52:60], seq)
+	order.PutUint32(slot[60:], crc32.ChecksumIEEE(slot[:60]))
+	return slot
+}
+
+func decodeHeaderSlot(slot []byte) (fileHeader, uint64, bool) {
+	// the flag is a single byte, so it can be read before the byte order
+	// of the other fields is known
+	h := fileHeader{byteOrder: slot[24]}
+	if h.byteOrder != orderBigEndian && h.byteOrder != orderLittleEndian {
+		return fileHeader{}, 0, false
+	}
+	order := h.order()
+
+	if order.Uint32(slot[60:]) != crc32.ChecksumIEEE(slot[:60]) {
+		return fileHeader{}, 0, false
+	}
+
+	seq := order.Uint64(slot[52:60])
+	if seq == 0 {
+		// an all-zero slot has a valid checksum but was never written
+		return fileHeader{}, 0, false
+	}
+
+	version := slot[25]
+	if version > headerVersion {
+		return fileHeader{}, 0, false
+	}
+
+	h.fileLength = order.Uint32(slot[:4])
+	h.queueSize = order.Uint32(slot[4:8])
+	h.headPosition = order.Uint32(slot[8:12])
+	h.tailPosition = order.Uint32(slot[12:16])
+	h.wrapPosition = order.Uint32(slot[16:20])
+	h.userHeaderLength = order.Uint32(slot[20:24])
+	if version >= 1 {
+		h.flags = slot[26]
+		h.nextSequence = order.Uint64(slot[27:35])
+	}
+	if version >= 2 {
+		h.elementSize = order.Uint32(slot[35:39])
+	}
+	if version >= 3 {
+		h.cursorSlots = order.Uint32(slot[39:43])
+		h.removed = order.Uint64(slot[43:51])
+	}
+	if version >= 4 {
+		h.blockShift = slot[51]
+	}
+	return h, seq, true
+}
@@ -0,0 +1,223 @@
+package queue
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeader(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("torn header write recovers the previous header", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		rws := newFlakyReadWriteSeeker(f)
+		q := NewQueue(rws)
+
+		assert.Nil(q.Enqueue([]byte("a")))
+		assert.Nil(q.Enqueue([]byte("b")))
+		before := q.header
+
+		// the dequeue only writes the header, which is torn part way through
+		rws.tearNextWrite(10)
+		_, err = q.Dequeue()
+		var ioErr *IOError
+		assert.True(errors.As(err, &ioErr))
+		assert.Equal(OpHeaderSync, ioErr.Op)
+
+		q = NewQueue(f)
+		assert.Equal(before, q.header)
+
+		for _, want := range []string{"a", "b"} {
+			front, err := q.Dequeue()
+			assert.Nil(err)
+			assert.Equal([]byte(want), front)
+		}
+	})
+
+	t.Run("writes alternate between slots", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f)
+		assert.Nil(q.Enqueue([]byte("a")))
+
+		raw := make([]byte, headerLength)
+		_, err = f.ReadAt(raw, 0)
+		assert.Nil(err)
+
+		older, olderSeq, ok := decodeHeaderSlot(raw[headerSlotLength:])
+		assert.True(ok)
+		newer, newerSeq, ok := decodeHeaderSlot(raw[:headerSlotLength])
+		assert.True(ok)
+
+		// the initial header and the enqueue landed in different slots
+		assert.Equal(olderSeq+1, newerSeq)
+		assert.Equal(uint32(0), older.queueSize)
+		assert.Equal(uint32(1), newer.queueSize)
+	})
+
+	t.Run("newest valid slot wins", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f)
+		assert.Nil(q.Enqueue([]byte("a")))
+		assert.Nil(q.Enqueue([]byte("b")))
+
+		q = NewQueue(f)
+		assert.Equal(uint32(2), q.header.queueSize)
+
+		// corrupting the newest slot falls back to the older one
+		slot := int64(q.headerSeq%2) * int64(headerSlotLength)
+		_, err = f.WriteAt([]byte{0xff}, slot)
+		assert.Nil(err)
+
+		q = NewQueue(f)
+		assert.Equal(uint32(1), q.header.queueSize)
+	})
+
+	t.Run("no valid slot", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		_, err = f.Write(make([]byte, headerLength))
+		assert.Nil(err)
+
+		q := newQueue(f, nil)
+		assert.Equal(ErrInvalidHeader, q.init())
+	})
+}
+
+func TestHeaderView(t *testing.T) {
+	assert := assert.New(t)
+
+	q := NewQueue(NewMemBuffer(), WithCapacity(headerLength+32))
+	start := headerLength
+
+	assert.Equal(HeaderView{
+		FileLength:   headerLength + 32,
+		HeadPosition: start,
+		TailPosition: start,
+		ByteOrder:    binary.BigEndian,
+	}, q.Header())
+
+	assert.Nil(q.Enqueue(nBytes(12)))
+	assert.Nil(q.Enqueue(nBytes(8)))
+	_, err := q.Dequeue()
+	assert.Nil(err)
+	assert.Nil(q.Enqueue(nBytes(4)))
+
+	assert.Equal(HeaderView{
+		FileLength:   headerLength + 32,
+		QueueSize:    2,
+		HeadPosition: start + 16,
+		TailPosition: start + 8,
+		WrapPosition: start + 28,
+		ByteOrder:    binary.BigEndian,
+	}, q.Header())
+}
+
+func TestHeaderVersion(t *testing.T) {
+	assert := assert.New(t)
+
+	// rewrites both big-endian header slots as the given layout version,
+	// filling the bytes after the version with garbage
+	downgrade := func(f *MemBuffer, version byte) {
+		b := f.Bytes()
+		for i := uint32(0); i < 2; i++ {
+			slot := b[i*headerSlotLength : (i+1)*headerSlotLength]
+			slot[25] = version
+			switch version {
+			case 0:
+				copy(slot[26:39], nBytes(13))
+			case 1:
+				copy(slot[35:39], nBytes(4))
+			}
+			binary.BigEndian.PutUint32(slot[60:], crc32.ChecksumIEEE(slot[:60]))
+		}
+	}
+
+	versions := func(f *MemBuffer) []byte {
+		b := f.Bytes()
+		return []byte{b[25], b[headerSlotLength+25]}
+	}
+
+	for _, version := range []byte{0, 1} {
+		// version 0 files cannot hold sequence numbers
+		var opts []Option
+		if version >= 1 {
+			opts = append(opts, WithSequenceNumbers())
+		}
+
+		f := NewMemBuffer()
+		q := NewQueue(f, opts...)
+		assert.Nil(q.Enqueue([]byte("a")))
+		assert.Nil(q.Enqueue([]byte("b")))
+		downgrade(f, version)
+
+		// fields missing from the older layout read as zero
+		q, err := New(f)
+		assert.Nil(err, "version %d", version)
+		assert.Equal(uint32(0), q.header.elementSize)
+		if version == 1 {
+			assert.True(q.sequenced())
+			assert.Equal(uint64(3), q.header.nextSequence)
+		}
+
+		elements, err := q.Elements()
+		assert.Nil(err)
+		assert.Equal([][]byte{[]byte("a"), []byte("b")}, elements)
+
+		// the next header written is upgraded, the other slot is not yet
+		assert.Nil(q.Enqueue([]byte("ccc")))
+		upgraded := versions(f)
+		assert.Contains(upgraded, headerVersion)
+		assert.Contains(upgraded, version)
+
+		q, err = New(f)
+		assert.Nil(err)
+		assert.Equal(3, q.Len())
+	}
+
+	// newer layouts are rejected
+	f := NewMemBuffer()
+	NewQueue(f)
+	downgrade(f, headerVersion+1)
+	_, err := New(f)
+	assert.Equal(ErrInvalidHeader, err)
+}
+
+func TestHeaderPadding(t *testing.T) {
+	assert := assert.New(t)
+
+	f := NewMemBuffer()
+	q := NewQueue(f, WithHeaderPadding(512), WithUserHeader(16), WithCapacity(4096))
+
+	offset, err := q.EnqueueAt([]byte("first"))
+	assert.Nil(err)
+	assert.Equal(uint32(512), offset)
+
+	// the layout is read back from the header
+	q = NewQueue(f)
+	offset, err = q.EnqueueAt([]byte("second"))
+	assert.Nil(err)
+	assert.Equal(uint32(512+9), offset)
+
+	elements, err := q.Elements()
+	assert.Nil(err)
+	assert.Equal([][]byte{[]byte("first"), []byte("second")}, elements)
+
+	// the padding counts against the capacity
+	_, err = New(NewMemBuffer(), WithHeaderPadding(512), WithCapacity(512))
+	assert.True(errors.Is(err, ErrCapacityTooSmall))
+
+	_, err = New(NewMemBuffer(), WithHeaderPadding(500))
+	assert.NotNil(err)
+}
@@ -0,0 +1,189 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+)
+
+// HealthCheck verifies that the queue file is internally consistent
+// without modifying it
+//
+// The header is re-read from the file and compared with the in-memory
+// header, and the chain of element frames is walked from the head to
+// check that every frame lies within the buffer and that the chain ends
+// exactly at the tail. A descriptive error is returned for the first
+// inconsistency found.
+func (ls *Queue) HealthCheck() error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	header, _, err := ls.readHeader()
+	if err != nil {
+		return fmt.Errorf("read header: %w", err)
+	}
+	if header != ls.header {
+		return fmt.Errorf("header on disk %+v does not match cached header %+v", header, ls.header)
+	}
+
+	if err := ls.checkHeader(); err != nil {
+		return err
+	}
+
+	return ls.checkElements()
+}
+
+// checkHeader verifies that the positions in the header lie within the
+// buffer and agree with one another
+func (ls *Queue) checkHeader() error {
+	h := ls.header
+
+	start := ls.dataStart()
+	if h.fileLength < start {
+		return fmt.Errorf("file length %d is shorter than the header", h.fileLength)
+	}
+
+	for _, p := range []struct {
+		name string
+		pos  uint32
+	}{{"head", h.headPosition}, {"tail", h.tailPosition}} {
+		if p.pos < start || p.pos > h.fileLength {
+			return fmt.Errorf("%s position %d is outside of [%d, %d]", p.name, p.pos, start, h.fileLength)
+		}
+	}
+
+	if ls.isWrapped() {
+		if h.wrapPosition < h.headPosition || h.wrapPosition > h.fileLength {
+			return fmt.Errorf("wrap position %d is outside of [%d, %d]", h.wrapPosition, h.headPosition, h.fileLength)
+		}
+		if h.tailPosition > h.headPosition {
+			return fmt.Errorf("tail position %d is past head position %d in a wrapped queue", h.tailPosition, h.headPosition)
+		}
+	} else if h.tailPosition < h.headPosition {
+		return fmt.Errorf("tail position %d is before head position %d in an unwrapped queue", h.tailPosition, h.headPosition)
+	}
+
+	if h.queueSize == 0 && h.headPosition != h.tailPosition {
+		return fmt.Errorf("empty queue has head position %d and tail position %d", h.headPosition, h.tailPosition)
+	}
+
+	return nil
+}
+
+// checkElements walks the frames of every live element and verifies that
+// none crosses the wrap position and that the last ends at the tail
+func (ls *Queue) checkElements() error {
+	h := ls.header
+	if h.queueSize == 0 {
+		return nil
+	}
+
+	var (
+		end      = h.headPosition
+		wrapped  = ls.isWrapped()
+		chainErr error
+	)
+	err := ls.walk(func(pos, frameLength uint32, _ []byte) bool {
+		if wrapped && pos == ls.dataStart() {
+			wrapped = false
+		}
+
+		end = pos + frameLength
+		if wrapped && end > h.wrapPosition {
+			chainErr = fmt.Errorf("element at %d with frame length %d crosses wrap position %d", pos, frameLength, h.wrapPosition)
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("walk elements: %w", err)
+	}
+	if chainErr != nil {
+		return chainErr
+	}
+
+	if wrapped {
+		return fmt.Errorf("elements end at %d without reaching wrap position %d", end, h.wrapPosition)
+	}
+	if end != h.tailPosition {
+		return fmt.Errorf("elements end at %d but tail position is %d", end, h.tailPosition)
+	}
+
+	return nil
+}
+
+// VerifyReport describes the state of a queue file as found by Verify
+type VerifyReport struct {
+	Frames         int      // valid element frames walked from the head
+	FirstBadOffset int64    // offset of the first invalid frame, or -1 if none
+	Consistent     bool     // head, tail and size agree with the frame chain
+	Truncated      bool     // the backing store ends before a live frame does
+	Problems       []string // descriptions of each inconsistency found
+}
+
+// Verify walks the queue file like HealthCheck, without modifying it, but
+// reports everything it finds instead of stopping at the first problem
+//
+// The returned error is reserved for I/O errors that prevent the file from
+// being inspected; a damaged file yields a report with Consistent unset.
+func (ls *Queue) Verify() (VerifyReport, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	report := VerifyReport{FirstBadOffset: -1}
+	problem := func(format string, args ...interface{}) {
+		report.Problems = append(report.Problems, fmt.Sprintf(format, args...))
+	}
+
+	header, _, err := ls.readHeader()
+	if err != nil {
+		problem("read header: %v", err)
+	} else if header != ls.header {
+		problem("header on disk %+v does not match cached header %+v", header, ls.header)
+	}
+
+	if err := ls.checkHeader(); err != nil {
+		problem("%v", err)
+	}
+
+	h := ls.header
+	pos := h.headPosition
+	wrapped := ls.isWrapped()
+	for i := uint32(0); i < h.queueSize; i++ {
+		if wrapped && pos == h.wrapPosition {
+			pos = ls.dataStart()
+			wrapped = false
+		}
+
+		_, frameLength, err := ls.readElement(pos, h.fileLength)
+		var truncated *TruncatedElementError
+		switch {
+		case err == nil && wrapped && pos+frameLength > h.wrapPosition:
+			err = fmt.Errorf("frame length %d crosses wrap position %d", frameLength, h.wrapPosition)
+		case errors.As(err, &truncated):
+			overhead := uint32(len(ls.framer.Frame(nil)))
+			report.Truncated = truncated.Length == 0 ||
+				uint64(pos)+uint64(overhead)+uint64(truncated.Length) <= uint64(h.fileLength)
+		case isIOError(err):
+			return report, err
+		}
+		if err != nil {
+			report.FirstBadOffset = int64(pos)
+			problem("element %d at %d: %v", i, pos, err)
+			break
+		}
+
+		report.Frames++
+		pos += frameLength
+	}
+
+	if report.FirstBadOffset == -1 {
+		if wrapped && h.queueSize > 0 {
+			problem("elements end at %d without reaching wrap position %d", pos, h.wrapPosition)
+		} else if pos != h.tailPosition {
+			problem("elements end at %d but tail position is %d", pos, h.tailPosition)
+		}
+	}
+
+	report.Consistent = len(report.Problems) == 0
+	return report, nil
+}
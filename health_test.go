@@ -0,0 +1,138 @@
+package queue
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthCheck(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("healthy queue", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithCapacity(headerLength+64))
+		assert.Nil(q.HealthCheck())
+
+		for i := 0; i < 3; i++ {
+			assert.Nil(q.Enqueue(nBytes(16)))
+		}
+		assert.Nil(q.HealthCheck())
+
+		// wrap the queue around the end of the buffer
+		_, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Nil(q.Enqueue(nBytes(16)))
+		assert.True(q.isWrapped())
+		assert.Nil(q.HealthCheck())
+	})
+
+	t.Run("cached header differs from disk", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+		assert.Nil(q.Enqueue([]byte("hello")))
+
+		q.header.queueSize++
+		assert.Error(q.HealthCheck())
+	})
+
+	t.Run("element count disagrees with the frame chain", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+		assert.Nil(q.Enqueue([]byte("hello")))
+		assert.Nil(q.Enqueue([]byte("world")))
+
+		q.header.queueSize = 1
+		assert.Nil(q.syncHeader())
+		assert.EqualError(q.HealthCheck(), fmt.Sprintf("elements end at %d but tail position is %d", headerLength+9, headerLength+18))
+	})
+
+	t.Run("frame length runs past the buffer", func(t *testing.T) {
+		m := NewMemBuffer()
+		q := NewQueue(m)
+		assert.Nil(q.Enqueue([]byte("hello")))
+
+		binary.BigEndian.PutUint32(m.Bytes()[headerLength:], 1<<20)
+		assert.Error(q.HealthCheck())
+	})
+
+	t.Run("tail outside the buffer", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+
+		q.header.tailPosition = q.header.fileLength + 1
+		assert.Nil(q.syncHeader())
+		assert.Error(q.HealthCheck())
+	})
+}
+
+func TestVerify(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("healthy queue", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithCapacity(headerLength+64))
+		for i := 0; i < 3; i++ {
+			assert.Nil(q.Enqueue(nBytes(16)))
+		}
+		_, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Nil(q.Enqueue(nBytes(16)))
+		assert.True(q.isWrapped())
+
+		report, err := q.Verify()
+		assert.Nil(err)
+		assert.Equal(VerifyReport{Frames: 3, FirstBadOffset: -1, Consistent: true}, report)
+	})
+
+	t.Run("truncated file", func(t *testing.T) {
+		m := NewMemBuffer()
+		q := NewQueue(m)
+		assert.Nil(q.Enqueue([]byte("hello")))
+		assert.Nil(q.Enqueue([]byte("world")))
+
+		// cut the second element short
+		assert.Nil(m.Truncate(int64(headerLength + 9 + 6)))
+
+		report, err := q.Verify()
+		assert.Nil(err)
+		assert.Equal(1, report.Frames)
+		assert.Equal(int64(headerLength+9), report.FirstBadOffset)
+		assert.True(report.Truncated)
+		assert.False(report.Consistent)
+		assert.Len(report.Problems, 1)
+	})
+
+	t.Run("corrupt frame in the middle of the chain", func(t *testing.T) {
+		m := NewMemBuffer()
+		q := NewQueue(m)
+		for _, v := range []string{"one", "two", "three"} {
+			assert.Nil(q.Enqueue([]byte(v)))
+		}
+
+		binary.BigEndian.PutUint32(m.Bytes()[headerLength+7:], 1<<30)
+
+		report, err := q.Verify()
+		assert.Nil(err)
+		assert.Equal(1, report.Frames)
+		assert.Equal(int64(headerLength+7), report.FirstBadOffset)
+		assert.False(report.Truncated)
+		assert.False(report.Consistent)
+
+		// the file is left as it was
+		assert.Equal(3, q.Len())
+	})
+
+	t.Run("size disagrees with the frame chain", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+		assert.Nil(q.Enqueue([]byte("hello")))
+		assert.Nil(q.Enqueue([]byte("world")))
+
+		q.header.queueSize = 1
+		assert.Nil(q.syncHeader())
+
+		report, err := q.Verify()
+		assert.Nil(err)
+		assert.Equal(1, report.Frames)
+		assert.Equal(int64(-1), report.FirstBadOffset)
+		assert.False(report.Consistent)
+		assert.Equal([]string{fmt.Sprintf("elements end at %d but tail position is %d", headerLength+9, headerLength+18)}, report.Problems)
+	})
+}
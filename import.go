@@ -0,0 +1,91 @@
+package queue
+
+import (
+	"errors"
+	"io"
+)
+
+// ImportFrom enqueues every element read from r, which holds a sequence of
+// frames each made of a 4-byte big-endian length followed by the payload,
+// and returns the number of elements imported
+//
+// Importing stops without error at the end of r. It stops with an error,
+// such as ErrQueueFull, at the first element that cannot be read or
+// enqueued; the elements before it remain in the queue.
+func (ls *Queue) ImportFrom(r io.Reader) (int, error) {
+	var framer lengthPrefixFramer
+
+	count := 0
+	for {
+		v, err := framer.Unframe(r)
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+
+		if err := ls.Enqueue(v); err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+// ExportTo writes every live element to w in FIFO order, in the format
+// read by ImportFrom, and returns the number of elements written
+//
+// The queue is left unchanged.
+func (ls *Queue) ExportTo(w io.Writer) (int, error) {
+	var framer lengthPrefixFramer
+
+	count := 0
+	err := ls.ForEach(func(v []byte) error {
+		if _, err := w.Write(framer.Frame(v)); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+
+	return count, err
+}
+
+// MoveTo dequeues every element and enqueues it to dst in FIFO order,
+// returning the number of elements moved
+//
+// Each element is enqueued to dst before it is removed from the queue, so
+// a failure part way through never loses an element; at worst the element
+// being moved is left in both queues. Moving stops with the error from dst,
+// such as ErrQueueFull, at the first element dst does not accept, leaving
+// it and the elements behind it in the queue. MoveTo holds the lock of the
+// queue throughout, so moves between two queues in opposite directions
+// must not run concurrently.
+func (ls *Queue) MoveTo(dst *Queue) (int, error) {
+	if dst == ls {
+		return 0, errors.New("cannot move elements to the same queue")
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	count := 0
+	for {
+		if ls.unread() == 0 {
+			return count, nil
+		}
+
+		var enqueueErr error
+		_, ok, err := ls.dequeueIf(func(v []byte) bool {
+			enqueueErr = dst.Enqueue(v)
+			return enqueueErr == nil
+		})
+		if err != nil {
+			return count, err
+		}
+		if !ok {
+			return count, enqueueErr
+		}
+		count++
+	}
+}
@@ -0,0 +1,154 @@
+package queue
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportFrom(t *testing.T) {
+	assert := assert.New(t)
+
+	values := [][]byte{[]byte("a"), []byte("bc"), []byte("def")}
+	dump := func() *bytes.Buffer {
+		var buf bytes.Buffer
+		for _, v := range values {
+			buf.Write(lengthPrefixFramer{}.Frame(v))
+		}
+		return &buf
+	}
+
+	t.Run("imports every frame", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+
+		n, err := q.ImportFrom(dump())
+		assert.Nil(err)
+		assert.Equal(3, n)
+
+		elements, err := q.Elements()
+		assert.Nil(err)
+		assert.Equal(values, elements)
+	})
+
+	t.Run("stops when the queue is full", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithCapacity(headerLength+12))
+
+		n, err := q.ImportFrom(dump())
+		assert.Equal(ErrQueueFull, err)
+		assert.Equal(2, n)
+		assert.Equal(2, q.Len())
+	})
+
+	t.Run("truncated dump", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+
+		buf := dump()
+		buf.Truncate(buf.Len() - 1)
+
+		n, err := q.ImportFrom(buf)
+		assert.Equal(2, n)
+		assert.True(errors.Is(err, io.ErrUnexpectedEOF))
+	})
+}
+
+func TestExportTo(t *testing.T) {
+	assert := assert.New(t)
+
+	q := NewQueue(NewMemBuffer(), WithCapacity(headerLength+32))
+
+	// wrap the queue so that the export crosses the end of the buffer
+	assert.Nil(q.Enqueue(nBytes(12)))
+	assert.Nil(q.Enqueue(nBytes(4)))
+	_, err := q.Dequeue()
+	assert.Nil(err)
+	for _, v := range []string{"a", "b", "c"} {
+		assert.Nil(q.Enqueue([]byte(v)))
+	}
+	assert.True(q.isWrapped())
+
+	header := q.header
+	var buf bytes.Buffer
+	n, err := q.ExportTo(&buf)
+	assert.Nil(err)
+	assert.Equal(4, n)
+	assert.Equal(header, q.header)
+
+	restored := NewQueue(NewMemBuffer())
+	n, err = restored.ImportFrom(&buf)
+	assert.Nil(err)
+	assert.Equal(4, n)
+
+	expected, err := q.Elements()
+	assert.Nil(err)
+	actual, err := restored.Elements()
+	assert.Nil(err)
+	assert.Equal(expected, actual)
+}
+
+func TestMoveTo(t *testing.T) {
+	assert := assert.New(t)
+
+	values := [][]byte{[]byte("a"), []byte("bc"), []byte("def")}
+	newSource := func() *Queue {
+		q := NewQueue(NewMemBuffer())
+		for _, v := range values {
+			assert.Nil(q.Enqueue(v))
+		}
+		return q
+	}
+
+	t.Run("moves every element", func(t *testing.T) {
+		src, dst := newSource(), NewQueue(NewMemBuffer())
+		assert.Nil(dst.Enqueue([]byte("z")))
+
+		n, err := src.MoveTo(dst)
+		assert.Nil(err)
+		assert.Equal(3, n)
+		assert.Equal(0, src.Len())
+
+		elements, err := dst.Elements()
+		assert.Nil(err)
+		assert.Equal(append([][]byte{[]byte("z")}, values...), elements)
+	})
+
+	t.Run("stops when the destination is full", func(t *testing.T) {
+		src, dst := newSource(), NewQueue(NewMemBuffer(), WithCapacity(headerLength+12))
+
+		n, err := src.MoveTo(dst)
+		assert.Equal(ErrQueueFull, err)
+		assert.Equal(2, n)
+
+		moved, err := dst.Elements()
+		assert.Nil(err)
+		assert.Equal(values[:2], moved)
+
+		remaining, err := src.Elements()
+		assert.Nil(err)
+		assert.Equal(values[2:], remaining)
+	})
+
+	t.Run("failed enqueue keeps the element", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+		rws := newFlakyReadWriteSeeker(f)
+		src, dst := newSource(), NewQueue(rws)
+
+		rws.failWrites(1)
+		n, err := src.MoveTo(dst)
+		assert.NotNil(err)
+		assert.Equal(0, n)
+		assert.Equal(3, src.Len())
+		assert.Equal(0, dst.Len())
+	})
+
+	t.Run("same queue", func(t *testing.T) {
+		q := newSource()
+		_, err := q.MoveTo(q)
+		assert.NotNil(err)
+		assert.Equal(3, q.Len())
+	})
+}
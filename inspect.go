@@ -0,0 +1,463 @@
+package queue
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"sync/atomic"
+)
+
+// ReadElementAt returns the payload of the element whose frame starts at
+// offset, as returned by EnqueueAt, without removing it from the queue
+//
+// The offset is not checked against the live region of the queue, so
+// reading at the offset of an element that has already been dequeued
+// returns whatever now occupies that space.
+func (ls *Queue) ReadElementAt(offset uint32) ([]byte, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if offset < ls.dataStart() || offset >= ls.header.fileLength {
+		return nil, fmt.Errorf("read at %d: %w", offset, ErrInvalidOffset)
+	}
+
+	body, _, err := ls.readElement(offset, ls.header.fileLength)
+	if err != nil {
+		return nil, err
+	}
+
+	return ls.decodeElement(body)
+}
+
+// Elements returns the payloads of all live elements in FIFO order
+// without removing them from the queue
+func (ls *Queue) Elements() ([][]byte, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	return ls.elements()
+}
+
+func (ls *Queue) elements() ([][]byte, error) {
+	elements := make([][]byte, 0, ls.unread())
+
+	var decodeErr error
+	err := ls.walkUnread(func(_, _ uint32, body []byte) bool {
+		var v []byte
+		v, decodeErr = ls.decodeElement(body)
+		elements = append(elements, v)
+		return decodeErr == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+
+	return elements, nil
+}
+
+// ContentHash returns a SHA-256 digest of the live payloads in FIFO order,
+// each preceded by its length as an 8-byte big-endian integer
+//
+// The digest depends only on the payloads and their order, so queues with
+// the same contents hash alike however their elements are laid out in the
+// file, and whatever their compression, sequence numbers, or framing.
+func (ls *Queue) ContentHash() ([]byte, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	h := sha256.New()
+	var length [8]byte
+	var decodeErr error
+	err := ls.walk(func(_, _ uint32, body []byte) bool {
+		var v []byte
+		if v, decodeErr = ls.decodeElement(body); decodeErr != nil {
+			return false
+		}
+		binary.BigEndian.PutUint64(length[:], uint64(len(v)))
+		h.Write(length[:])
+		h.Write(v)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+
+	return h.Sum(nil), nil
+}
+
+// ElementSizes returns the stored length in bytes of each live element in
+// FIFO order, excluding framing
+//
+// The stored length equals the payload length unless elements carry a
+// compression or spillover flag, a sequence number, a timestamp, or a tag
+// block. With the default framing only the length prefix of each element
+// is read.
+func (ls *Queue) ElementSizes() ([]uint32, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	sizes := make([]uint32, 0, ls.header.queueSize)
+
+	framer, ok := ls.framer.(lengthPrefixFramer)
+	if !ok {
+		err := ls.walk(func(_, _ uint32, body []byte) bool {
+			sizes = append(sizes, uint32(len(body)))
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		return sizes, nil
+	}
+
+	pos := ls.header.headPosition
+	wrapped := ls.isWrapped()
+	var prefix [4]byte
+	for i := uint32(0); i < ls.header.queueSize; i++ {
+		if wrapped && pos == ls.header.wrapPosition {
+			pos = ls.dataStart()
+			wrapped = false
+		}
+
+		if err := ls.readAt(prefix[:], int64(pos)); err != nil {
+			return nil, ioError(OpElementRead, int64(pos), err)
+		}
+
+		length := framer.byteOrder().Uint32(prefix[:])
+		if err := ls.checkLength(pos, length); err != nil {
+			return nil, err
+		}
+		if int64(pos)+int64(len(prefix))+int64(length) > int64(ls.header.fileLength) {
+			return nil, &TruncatedElementError{Offset: pos, Length: length}
+		}
+
+		sizes = append(sizes, length)
+		pos += ls.align(uint32(len(prefix)) + length)
+	}
+
+	return sizes, nil
+}
+
+// Len returns the number of elements in the queue
+func (ls *Queue) Len() int {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	return int(ls.unread())
+}
+
+// RawBytes returns a copy of the bytes of the backing store from the start
+// of the file header to the end of the buffer, for diagnostics
+//
+// Bytes past the end of a backing store that has not grown to its full
+// capacity are not included. When the backing store does not support
+// positioned reads, its offset is restored afterwards.
+func (ls *Queue) RawBytes() ([]byte, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if ls.ra == nil {
+		atomic.AddUint64(&ls.stats.seeks, 1)
+		pos, err := ls.rws.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, &IOError{Op: OpSeek, Offset: 0, Err: err}
+		}
+		defer ls.seek(pos)
+	}
+
+	r, err := ls.readerAt(0, int64(ls.header.fileLength))
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, ioError(OpElementRead, 0, err)
+	}
+
+	return b, nil
+}
+
+// IsWrapped reports whether the live elements straddle the end of the
+// buffer, with the newest elements written back at the front
+func (ls *Queue) IsWrapped() bool {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	return ls.isWrapped()
+}
+
+// FreeSpace returns the contiguous bytes available for the next element
+// frame at the tail of the queue and at the front of the buffer
+//
+// An enqueue succeeds without evicting elements if its frame fits in
+// either region; it is written at the tail when it fits there and wraps
+// to the front of the buffer otherwise. A frame is the payload plus its
+// framing, which is 4 bytes with the default framing.
+func (ls *Queue) FreeSpace() (tailFree, headFree uint32) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	return ls.tailSpaceAvailable(), ls.headSpaceAvailable()
+}
+
+// MaxEnqueueableSize returns the length of the largest payload that could
+// be enqueued right now without evicting elements, or 0 if no element fits
+//
+// The payload must fit, with its framing, in the larger of the contiguous
+// regions reported by FreeSpace, or anywhere in the element region once
+// the queue is empty. Compressed payloads are assumed to keep their size,
+// and payloads that would be spilled are not considered.
+func (ls *Queue) MaxEnqueueableSize() uint32 {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	var space uint32
+	switch {
+	case ls.maxElements != 0 && ls.header.queueSize >= ls.maxElements:
+		return 0
+	case !ls.appendOnly && ls.header.queueSize == 0:
+		space = ls.header.fileLength - ls.dataStart()
+	default:
+		space = ls.tailSpaceAvailable()
+		if head := ls.headSpaceAvailable(); !ls.appendOnly && head > space {
+			space = head
+		}
+	}
+
+	if size := ls.header.elementSize; size != 0 {
+		if ls.frameLengthFor(size) <= space {
+			return size
+		}
+		return 0
+	}
+	if ls.frameLengthFor(0) > space {
+		return 0
+	}
+
+	// frames grow with their payload, so search for the first payload
+	// length that no longer fits
+	n := sort.Search(int(space)+1, func(n int) bool {
+		return ls.frameLengthFor(uint32(n)) > space
+	})
+	return uint32(n - 1)
+}
+
+// frameLengthFor returns the length of the frame holding an uncompressed
+// payload of payloadLength bytes without tags
+func (ls *Queue) frameLengthFor(payloadLength uint32) uint32 {
+	bodyLength := payloadLength
+	if ls.flagged() {
+		bodyLength++
+	}
+	if ls.tagged() {
+		bodyLength++
+	}
+	bodyLength += ls.prefixLength()
+	return uint32(len(ls.pad(ls.framer.Frame(make([]byte, bodyLength)))))
+}
+
+// RemainingCapacityFor returns how many more elements with payloads of
+// elemSize bytes could be enqueued, without evicting elements, into the
+// space currently free
+//
+// Each element is counted with its framing, and placed by the same rules
+// Enqueue uses to decide whether an element fits at the tail or must wrap
+// to the front of the buffer. Compressed payloads are assumed to keep
+// their size.
+func (ls *Queue) RemainingCapacityFor(elemSize uint32) int {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	frameLength := ls.frameLengthFor(elemSize)
+	if frameLength == 0 || frameLength > ls.header.fileLength-ls.dataStart() {
+		return 0
+	}
+
+	// simulate enqueues against the cached header
+	original := ls.header
+	defer func() { ls.header = original }()
+
+	n := 0
+	for {
+		header, ok := ls.reserve(frameLength)
+		if !ok {
+			return n
+		}
+		header.tailPosition += frameLength
+		header.queueSize++
+		ls.header = header
+		n++
+	}
+}
+
+// AvgElementSize returns the mean payload length in bytes of the live
+// elements, or 0 if the queue is empty
+func (ls *Queue) AvgElementSize() (float64, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if ls.header.queueSize == 0 {
+		return 0, nil
+	}
+
+	var total int
+	var decodeErr error
+	err := ls.walk(func(_, _ uint32, body []byte) bool {
+		var v []byte
+		v, decodeErr = ls.decodeElement(body)
+		total += len(v)
+		return decodeErr == nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if decodeErr != nil {
+		return 0, decodeErr
+	}
+
+	return float64(total) / float64(ls.header.queueSize), nil
+}
+
+// Peek returns the item at the front of the queue without removing it
+func (ls *Queue) Peek() ([]byte, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if ls.unread() == 0 {
+		return nil, ErrQueueEmpty
+	}
+
+	if ls.consumer != nil {
+		body, _, _, err := ls.consumer.peek()
+		if err != nil {
+			return nil, err
+		}
+		return ls.decodeElement(body)
+	}
+
+	body, _, err := ls.readElement(ls.header.headPosition, ls.header.fileLength)
+	if err != nil {
+		return nil, err
+	}
+
+	return ls.decodeElement(body)
+}
+
+// PeekAt returns the item index places from the front of the queue without
+// removing it, so that PeekAt(0) is equivalent to Peek
+//
+// Like PeekTail, PeekAt walks the elements in front of the requested one.
+func (ls *Queue) PeekAt(index int) ([]byte, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if index < 0 || index >= int(ls.unread()) {
+		return nil, fmt.Errorf("peek at %d of %d elements: %w", index, ls.unread(), ErrIndexOutOfRange)
+	}
+
+	var target []byte
+	i := 0
+	err := ls.walkUnread(func(_, _ uint32, body []byte) bool {
+		if i == index {
+			target = body
+			return false
+		}
+		i++
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ls.decodeElement(target)
+}
+
+// PeekTail returns the most recently enqueued item without removing it
+//
+// Element frames only link forward, so PeekTail walks every live element
+// from the head and its cost grows linearly with the length of the queue.
+func (ls *Queue) PeekTail() ([]byte, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if ls.unread() == 0 {
+		return nil, ErrQueueEmpty
+	}
+
+	var last []byte
+	err := ls.walkUnread(func(_, _ uint32, body []byte) bool {
+		last = body
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ls.decodeElement(last)
+}
+
+// ForEach calls fn with the payload of each live element in FIFO order
+// without removing them, stopping at the first error returned by fn
+func (ls *Queue) ForEach(fn func([]byte) error) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	var fnErr error
+	err := ls.walkUnread(func(_, _ uint32, body []byte) bool {
+		var v []byte
+		if v, fnErr = ls.decodeElement(body); fnErr != nil {
+			return false
+		}
+		fnErr = fn(v)
+		return fnErr == nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return fnErr
+}
+
+// Find returns the index and payload of the first live element, counting
+// from the head, for which pred returns true, or -1 and a nil payload if
+// no element matches
+//
+// Find stops at the first match and does not remove any elements.
+func (ls *Queue) Find(pred func([]byte) bool) (int, []byte, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	index, i := -1, 0
+	var found []byte
+	var decodeErr error
+	err := ls.walkUnread(func(_, _ uint32, body []byte) bool {
+		var v []byte
+		if v, decodeErr = ls.decodeElement(body); decodeErr != nil {
+			return false
+		}
+		if pred(v) {
+			index, found = i, v
+			return false
+		}
+		i++
+		return true
+	})
+	if err != nil {
+		return -1, nil, err
+	}
+	if decodeErr != nil {
+		return -1, nil, decodeErr
+	}
+
+	return index, found, nil
+}
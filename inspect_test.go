@@ -0,0 +1,695 @@
+package queue
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadElementAt(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("reads elements out of order without dequeuing", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f)
+
+		values := [][]byte{[]byte("a"), []byte("bc"), []byte("def")}
+		offsets := make([]uint32, len(values))
+		for i, v := range values {
+			offsets[i], err = q.EnqueueAt(v)
+			assert.Nil(err)
+		}
+
+		header := q.header
+		for _, i := range []int{2, 0, 1} {
+			v, err := q.ReadElementAt(offsets[i])
+			assert.Nil(err)
+			assert.Equal(values[i], v)
+		}
+		assert.Equal(header, q.header)
+
+		front, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Equal(values[0], front)
+	})
+
+	t.Run("rejects offsets outside the element region", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f)
+		assert.Nil(q.Enqueue([]byte("a")))
+
+		_, err = q.ReadElementAt(0)
+		assert.True(errors.Is(err, ErrInvalidOffset))
+
+		_, err = q.ReadElementAt(q.header.fileLength)
+		assert.True(errors.Is(err, ErrInvalidOffset))
+	})
+
+	t.Run("rejects lengths running past the buffer", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f)
+		offset, err := q.EnqueueAt([]byte("a"))
+		assert.Nil(err)
+
+		_, err = f.WriteAt([]byte{0xff, 0xff, 0xff, 0xff}, int64(offset))
+		assert.Nil(err)
+
+		_, err = q.ReadElementAt(offset)
+		assert.NotNil(err)
+	})
+}
+
+func TestElements(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("empty queue", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f)
+
+		elements, err := q.Elements()
+		assert.Nil(err)
+		assert.Empty(elements)
+	})
+
+	t.Run("matches enqueued minus dequeued values", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f)
+
+		var model [][]byte
+		for i := 0; i < 200; i++ {
+			v := nBytes(i%50 + 1)
+			if q.Enqueue(v) == ErrQueueFull {
+				_, err := q.Dequeue()
+				assert.Nil(err)
+				model = model[1:]
+				continue
+			}
+			model = append(model, v)
+
+			if i%3 == 0 {
+				_, err := q.Dequeue()
+				assert.Nil(err)
+				model = model[1:]
+			}
+
+			header := q.header
+			elements, err := q.Elements()
+			assert.Nil(err)
+			assert.Equal(model, elements)
+			assert.Equal(header, q.header)
+		}
+	})
+
+	t.Run("consumer offset", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithConsumerOffset())
+		for _, v := range []string{"a", "bc", "def"} {
+			assert.Nil(q.Enqueue([]byte(v)))
+		}
+		_, err := q.Dequeue()
+		assert.Nil(err)
+
+		elements, err := q.Elements()
+		assert.Nil(err)
+		assert.Equal([][]byte{[]byte("bc"), []byte("def")}, elements)
+	})
+}
+
+func TestElementSizes(t *testing.T) {
+	assert := assert.New(t)
+
+	for name, opts := range map[string][]Option{
+		"default": nil,
+		"varint":  {WithVarintFraming()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			q := NewQueue(NewMemBuffer(), append(opts, WithCapacity(headerLength+32))...)
+
+			sizes, err := q.ElementSizes()
+			assert.Nil(err)
+			assert.Empty(sizes)
+
+			// wrap the queue so that the walk crosses the end of the buffer
+			assert.Nil(q.Enqueue(nBytes(12)))
+			assert.Nil(q.Enqueue(nBytes(4)))
+			_, err = q.Dequeue()
+			assert.Nil(err)
+			assert.Nil(q.Enqueue(nBytes(2)))
+			assert.Nil(q.Enqueue(nBytes(1)))
+
+			header := q.header
+			sizes, err = q.ElementSizes()
+			assert.Nil(err)
+			assert.Equal([]uint32{4, 2, 1}, sizes)
+			assert.Equal(header, q.header)
+		})
+	}
+}
+
+func TestRawBytes(t *testing.T) {
+	assert := assert.New(t)
+
+	for name, wrap := range map[string]func(io.ReadWriteSeeker) io.ReadWriteSeeker{
+		"positioned": func(f io.ReadWriteSeeker) io.ReadWriteSeeker { return f },
+		"seek":       func(f io.ReadWriteSeeker) io.ReadWriteSeeker { return seekOnly{f} },
+	} {
+		t.Run(name, func(t *testing.T) {
+			f := NewMemBuffer()
+			q := NewQueue(wrap(f), WithCapacity(headerLength+64), WithPreallocate())
+			assert.Nil(q.Enqueue([]byte("hello")))
+
+			_, err := f.Seek(7, io.SeekStart)
+			assert.Nil(err)
+
+			raw, err := q.RawBytes()
+			assert.Nil(err)
+			assert.Equal(f.Bytes(), raw)
+			assert.Len(raw, int(headerLength+64))
+
+			slot := int64(q.headerSeq%2) * int64(headerSlotLength)
+			assert.Equal(encodeHeaderSlot(q.header, q.headerSeq)[:16], raw[slot:slot+16])
+
+			if name == "seek" {
+				pos, err := f.Seek(0, io.SeekCurrent)
+				assert.Nil(err)
+				assert.Equal(int64(7), pos)
+			}
+		})
+	}
+}
+
+func TestIsWrapped(t *testing.T) {
+	assert := assert.New(t)
+
+	q := NewQueue(NewMemBuffer(), WithCapacity(headerLength+32))
+	assert.False(q.IsWrapped())
+
+	assert.Nil(q.Enqueue(nBytes(12)))
+	assert.Nil(q.Enqueue(nBytes(8)))
+	assert.False(q.IsWrapped())
+
+	// the next element does not fit after the tail, so it wraps
+	_, err := q.Dequeue()
+	assert.Nil(err)
+	assert.Nil(q.Enqueue(nBytes(10)))
+	assert.True(q.IsWrapped())
+
+	// consuming the elements at the end of the buffer unwraps the queue
+	_, err = q.Dequeue()
+	assert.Nil(err)
+	assert.False(q.IsWrapped())
+
+	_, err = q.Dequeue()
+	assert.Nil(err)
+	assert.False(q.IsWrapped())
+	assert.Equal(0, q.Len())
+}
+
+func TestFreeSpace(t *testing.T) {
+	assert := assert.New(t)
+
+	fits := func(q *Queue, payload int) bool {
+		tailFree, headFree := q.FreeSpace()
+		frame := uint32(4 + payload)
+		return frame <= tailFree || frame <= headFree
+	}
+
+	// leaves 8 bytes free at the tail and 12 at the front
+	fragmented := func() *Queue {
+		q := NewQueue(NewMemBuffer(), WithCapacity(headerLength+32))
+		assert.Nil(q.Enqueue(nBytes(8)))
+		assert.Nil(q.Enqueue(nBytes(8)))
+		_, err := q.Dequeue()
+		assert.Nil(err)
+		return q
+	}
+
+	tailFree, headFree := NewQueue(NewMemBuffer(), WithCapacity(headerLength+32)).FreeSpace()
+	assert.Equal(uint32(32), tailFree)
+	assert.Equal(uint32(0), headFree)
+
+	q := fragmented()
+	tailFree, headFree = q.FreeSpace()
+	assert.Equal(uint32(8), tailFree)
+	assert.Equal(uint32(12), headFree)
+
+	for payload := 0; payload <= 12; payload++ {
+		q := fragmented()
+		want := fits(q, payload)
+		err := q.Enqueue(nBytes(payload))
+		if want {
+			assert.Nil(err, "payload %d", payload)
+		} else {
+			assert.Equal(ErrQueueFull, err, "payload %d", payload)
+		}
+	}
+
+	// once wrapped, both regions are the gap between the tail and the head
+	assert.Nil(q.Enqueue(nBytes(6)))
+	tailFree, headFree = q.FreeSpace()
+	assert.Equal(uint32(2), tailFree)
+	assert.Equal(uint32(2), headFree)
+	assert.False(fits(q, 0))
+	assert.Equal(ErrQueueFull, q.Enqueue(nBytes(0)))
+}
+
+func TestMaxEnqueueableSize(t *testing.T) {
+	assert := assert.New(t)
+
+	fill := func(q *Queue) *Queue {
+		for i := 0; i < 4; i++ {
+			assert.Nil(q.Enqueue(nBytes(16)))
+		}
+		return q
+	}
+	layouts := map[string]func() *Queue{
+		"empty": func() *Queue {
+			return NewQueue(NewMemBuffer(), WithCapacity(headerLength+100))
+		},
+		"partly full": func() *Queue {
+			return fill(NewQueue(NewMemBuffer(), WithCapacity(headerLength+100)))
+		},
+		"gap at front": func() *Queue {
+			q := fill(NewQueue(NewMemBuffer(), WithCapacity(headerLength+100)))
+			for i := 0; i < 3; i++ {
+				_, err := q.Dequeue()
+				assert.Nil(err)
+			}
+			return q
+		},
+		"wrapped": func() *Queue {
+			q := fill(NewQueue(NewMemBuffer(), WithCapacity(headerLength+100)))
+			_, err := q.Dequeue()
+			assert.Nil(err)
+			_, err = q.Dequeue()
+			assert.Nil(err)
+			assert.Nil(q.Enqueue(nBytes(30)))
+			assert.True(q.isWrapped())
+			return q
+		},
+		"aligned": func() *Queue {
+			return fill(NewQueue(NewMemBuffer(), WithCapacity(headerLength+100), WithAlignment(8)))
+		},
+		"varint framing": func() *Queue {
+			return fill(NewQueue(NewMemBuffer(), WithCapacity(headerLength+300), WithVarintFraming()))
+		},
+		"sequenced": func() *Queue {
+			return fill(NewQueue(NewMemBuffer(), WithCapacity(headerLength+200), WithSequenceNumbers()))
+		},
+		"full": func() *Queue {
+			q := NewQueue(NewMemBuffer(), WithCapacity(headerLength+100))
+			assert.Nil(q.Enqueue(nBytes(96)))
+			return q
+		},
+	}
+
+	for name, layout := range layouts {
+		q := layout()
+		size := q.MaxEnqueueableSize()
+
+		// one more byte does not fit, but the reported size does
+		err := q.Enqueue(nBytes(int(size) + 1))
+		assert.True(errors.Is(err, ErrQueueFull) || errors.Is(err, ErrElementTooLarge), name)
+		if size > 0 {
+			assert.Nil(q.Enqueue(nBytes(int(size))), name)
+		}
+	}
+
+	q := NewQueue(NewMemBuffer(), WithCapacity(headerLength+100))
+	assert.Equal(uint32(96), q.MaxEnqueueableSize())
+}
+
+func TestRemainingCapacityFor(t *testing.T) {
+	assert := assert.New(t)
+
+	layouts := map[string]func() *Queue{
+		"empty": func() *Queue {
+			return NewQueue(NewMemBuffer(), WithCapacity(headerLength+100))
+		},
+		"gap at front": func() *Queue {
+			q := NewQueue(NewMemBuffer(), WithCapacity(headerLength+100))
+			for i := 0; i < 4; i++ {
+				assert.Nil(q.Enqueue(nBytes(16)))
+			}
+			for i := 0; i < 3; i++ {
+				_, err := q.Dequeue()
+				assert.Nil(err)
+			}
+			return q
+		},
+		"wrapped": func() *Queue {
+			q := NewQueue(NewMemBuffer(), WithCapacity(headerLength+100))
+			for i := 0; i < 4; i++ {
+				assert.Nil(q.Enqueue(nBytes(16)))
+			}
+			_, err := q.Dequeue()
+			assert.Nil(err)
+			_, err = q.Dequeue()
+			assert.Nil(err)
+			assert.Nil(q.Enqueue(nBytes(30)))
+			assert.True(q.isWrapped())
+			return q
+		},
+		"aligned": func() *Queue {
+			return NewQueue(NewMemBuffer(), WithCapacity(headerLength+100), WithAlignment(8))
+		},
+	}
+
+	for name, layout := range layouts {
+		for _, size := range []uint32{0, 1, 5, 12, 40, 96, 97} {
+			q := layout()
+			estimate := q.RemainingCapacityFor(size)
+
+			enqueued := 0
+			for q.Enqueue(nBytes(int(size))) == nil {
+				enqueued++
+			}
+			assert.Equal(enqueued, estimate, "%s, size %d", name, size)
+		}
+	}
+}
+
+func TestAvgElementSize(t *testing.T) {
+	assert := assert.New(t)
+
+	q := NewQueue(NewMemBuffer())
+
+	avg, err := q.AvgElementSize()
+	assert.Nil(err)
+	assert.Equal(0.0, avg)
+
+	for _, n := range []int{1, 2, 6} {
+		assert.Nil(q.Enqueue(nBytes(n)))
+	}
+
+	header := q.header
+	avg, err = q.AvgElementSize()
+	assert.Nil(err)
+	assert.Equal(3.0, avg)
+	assert.Equal(header, q.header)
+
+	_, err = q.Dequeue()
+	assert.Nil(err)
+	avg, err = q.AvgElementSize()
+	assert.Nil(err)
+	assert.Equal(4.0, avg)
+}
+
+func TestPeek(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := ioutil.TempFile("", "test-*")
+	assert.Nil(err)
+
+	q := NewQueue(f)
+
+	_, err = q.Peek()
+	assert.Equal(ErrQueueEmpty, err)
+
+	assert.Nil(q.Enqueue([]byte("a")))
+	assert.Nil(q.Enqueue([]byte("b")))
+
+	for i := 0; i < 2; i++ {
+		front, err := q.Peek()
+		assert.Nil(err)
+		assert.Equal([]byte("a"), front)
+		assert.Equal(2, q.Len())
+	}
+
+	_, err = q.Dequeue()
+	assert.Nil(err)
+
+	front, err := q.Peek()
+	assert.Nil(err)
+	assert.Equal([]byte("b"), front)
+	assert.Equal(1, q.Len())
+}
+
+func TestPeekAt(t *testing.T) {
+	assert := assert.New(t)
+
+	q := NewQueue(NewMemBuffer(), WithCapacity(headerLength+32))
+
+	_, err := q.PeekAt(0)
+	assert.True(errors.Is(err, ErrIndexOutOfRange))
+
+	// wrap the queue so that the walk crosses the end of the buffer
+	assert.Nil(q.Enqueue(nBytes(12)))
+	assert.Nil(q.Enqueue(nBytes(4)))
+	_, err = q.Dequeue()
+	assert.Nil(err)
+
+	values := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	for _, v := range values {
+		assert.Nil(q.Enqueue(v))
+	}
+	assert.True(q.isWrapped())
+
+	front, err := q.Peek()
+	assert.Nil(err)
+	values = append([][]byte{front}, values...)
+
+	for i, v := range values {
+		got, err := q.PeekAt(i)
+		assert.Nil(err)
+		assert.Equal(v, got)
+	}
+	assert.Equal(len(values), q.Len())
+
+	for _, index := range []int{-1, len(values)} {
+		_, err := q.PeekAt(index)
+		assert.True(errors.Is(err, ErrIndexOutOfRange))
+	}
+
+	t.Run("consumer offset", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithConsumerOffset(), WithOverwriteOldest(), WithCapacity(headerLength+cursorSlotLength+32))
+
+		// wrap the queue and leave the consumer in the front of the buffer
+		assert.Nil(q.Enqueue(nBytes(12)))
+		assert.Nil(q.Enqueue(nBytes(4)))
+		for _, v := range []string{"a", "b", "c", "d"} {
+			assert.Nil(q.Enqueue([]byte(v)))
+		}
+		assert.True(q.isWrapped())
+		for i := 0; i < 3; i++ {
+			_, err := q.Dequeue()
+			assert.Nil(err)
+		}
+
+		n := q.Len()
+		peeked := make([][]byte, n)
+		for i := range peeked {
+			peeked[i], err = q.PeekAt(i)
+			assert.Nil(err)
+		}
+		_, err = q.PeekAt(n)
+		assert.True(errors.Is(err, ErrIndexOutOfRange))
+
+		front, err := q.Peek()
+		assert.Nil(err)
+		assert.Equal(front, peeked[0])
+
+		for _, v := range peeked {
+			got, err := q.Dequeue()
+			assert.Nil(err)
+			assert.Equal(v, got)
+		}
+		assert.Equal(0, q.Len())
+	})
+}
+
+func TestPeekTail(t *testing.T) {
+	assert := assert.New(t)
+
+	q := NewQueue(NewMemBuffer(), WithCapacity(headerLength+32))
+
+	_, err := q.PeekTail()
+	assert.Equal(ErrQueueEmpty, err)
+
+	for _, v := range []string{"a", "b", "c"} {
+		assert.Nil(q.Enqueue([]byte(v)))
+
+		back, err := q.PeekTail()
+		assert.Nil(err)
+		assert.Equal([]byte(v), back)
+	}
+	assert.Equal(3, q.Len())
+
+	// the newest element sits at the front of the buffer once wrapped
+	for i := 0; i < 2; i++ {
+		_, err = q.Dequeue()
+		assert.Nil(err)
+	}
+	assert.Nil(q.Enqueue(nBytes(12)))
+	assert.Nil(q.Enqueue([]byte("d")))
+	assert.True(q.isWrapped())
+
+	back, err := q.PeekTail()
+	assert.Nil(err)
+	assert.Equal([]byte("d"), back)
+
+	t.Run("consumer offset", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithConsumerOffset())
+		for _, v := range []string{"a", "b"} {
+			assert.Nil(q.Enqueue([]byte(v)))
+		}
+		_, err := q.Dequeue()
+		assert.Nil(err)
+
+		back, err := q.PeekTail()
+		assert.Nil(err)
+		assert.Equal([]byte("b"), back)
+
+		_, err = q.Dequeue()
+		assert.Nil(err)
+		_, err = q.PeekTail()
+		assert.Equal(ErrQueueEmpty, err)
+	})
+}
+
+func TestForEach(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := ioutil.TempFile("", "test-*")
+	assert.Nil(err)
+
+	q := NewQueue(f)
+	for _, v := range []string{"a", "b", "c"} {
+		assert.Nil(q.Enqueue([]byte(v)))
+	}
+
+	stop := errors.New("stop")
+	var seen []string
+	err = q.ForEach(func(v []byte) error {
+		seen = append(seen, string(v))
+		if string(v) == "b" {
+			return stop
+		}
+		return nil
+	})
+	assert.Equal(stop, err)
+	assert.Equal([]string{"a", "b"}, seen)
+	assert.Equal(3, q.Len())
+
+	t.Run("consumer offset", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithConsumerOffset())
+		for _, v := range []string{"a", "b", "c"} {
+			assert.Nil(q.Enqueue([]byte(v)))
+		}
+		_, err := q.Dequeue()
+		assert.Nil(err)
+
+		var seen []string
+		assert.Nil(q.ForEach(func(v []byte) error {
+			seen = append(seen, string(v))
+			return nil
+		}))
+		assert.Equal([]string{"b", "c"}, seen)
+	})
+}
+
+func TestFind(t *testing.T) {
+	assert := assert.New(t)
+
+	q := NewQueue(NewMemBuffer())
+	for _, v := range []string{"apple", "banana", "cherry", "blueberry"} {
+		assert.Nil(q.Enqueue([]byte(v)))
+	}
+
+	index, v, err := q.Find(func(v []byte) bool { return bytes.Contains(v, []byte("berry")) })
+	assert.Nil(err)
+	assert.Equal(3, index)
+	assert.Equal([]byte("blueberry"), v)
+
+	index, v, err = q.Find(func(v []byte) bool { return bytes.Contains(v, []byte("an")) })
+	assert.Nil(err)
+	assert.Equal(1, index)
+	assert.Equal([]byte("banana"), v)
+
+	index, v, err = q.Find(func(v []byte) bool { return bytes.Contains(v, []byte("grape")) })
+	assert.Nil(err)
+	assert.Equal(-1, index)
+	assert.Nil(v)
+
+	assert.Equal(4, q.Len())
+
+	t.Run("consumer offset", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithConsumerOffset())
+		for _, v := range []string{"apple", "banana", "cherry"} {
+			assert.Nil(q.Enqueue([]byte(v)))
+		}
+		_, err := q.Dequeue()
+		assert.Nil(err)
+
+		index, v, err := q.Find(func(v []byte) bool { return bytes.HasPrefix(v, []byte("a")) })
+		assert.Nil(err)
+		assert.Equal(-1, index)
+		assert.Nil(v)
+
+		index, v, err = q.Find(func(v []byte) bool { return bytes.HasPrefix(v, []byte("c")) })
+		assert.Nil(err)
+		assert.Equal(1, index)
+		assert.Equal([]byte("cherry"), v)
+	})
+}
+
+func TestContentHash(t *testing.T) {
+	assert := assert.New(t)
+
+	// a wrapped queue
+	q := NewQueue(NewMemBuffer(), WithCapacity(headerLength+64))
+	for i := 0; i < 3; i++ {
+		assert.Nil(q.Enqueue(nBytes(16)))
+	}
+	_, err := q.Dequeue()
+	assert.Nil(err)
+	assert.Nil(q.Enqueue(nBytes(16)))
+	assert.True(q.IsWrapped())
+
+	want, err := q.ContentHash()
+	assert.Nil(err)
+	assert.Len(want, 32)
+
+	// a copy laid out contiguously, compressed, and sequenced
+	var dump bytes.Buffer
+	_, err = q.ExportTo(&dump)
+	assert.Nil(err)
+	dst := NewQueue(NewMemBuffer(), WithCompression(flateCodecOrPanic()), WithSequenceNumbers())
+	_, err = dst.ImportFrom(&dump)
+	assert.Nil(err)
+	assert.False(dst.IsWrapped())
+
+	got, err := dst.ContentHash()
+	assert.Nil(err)
+	assert.Equal(want, got)
+
+	// hashing leaves the queue unchanged
+	assert.Equal(3, q.Len())
+
+	// the hash tells apart contents that concatenate alike
+	a := NewQueue(NewMemBuffer())
+	assert.Nil(a.Enqueue([]byte("ab")))
+	assert.Nil(a.Enqueue([]byte("c")))
+	b := NewQueue(NewMemBuffer())
+	assert.Nil(b.Enqueue([]byte("a")))
+	assert.Nil(b.Enqueue([]byte("bc")))
+
+	ha, err := a.ContentHash()
+	assert.Nil(err)
+	hb, err := b.ContentHash()
+	assert.Nil(err)
+	assert.NotEqual(ha, hb)
+}
@@ -0,0 +1,88 @@
+package queue
+
+import (
+	"context"
+	"io"
+)
+
+// DequeueInto removes the item at the front of the queue, copying it into
+// buf, and returns its length
+//
+// If buf is too small to hold the item, DequeueInto leaves it in the queue
+// and returns io.ErrShortBuffer along with the length of the item, so that
+// the caller can retry with a larger buffer.
+//
+// With the default framing and neither compression, spillover, sequence
+// numbers, timestamps, tags, nor a validator, the item is read straight
+// into buf without allocating.
+func (ls *Queue) DequeueInto(buf []byte) (int, error) {
+	if err := ls.pace(context.Background()); err != nil {
+		return 0, err
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	framer, ok := ls.framer.(lengthPrefixFramer)
+	if !ok || ls.flagged() || ls.tagged() || ls.prefixLength() != 0 || ls.consumer != nil || ls.validator != nil {
+		return ls.dequeueCopy(buf)
+	}
+
+	if err := ls.checkDequeue(); err != nil {
+		return 0, err
+	}
+
+	head := ls.header.headPosition
+	var prefix [4]byte
+	if err := ls.readAt(prefix[:], int64(head)); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return 0, &TruncatedElementError{Offset: head}
+		}
+		return 0, ioError(OpElementRead, int64(head), err)
+	}
+
+	length := framer.byteOrder().Uint32(prefix[:])
+	if err := ls.checkLength(head, length); err != nil {
+		return 0, err
+	}
+	if int64(head)+int64(len(prefix))+int64(length) > int64(ls.header.fileLength) {
+		return 0, &TruncatedElementError{Offset: head, Length: length}
+	}
+	if int(length) > len(buf) {
+		return int(length), io.ErrShortBuffer
+	}
+
+	body := buf[:length]
+	if err := ls.readAt(body, int64(head)+int64(len(prefix))); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return 0, &TruncatedElementError{Offset: head, Length: length}
+		}
+		return 0, ioError(OpElementRead, int64(head), err)
+	}
+
+	if err := ls.removeHead(ls.align(uint32(len(prefix)) + length)); err != nil {
+		return 0, err
+	}
+
+	ls.recordDequeue(len(body))
+
+	return len(body), nil
+}
+
+// dequeueCopy dequeues the head element into buf when elements must be
+// decoded before their length is known
+func (ls *Queue) dequeueCopy(buf []byte) (int, error) {
+	var length int
+	v, ok, err := ls.dequeueIf(func(v []byte) bool {
+		length = len(v)
+		return length <= len(buf)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return length, io.ErrShortBuffer
+	}
+
+	return copy(buf, v), nil
+}
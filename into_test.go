@@ -0,0 +1,51 @@
+package queue
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDequeueInto(t *testing.T) {
+	assert := assert.New(t)
+
+	for name, opts := range map[string][]Option{
+		"default":     nil,
+		"compression": {WithCompression(flateCodecOrPanic())},
+		"alignment":   {WithAlignment(8)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			q := NewQueue(NewMemBuffer(), opts...)
+
+			buf := make([]byte, 4)
+			_, err := q.DequeueInto(buf)
+			assert.Equal(ErrQueueEmpty, err)
+
+			assert.Nil(q.Enqueue([]byte("abc")))
+			assert.Nil(q.Enqueue([]byte("defghi")))
+			assert.Nil(q.Enqueue([]byte("j")))
+
+			n, err := q.DequeueInto(buf)
+			assert.Nil(err)
+			assert.Equal("abc", string(buf[:n]))
+
+			// the element stays in the queue until a large enough buffer is given
+			n, err = q.DequeueInto(buf)
+			assert.Equal(io.ErrShortBuffer, err)
+			assert.Equal(6, n)
+			assert.Equal(2, q.Len())
+
+			buf = make([]byte, n)
+			n, err = q.DequeueInto(buf)
+			assert.Nil(err)
+			assert.Equal("defghi", string(buf[:n]))
+
+			n, err = q.DequeueInto(buf)
+			assert.Nil(err)
+			assert.Equal("j", string(buf[:n]))
+			assert.Equal(0, q.Len())
+			assert.Nil(q.HealthCheck())
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// Op identifies the kind of I/O operation that failed
+type Op string
+
+const (
+	OpHeaderRead   Op = "header read"
+	OpHeaderSync   Op = "header sync"
+	OpElementRead  Op = "element read"
+	OpElementWrite Op = "element write"
+	OpSeek         Op = "seek"
+)
+
+// IOError records an error from the backing store along with the
+// operation and file offset at which it occurred
+type IOError struct {
+	Op     Op
+	Offset int64
+	Err    error
+}
+
+func (e *IOError) Error() string {
+	return fmt.Sprintf("%s at %d: %v", e.Op, e.Offset, e.Err)
+}
+
+func (e *IOError) Unwrap() error {
+	return e.Err
+}
+
+// ioError annotates err with op and off, leaving errors that are already
+// annotated, such as failed seeks, untouched
+func ioError(op Op, off int64, err error) error {
+	var ioErr *IOError
+	if err == nil || errors.As(err, &ioErr) {
+		return err
+	}
+	return &IOError{Op: op, Offset: off, Err: err}
+}
+
+// readAt fills b with the bytes at off in the backing store, using
+// positioned reads when the backing store implements io.ReaderAt
+//
+// Like io.ReadFull, it returns io.EOF if no bytes could be read and
+// io.ErrUnexpectedEOF if only some of them could.
+func (ls *Queue) readAt(b []byte, off int64) error {
+	return ls.retry(isTransient, func() error {
+		return ls.readAtOnce(b, off)
+	})
+}
+
+func (ls *Queue) readAtOnce(b []byte, off int64) error {
+	if ls.ra == nil {
+		if err := ls.seek(off); err != nil {
+			return err
+		}
+		_, err := io.ReadFull(tallyReader{r: ls.rws, n: &ls.stats.reads}, b)
+		return err
+	}
+
+	atomic.AddUint64(&ls.stats.reads, 1)
+	n, err := ls.ra.ReadAt(b, off)
+	if n == len(b) {
+		return nil
+	}
+	if err == io.EOF && n > 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// writeAt writes b at off in the backing store, using positioned writes
+// when the backing store implements io.WriterAt
+func (ls *Queue) writeAt(b []byte, off int64) (int, error) {
+	var n int
+	err := ls.retry(isTransient, func() (err error) {
+		n, err = ls.writeAtOnce(b, off)
+		return err
+	})
+	return n, err
+}
+
+func (ls *Queue) writeAtOnce(b []byte, off int64) (int, error) {
+	if ls.wa != nil {
+		atomic.AddUint64(&ls.stats.writes, 1)
+		return ls.wa.WriteAt(b, off)
+	}
+
+	if err := ls.seek(off); err != nil {
+		return 0, err
+	}
+	atomic.AddUint64(&ls.stats.writes, 1)
+	return ls.rws.Write(b)
+}
+
+// writerAt returns a writer that writes sequentially from off in the
+// backing store
+func (ls *Queue) writerAt(off int64) (io.Writer, error) {
+	if ls.wa != nil {
+		return &offsetWriter{wa: ls.wa, off: off, writes: &ls.stats.writes}, nil
+	}
+
+	if err := ls.seek(off); err != nil {
+		return nil, err
+	}
+	return tallyWriter{w: ls.rws, n: &ls.stats.writes}, nil
+}
+
+// offsetWriter writes sequentially to an io.WriterAt
+type offsetWriter struct {
+	wa     io.WriterAt
+	off    int64
+	writes *uint64 // incremented for every write, accessed atomically
+}
+
+func (w *offsetWriter) Write(b []byte) (int, error) {
+	atomic.AddUint64(w.writes, 1)
+	n, err := w.wa.WriteAt(b, w.off)
+	w.off += int64(n)
+	return n, err
+}
+
+// readerAt returns a reader over the n bytes at off in the backing store
+func (ls *Queue) readerAt(off, n int64) (io.Reader, error) {
+	if ls.ra != nil {
+		return tallyReader{r: io.NewSectionReader(ls.ra, off, n), n: &ls.stats.reads}, nil
+	}
+
+	if err := ls.seek(off); err != nil {
+		return nil, err
+	}
+	return io.LimitReader(tallyReader{r: ls.rws, n: &ls.stats.reads}, n), nil
+}
+
+// tallyReader counts the reads made from r in n, accessed atomically
+type tallyReader struct {
+	r io.Reader
+	n *uint64
+}
+
+func (c tallyReader) Read(b []byte) (int, error) {
+	atomic.AddUint64(c.n, 1)
+	return c.r.Read(b)
+}
+
+// tallyWriter counts the writes made to w in n, accessed atomically
+type tallyWriter struct {
+	w io.Writer
+	n *uint64
+}
+
+func (c tallyWriter) Write(b []byte) (int, error) {
+	atomic.AddUint64(c.n, 1)
+	return c.w.Write(b)
+}
+
+// seek moves the offset of Queue.rws to off
+func (ls *Queue) seek(off int64) error {
+	atomic.AddUint64(&ls.stats.seeks, 1)
+	if _, err := ls.rws.Seek(off, io.SeekStart); err != nil {
+		return &IOError{Op: OpSeek, Offset: off, Err: err}
+	}
+	return nil
+}
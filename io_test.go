@@ -0,0 +1,73 @@
+package queue
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIOError(t *testing.T) {
+	assert := assert.New(t)
+
+	// newFlakyQueue returns a queue over a flaky RWS holding one element
+	newFlakyQueue := func() (*Queue, *flakyReadWriteSeeker) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		rws := newFlakyReadWriteSeeker(f)
+		q := NewQueue(rws)
+		assert.Nil(q.Enqueue([]byte("hello")))
+		return q, rws
+	}
+
+	assertOp := func(err error, op Op, offset int64) {
+		var ioErr *IOError
+		if assert.True(errors.As(err, &ioErr)) {
+			assert.Equal(op, ioErr.Op)
+			assert.Equal(offset, ioErr.Offset)
+			assert.EqualError(ioErr.Err, "Oh no!")
+		}
+	}
+
+	t.Run("element write", func(t *testing.T) {
+		q, rws := newFlakyQueue()
+
+		rws.failNextWrite()
+		assertOp(q.Enqueue([]byte("world")), OpElementWrite, int64(headerLength+4+5))
+	})
+
+	t.Run("header sync", func(t *testing.T) {
+		q, rws := newFlakyQueue()
+
+		// the 9-byte element frame is written but the header write that
+		// follows is torn
+		rws.tearNextWrite(9)
+		assertOp(q.Enqueue([]byte("world")), OpHeaderSync, int64(headerSlotLength))
+	})
+
+	t.Run("element read", func(t *testing.T) {
+		q, rws := newFlakyQueue()
+
+		rws.failNextRead()
+		_, err := q.Dequeue()
+		assertOp(err, OpElementRead, int64(headerLength))
+	})
+
+	t.Run("header read", func(t *testing.T) {
+		_, rws := newFlakyQueue()
+
+		rws.failNextRead()
+		_, err := New(rws)
+		assertOp(err, OpHeaderRead, 0)
+	})
+
+	t.Run("seek", func(t *testing.T) {
+		q, rws := newFlakyQueue()
+
+		rws.failNextSeek()
+		_, err := q.Dequeue()
+		assertOp(err, OpSeek, int64(headerLength))
+	})
+}
@@ -0,0 +1,80 @@
+package queue
+
+import "context"
+
+// Iter returns a channel on which the payloads of the live elements are
+// sent in FIFO order without removing them, and which is closed after the
+// last element or once ctx is done
+//
+// The walk covers the elements live when Iter is called: elements enqueued
+// afterwards are not sent. Elements are read one at a time as the channel
+// is drained, so the queue is not locked between sends; an element that is
+// dequeued or evicted before the walk reaches it is skipped, and the walk
+// carries on from the new head. The channel is also closed early if the
+// queue is closed or an element cannot be read.
+func (ls *Queue) Iter(ctx context.Context) <-chan []byte {
+	ls.mu.Lock()
+	it := &Cursor{q: ls, read: ls.header.removed}
+	end := ls.header.removed + uint64(ls.header.queueSize)
+	ls.mu.Unlock()
+
+	ch := make(chan []byte)
+	go ls.iterate(ctx, it, end, ch)
+	return ch
+}
+
+// iterate sends the payloads of the elements from it up to, but not
+// including, the element numbered end on ch
+func (ls *Queue) iterate(ctx context.Context, it *Cursor, end uint64, ch chan<- []byte) {
+	defer close(ch)
+
+	for ctx.Err() == nil {
+		v, ok := ls.iterNext(it, end)
+		if !ok {
+			return
+		}
+
+		select {
+		case ch <- v:
+		case <-ctx.Done():
+			return
+		case <-ls.closed:
+			return
+		}
+	}
+}
+
+// iterNext returns the next payload for it and moves it past the element,
+// or false once it reaches the element numbered end or the element cannot
+// be read
+//
+// it is an unregistered Cursor, so its position is never persisted.
+func (ls *Queue) iterNext(it *Cursor, end uint64) ([]byte, bool) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if ls.isClosed() {
+		return nil, false
+	}
+
+	// skip elements removed since the walk started
+	if it.read < ls.header.removed {
+		it.read, it.known = ls.header.removed, false
+	}
+	if it.read >= end {
+		return nil, false
+	}
+
+	body, pos, frameLength, err := it.peek()
+	if err != nil {
+		return nil, false
+	}
+	v, err := ls.decodeElement(body)
+	if err != nil {
+		return nil, false
+	}
+
+	it.read++
+	it.pos, it.relocations, it.known = pos+frameLength, ls.relocations, true
+	return v, true
+}
@@ -0,0 +1,123 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIter(t *testing.T) {
+	assert := assert.New(t)
+
+	collect := func(ch <-chan []byte) []string {
+		var values []string
+		for v := range ch {
+			values = append(values, string(v))
+		}
+		return values
+	}
+
+	t.Run("fifo", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+
+		var want []string
+		for i := 0; i < 10; i++ {
+			v := fmt.Sprintf("element-%d", i)
+			assert.Nil(q.Enqueue([]byte(v)))
+			want = append(want, v)
+		}
+
+		assert.Equal(want, collect(q.Iter(context.Background())))
+
+		// nothing is removed
+		assert.Equal(10, q.Len())
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+		assert.Empty(collect(q.Iter(context.Background())))
+	})
+
+	t.Run("wrapped", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithCapacity(headerLength+32))
+
+		for i := 0; i < 6; i++ {
+			assert.Nil(q.Enqueue([]byte(strconv.Itoa(i))))
+		}
+		for i := 0; i < 4; i++ {
+			_, err := q.Dequeue()
+			assert.Nil(err)
+		}
+		for i := 6; i < 9; i++ {
+			assert.Nil(q.Enqueue([]byte(strconv.Itoa(i))))
+		}
+		assert.True(q.IsWrapped())
+
+		assert.Equal([]string{"4", "5", "6", "7", "8"}, collect(q.Iter(context.Background())))
+	})
+
+	t.Run("snapshot", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+		for i := 0; i < 100; i++ {
+			assert.Nil(q.Enqueue([]byte(strconv.Itoa(i))))
+		}
+
+		ch := q.Iter(context.Background())
+
+		// mutate the queue while the walk is under way
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 50; i++ {
+				_, err := q.Dequeue()
+				assert.Nil(err)
+				assert.Nil(q.Enqueue([]byte(strconv.Itoa(100 + i))))
+			}
+		}()
+
+		// elements removed before the walk reaches them are skipped, and
+		// elements enqueued after Iter was called are not sent
+		last := -1
+		for v := range ch {
+			i, err := strconv.Atoi(string(v))
+			assert.Nil(err)
+			assert.Greater(i, last)
+			assert.Less(i, 100)
+			last = i
+		}
+		assert.Equal(99, last)
+		<-done
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+		for i := 0; i < 10; i++ {
+			assert.Nil(q.Enqueue([]byte(strconv.Itoa(i))))
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		ch := q.Iter(ctx)
+		assert.Equal([]byte("0"), <-ch)
+		cancel()
+
+		// at most the element already read is still sent
+		assert.LessOrEqual(len(collect(ch)), 1)
+		assert.Equal(10, q.Len())
+	})
+
+	t.Run("close", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+		for i := 0; i < 10; i++ {
+			assert.Nil(q.Enqueue([]byte(strconv.Itoa(i))))
+		}
+
+		ch := q.Iter(context.Background())
+		assert.Equal([]byte("0"), <-ch)
+		assert.Nil(q.Close())
+
+		assert.LessOrEqual(len(collect(ch)), 1)
+	})
+}
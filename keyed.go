@@ -0,0 +1,248 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+const (
+	keyedElement   byte = 0 // record holding a key and a value
+	keyedTombstone byte = 1 // record removing the preceding element with its key
+
+	maxKeyLength = 255
+)
+
+var (
+	ErrKeyNotFound = errors.New("key not found")
+	ErrKeyExists   = errors.New("key already exists")
+)
+
+// KeyedQueue is a FIFO queue whose elements also carry a unique key, so
+// that individual elements can be looked up or removed out of order
+//
+// An index from key to element offset is kept in memory and rebuilt by
+// scanning the queue when it is opened. Removing an element appends a
+// tombstone record naming its key; the removed element stays in the file
+// until it reaches the head, where Dequeue skips it.
+type KeyedQueue struct {
+	mu      sync.Mutex
+	q       *Queue
+	live    map[string]uint32 // offset of each live element by key
+	removed map[uint32]bool   // offsets of removed elements not yet dequeued
+}
+
+// NewKeyedQueue opens the KeyedQueue stored in f, creating it if f is
+// empty, and indexes its elements
+//
+// The index would go stale if elements left the queue behind its back, so
+// options that evict elements, such as WithOverwriteOldest, or keep them
+// after they are dequeued, such as WithConsumerOffset, are rejected.
+func NewKeyedQueue(f io.ReadWriteSeeker, opts ...Option) (*KeyedQueue, error) {
+	q, err := New(f, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.checkIndexable(); err != nil {
+		return nil, err
+	}
+
+	kq := &KeyedQueue{
+		q:       q,
+		live:    make(map[string]uint32),
+		removed: make(map[uint32]bool),
+	}
+
+	if err := kq.load(); err != nil {
+		return nil, err
+	}
+
+	return kq, nil
+}
+
+// Enqueue adds v to the back of the queue under key, which must not
+// belong to another element in the queue
+func (kq *KeyedQueue) Enqueue(key string, v []byte) error {
+	kq.mu.Lock()
+	defer kq.mu.Unlock()
+
+	if _, ok := kq.live[key]; ok {
+		return fmt.Errorf("enqueue %q: %w", key, ErrKeyExists)
+	}
+
+	record, err := encodeKeyedRecord(keyedElement, key, v)
+	if err != nil {
+		return err
+	}
+
+	offset, err := kq.q.EnqueueAt(record)
+	if err != nil {
+		return err
+	}
+	kq.live[key] = offset
+
+	return nil
+}
+
+// Get returns the value of the element with key without removing it
+func (kq *KeyedQueue) Get(key string) ([]byte, error) {
+	kq.mu.Lock()
+	defer kq.mu.Unlock()
+
+	offset, ok := kq.live[key]
+	if !ok {
+		return nil, fmt.Errorf("get %q: %w", key, ErrKeyNotFound)
+	}
+
+	record, err := kq.q.ReadElementAt(offset)
+	if err != nil {
+		return nil, err
+	}
+
+	_, _, v, err := decodeKeyedRecord(record)
+	return v, err
+}
+
+// Remove removes the element with key from the queue
+//
+// Remove appends a tombstone record, so it fails with ErrQueueFull if the
+// queue has no room for it.
+func (kq *KeyedQueue) Remove(key string) error {
+	kq.mu.Lock()
+	defer kq.mu.Unlock()
+
+	offset, ok := kq.live[key]
+	if !ok {
+		return fmt.Errorf("remove %q: %w", key, ErrKeyNotFound)
+	}
+
+	record, err := encodeKeyedRecord(keyedTombstone, key, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := kq.q.Enqueue(record); err != nil {
+		return err
+	}
+	delete(kq.live, key)
+	kq.removed[offset] = true
+
+	return nil
+}
+
+// Dequeue removes and returns the key and value of the element at the
+// front of the queue, skipping removed elements
+func (kq *KeyedQueue) Dequeue() (string, []byte, error) {
+	kq.mu.Lock()
+	defer kq.mu.Unlock()
+
+	for {
+		offset, record, err := kq.dequeueRecord()
+		if err != nil {
+			return "", nil, err
+		}
+
+		kind, key, v, err := decodeKeyedRecord(record)
+		if err != nil {
+			return "", nil, err
+		}
+
+		// the element named by a tombstone was dequeued before it
+		if kind == keyedTombstone {
+			continue
+		}
+
+		if kq.removed[offset] {
+			delete(kq.removed, offset)
+			continue
+		}
+
+		delete(kq.live, key)
+		return key, v, nil
+	}
+}
+
+// Len returns the number of elements in the queue that have not been
+// removed
+func (kq *KeyedQueue) Len() int {
+	kq.mu.Lock()
+	defer kq.mu.Unlock()
+
+	return len(kq.live)
+}
+
+// Close closes the underlying queue
+func (kq *KeyedQueue) Close() error {
+	return kq.q.Close()
+}
+
+// dequeueRecord removes the head record and returns it along with the
+// offset it was stored at
+func (kq *KeyedQueue) dequeueRecord() (uint32, []byte, error) {
+	kq.q.mu.Lock()
+	defer kq.q.mu.Unlock()
+
+	offset := kq.q.header.headPosition
+	record, err := kq.q.dequeue()
+	return offset, record, err
+}
+
+// load rebuilds the index by scanning the records in the queue
+func (kq *KeyedQueue) load() error {
+	kq.q.mu.Lock()
+	defer kq.q.mu.Unlock()
+
+	var loadErr error
+	err := kq.q.walk(func(pos, _ uint32, body []byte) bool {
+		record, err := kq.q.decodeElement(body)
+		if err != nil {
+			loadErr = err
+			return false
+		}
+
+		kind, key, _, err := decodeKeyedRecord(record)
+		if err != nil {
+			loadErr = fmt.Errorf("record at %d: %w", pos, err)
+			return false
+		}
+
+		switch kind {
+		case keyedElement:
+			kq.live[key] = pos
+		case keyedTombstone:
+			if offset, ok := kq.live[key]; ok {
+				delete(kq.live, key)
+				kq.removed[offset] = true
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	return loadErr
+}
+
+// encodeKeyedRecord lays out a record as its kind, the key length, the
+// key, and the value
+func encodeKeyedRecord(kind byte, key string, v []byte) ([]byte, error) {
+	if len(key) > maxKeyLength {
+		return nil, fmt.Errorf("key must be at most %d bytes", maxKeyLength)
+	}
+
+	record := make([]byte, 0, 2+len(key)+len(v))
+	record = append(record, kind, byte(len(key)))
+	record = append(record, key...)
+	return append(record, v...), nil
+}
+
+func decodeKeyedRecord(record []byte) (kind byte, key string, v []byte, err error) {
+	if len(record) < 2 || len(record) < 2+int(record[1]) || record[0] > keyedTombstone {
+		return 0, "", nil, errors.New("malformed keyed queue record")
+	}
+
+	keyEnd := 2 + int(record[1])
+	return record[0], string(record[2:keyEnd]), record[keyEnd:], nil
+}
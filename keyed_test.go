@@ -0,0 +1,113 @@
+package queue
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyedQueue(t *testing.T) {
+	assert := assert.New(t)
+
+	dequeueAll := func(kq *KeyedQueue) []string {
+		var keys []string
+		for {
+			key, v, err := kq.Dequeue()
+			if err == ErrQueueEmpty {
+				return keys
+			}
+			assert.Nil(err)
+			assert.Equal([]byte("value-"+key), v)
+			keys = append(keys, key)
+		}
+	}
+
+	t.Run("lookup", func(t *testing.T) {
+		kq, err := NewKeyedQueue(NewMemBuffer())
+		assert.Nil(err)
+
+		for _, key := range []string{"a", "b", "c"} {
+			assert.Nil(kq.Enqueue(key, []byte("value-"+key)))
+		}
+		assert.True(errors.Is(kq.Enqueue("b", nil), ErrKeyExists))
+
+		v, err := kq.Get("b")
+		assert.Nil(err)
+		assert.Equal([]byte("value-b"), v)
+
+		_, err = kq.Get("z")
+		assert.True(errors.Is(err, ErrKeyNotFound))
+
+		// lookups do not consume elements
+		assert.Equal(3, kq.Len())
+		assert.Equal([]string{"a", "b", "c"}, dequeueAll(kq))
+
+		_, err = kq.Get("a")
+		assert.True(errors.Is(err, ErrKeyNotFound))
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		kq, err := NewKeyedQueue(NewMemBuffer())
+		assert.Nil(err)
+
+		for _, key := range []string{"a", "b", "c", "d"} {
+			assert.Nil(kq.Enqueue(key, []byte("value-"+key)))
+		}
+
+		assert.Nil(kq.Remove("b"))
+		assert.Nil(kq.Remove("d"))
+		assert.True(errors.Is(kq.Remove("b"), ErrKeyNotFound))
+		assert.Equal(2, kq.Len())
+
+		_, err = kq.Get("b")
+		assert.True(errors.Is(err, ErrKeyNotFound))
+
+		// a removed key may be reused
+		assert.Nil(kq.Enqueue("b", []byte("value-b")))
+
+		assert.Equal([]string{"a", "c", "b"}, dequeueAll(kq))
+		assert.Equal(0, kq.Len())
+	})
+
+	t.Run("reopen", func(t *testing.T) {
+		f := NewMemBuffer()
+		kq, err := NewKeyedQueue(f)
+		assert.Nil(err)
+
+		for _, key := range []string{"a", "b", "c", "d"} {
+			assert.Nil(kq.Enqueue(key, []byte("value-"+key)))
+		}
+		assert.Nil(kq.Remove("c"))
+		key, _, err := kq.Dequeue()
+		assert.Nil(err)
+		assert.Equal("a", key)
+
+		kq, err = NewKeyedQueue(f)
+		assert.Nil(err)
+		assert.Equal(2, kq.Len())
+
+		v, err := kq.Get("d")
+		assert.Nil(err)
+		assert.Equal([]byte("value-d"), v)
+
+		assert.Equal([]string{"b", "d"}, dequeueAll(kq))
+	})
+
+	t.Run("long key", func(t *testing.T) {
+		kq, err := NewKeyedQueue(NewMemBuffer())
+		assert.Nil(err)
+		assert.NotNil(kq.Enqueue(string(make([]byte, 256)), nil))
+	})
+
+	t.Run("evicting options are rejected", func(t *testing.T) {
+		for name, opt := range map[string]Option{
+			"overwrite oldest": WithOverwriteOldest(),
+			"full policy":      WithFullPolicy(func([]byte, *Queue) FullAction { return ActionDropOldest }),
+			"consumer offset":  WithConsumerOffset(),
+		} {
+			_, err := NewKeyedQueue(NewMemBuffer(), opt)
+			assert.NotNil(err, name)
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package queue
+
+import (
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// WithLatencyTracking records how long each Enqueue and Dequeue call takes,
+// including any wait for the queue lock, and reports the distribution in
+// Stats
+//
+// Durations are counted in buckets whose bounds are powers of two
+// nanoseconds, so recording costs a couple of atomic increments and the
+// reported percentiles are upper bounds accurate to within a factor of
+// two. Time spent waiting on a dequeue rate limit is not included.
+func WithLatencyTracking() Option {
+	return func(ls *Queue) {
+		ls.latency = &latencies{}
+	}
+}
+
+// LatencyStats summarizes the recorded durations of one kind of operation
+type LatencyStats struct {
+	Count uint64        // operations recorded
+	P50   time.Duration // median duration
+	P99   time.Duration // 99th percentile duration
+}
+
+// latencies holds the histograms behind WithLatencyTracking
+type latencies struct {
+	enqueue histogram
+	dequeue histogram
+}
+
+// histogram counts durations in buckets, where bucket i holds durations
+// shorter than 2^i nanoseconds that do not fit in bucket i-1
+type histogram struct {
+	buckets [65]uint64 // accessed atomically
+}
+
+// since records the time elapsed since start
+func (h *histogram) since(start time.Time) {
+	d := time.Since(start)
+	if d < 0 {
+		d = 0
+	}
+	atomic.AddUint64(&h.buckets[bits.Len64(uint64(d))], 1)
+}
+
+// stats returns the count and percentiles of the recorded durations
+func (h *histogram) stats() LatencyStats {
+	var counts [len(h.buckets)]uint64
+	var s LatencyStats
+	for i := range h.buckets {
+		counts[i] = atomic.LoadUint64(&h.buckets[i])
+		s.Count += counts[i]
+	}
+	if s.Count == 0 {
+		return s
+	}
+
+	s.P50 = percentile(counts[:], s.Count, 50)
+	s.P99 = percentile(counts[:], s.Count, 99)
+	return s
+}
+
+// percentile returns the upper bound of the bucket holding the p-th
+// percentile of total durations
+func percentile(counts []uint64, total uint64, p uint64) time.Duration {
+	rank := (total*p + 99) / 100
+	var seen uint64
+	for i, n := range counts {
+		seen += n
+		if seen >= rank {
+			return bucketBound(i)
+		}
+	}
+	return bucketBound(len(counts) - 1)
+}
+
+// bucketBound returns the exclusive upper bound of bucket i
+func bucketBound(i int) time.Duration {
+	if i >= 63 {
+		return time.Duration(1<<63 - 1)
+	}
+	return time.Duration(1) << uint(i)
+}
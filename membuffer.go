@@ -0,0 +1,123 @@
+package queue
+
+import (
+	"errors"
+	"io"
+)
+
+// MemBuffer is an in-memory io.ReadWriteSeeker backed by a byte slice that
+// grows as it is written, useful for queues that need not outlive the
+// process and for tests
+//
+// Like a file, seeking past the end and writing zero fills the gap.
+// MemBuffer also implements io.ReaderAt, io.WriterAt and Truncate, so a
+// queue over a MemBuffer behaves like one over an *os.File. A MemBuffer is
+// not safe for concurrent use on its own, but a Queue serializes its access.
+type MemBuffer struct {
+	buf []byte
+	pos int64
+}
+
+// NewMemBuffer returns an empty MemBuffer
+func NewMemBuffer() *MemBuffer {
+	return &MemBuffer{}
+}
+
+// Bytes returns the contents of the file
+func (m *MemBuffer) Bytes() []byte {
+	return m.buf
+}
+
+func (m *MemBuffer) Read(b []byte) (int, error) {
+	n, err := m.ReadAt(b, m.pos)
+	m.pos += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (m *MemBuffer) ReadAt(b []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= int64(len(m.buf)) {
+		return 0, io.EOF
+	}
+
+	n := copy(b, m.buf[off:])
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (m *MemBuffer) Write(b []byte) (int, error) {
+	n, err := m.WriteAt(b, m.pos)
+	m.pos += int64(n)
+	return n, err
+}
+
+func (m *MemBuffer) WriteAt(b []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	if end := off + int64(len(b)); end > int64(len(m.buf)) {
+		m.grow(end)
+	}
+	return copy(m.buf[off:], b), nil
+}
+
+func (m *MemBuffer) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = m.pos + offset
+	case io.SeekEnd:
+		pos = int64(len(m.buf)) + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+
+	if pos < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	m.pos = pos
+	return pos, nil
+}
+
+// Truncate changes the length of the file to size, zero filling it if it
+// grows
+func (m *MemBuffer) Truncate(size int64) error {
+	if size < 0 {
+		return errors.New("negative size")
+	}
+
+	if size > int64(len(m.buf)) {
+		m.grow(size)
+		return nil
+	}
+
+	m.buf = m.buf[:size]
+	return nil
+}
+
+// grow extends the file with zeros to size bytes
+func (m *MemBuffer) grow(size int64) {
+	if size <= int64(cap(m.buf)) {
+		old := len(m.buf)
+		m.buf = m.buf[:size]
+		for i := old; i < len(m.buf); i++ {
+			m.buf[i] = 0
+		}
+		return
+	}
+
+	buf := make([]byte, size, 2*size)
+	copy(buf, m.buf)
+	m.buf = buf
+}
@@ -0,0 +1,61 @@
+package queue
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemBuffer(t *testing.T) {
+	assert := assert.New(t)
+
+	m := NewMemBuffer()
+
+	n, err := m.Write([]byte("hello"))
+	assert.Nil(err)
+	assert.Equal(5, n)
+
+	_, err = m.WriteAt([]byte("world"), 8)
+	assert.Nil(err)
+	assert.Equal([]byte("hello\x00\x00\x00world"), m.Bytes())
+
+	pos, err := m.Seek(-5, io.SeekEnd)
+	assert.Nil(err)
+	assert.Equal(int64(8), pos)
+
+	b := make([]byte, 10)
+	n, err = m.Read(b)
+	assert.Nil(err)
+	assert.Equal("world", string(b[:n]))
+
+	_, err = m.Read(b)
+	assert.Equal(io.EOF, err)
+
+	assert.Nil(m.Truncate(2))
+	assert.Equal([]byte("he"), m.Bytes())
+	assert.Nil(m.Truncate(4))
+	assert.Equal([]byte("he\x00\x00"), m.Bytes())
+}
+
+func TestMemBufferSeekPastEnd(t *testing.T) {
+	assert := assert.New(t)
+
+	m := NewMemBuffer()
+
+	pos, err := m.Seek(4, io.SeekStart)
+	assert.Nil(err)
+	assert.Equal(int64(4), pos)
+
+	// reading past the end is EOF and does not grow the buffer
+	_, err = m.Read(make([]byte, 1))
+	assert.Equal(io.EOF, err)
+	assert.Empty(m.Bytes())
+
+	_, err = m.Write([]byte("x"))
+	assert.Nil(err)
+	assert.Equal([]byte("\x00\x00\x00\x00x"), m.Bytes())
+
+	_, err = m.Seek(-1, io.SeekStart)
+	assert.NotNil(err)
+}
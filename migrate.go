@@ -0,0 +1,168 @@
+package queue
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// LegacyVersion is the layout version Migrate accepts for files written
+// before the header was versioned, which start with a 16-byte big-endian
+// header holding the file length, queue size, head, and tail
+const LegacyVersion = -1
+
+// legacyHeaderLength is the length of the header of a legacy file
+const legacyHeaderLength uint32 = 16
+
+var errLegacyLayout = errors.New("file does not hold a valid legacy queue")
+
+// Migrate rewrites the header of the queue stored in f from layout version
+// fromVersion to toVersion, after checking that every element can be read
+// under the old layout
+//
+// The header is the only part of the file whose layout is versioned, so
+// elements stay where they are; fields added by newer versions start out
+// zero, just as when a current Queue opens an older file. The header keeps
+// its size across versions, so a migration always fits in the file.
+// Migrate fails without modifying f if the file holds a different version
+// than fromVersion, or if toVersion is older than fromVersion or newer
+// than the versions this package knows.
+//
+// Files with the legacy 16-byte header, which New rejects, are migrated
+// with a fromVersion of LegacyVersion. Their header is shorter than the
+// current one, so the elements are moved after it and the capacity grows
+// by the difference. Unlike other migrations this rewrites the elements in
+// place, so a crash part way through can lose the queue; migrate a copy of
+// the file. Legacy files whose elements wrapped to the front of the buffer
+// did not record where the elements wrap and are rejected.
+func Migrate(f io.ReadWriteSeeker, fromVersion, toVersion int) error {
+	if toVersion < fromVersion || toVersion < 0 {
+		return fmt.Errorf("cannot migrate from version %d down to version %d", fromVersion, toVersion)
+	}
+	if toVersion > int(headerVersion) {
+		return fmt.Errorf("cannot migrate to version %d newer than version %d", toVersion, headerVersion)
+	}
+	if fromVersion == LegacyVersion {
+		return migrateLegacy(f, byte(toVersion))
+	}
+
+	q := newQueue(f, nil)
+	header, seq, err := q.readHeader()
+	if err != nil {
+		return err
+	}
+
+	// the most recent header was written to the slot its sequence selects
+	var version [1]byte
+	offset := int64(seq%2)*int64(headerSlotLength) + 25
+	if err := q.readAt(version[:], offset); err != nil {
+		return ioError(OpHeaderRead, offset, err)
+	}
+	if int(version[0]) != fromVersion {
+		return fmt.Errorf("file holds version %d, not version %d", version[0], fromVersion)
+	}
+
+	q.header, q.headerSeq = header, seq
+	if err := q.adoptFraming(); err != nil {
+		return err
+	}
+	if err := q.walk(func(_, _ uint32, _ []byte) bool { return true }); err != nil {
+		return fmt.Errorf("read elements: %w", err)
+	}
+
+	seq++
+	slot := encodeHeaderSlotVersion(header, seq, byte(toVersion))
+	offset = int64(seq%2) * int64(headerSlotLength)
+	if _, err := q.writeAt(slot, offset); err != nil {
+		return ioError(OpHeaderSync, offset, err)
+	}
+
+	return nil
+}
+
+// migrateLegacy rewrites the legacy file f in the layout of version
+func migrateLegacy(f io.ReadWriteSeeker, version byte) error {
+	q := newQueue(f, nil)
+
+	// a current header can look like a legacy one, so it is ruled out
+	// first
+	if _, _, err := q.readHeader(); err != ErrInvalidHeader {
+		if err == nil {
+			err = errors.New("file does not hold the legacy version")
+		}
+		return err
+	}
+
+	legacy, frames, err := q.readLegacy()
+	if err != nil {
+		return err
+	}
+
+	// legacy frames are length-prefixed big-endian, as the default framing
+	// of a version 0 file
+	header := fileHeader{
+		fileLength:   legacy.fileLength + headerLength - legacyHeaderLength,
+		queueSize:    legacy.queueSize,
+		headPosition: headerLength,
+		tailPosition: headerLength + uint32(len(frames)),
+	}
+
+	if _, err := q.writeAt(frames, int64(headerLength)); err != nil {
+		return ioError(OpElementWrite, int64(headerLength), err)
+	}
+
+	// the new header goes to the second slot first, leaving the legacy
+	// header in place until it is written, and then the stale first slot
+	// is cleared
+	slot := encodeHeaderSlotVersion(header, 1, version)
+	if _, err := q.writeAt(slot, int64(headerSlotLength)); err != nil {
+		return ioError(OpHeaderSync, int64(headerSlotLength), err)
+	}
+	if _, err := q.writeAt(make([]byte, headerSlotLength), 0); err != nil {
+		return ioError(OpHeaderSync, 0, err)
+	}
+
+	return nil
+}
+
+// readLegacy reads the header of a legacy file along with the frames of
+// its elements, checking that they run from the head to the tail
+func (ls *Queue) readLegacy() (fileHeader, []byte, error) {
+	var b [legacyHeaderLength]byte
+	if err := ls.readAt(b[:], 0); err != nil {
+		return fileHeader{}, nil, ioError(OpHeaderRead, 0, err)
+	}
+
+	h := fileHeader{
+		fileLength:   binary.BigEndian.Uint32(b[:4]),
+		queueSize:    binary.BigEndian.Uint32(b[4:8]),
+		headPosition: binary.BigEndian.Uint32(b[8:12]),
+		tailPosition: binary.BigEndian.Uint32(b[12:]),
+	}
+	if h.headPosition < legacyHeaderLength || h.headPosition > h.tailPosition || h.tailPosition > h.fileLength {
+		return fileHeader{}, nil, errLegacyLayout
+	}
+
+	frames := make([]byte, h.tailPosition-h.headPosition)
+	if err := ls.readAt(frames, int64(h.headPosition)); err != nil {
+		return fileHeader{}, nil, ioError(OpElementRead, int64(h.headPosition), err)
+	}
+
+	pos := uint32(0)
+	for i := uint32(0); i < h.queueSize; i++ {
+		if uint32(len(frames))-pos < 4 {
+			return fileHeader{}, nil, errLegacyLayout
+		}
+		length := binary.BigEndian.Uint32(frames[pos:])
+		if length > uint32(len(frames))-pos-4 {
+			return fileHeader{}, nil, errLegacyLayout
+		}
+		pos += 4 + length
+	}
+	if pos != uint32(len(frames)) {
+		return fileHeader{}, nil, errLegacyLayout
+	}
+
+	return h, frames, nil
+}
@@ -0,0 +1,100 @@
+package queue
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrate(t *testing.T) {
+	assert := assert.New(t)
+
+	f := NewMemBuffer()
+	q := NewQueue(f, WithSequenceNumbers())
+	assert.Nil(q.Enqueue([]byte("a")))
+	assert.Nil(q.Enqueue([]byte("b")))
+
+	// rewrite both slots as version 1
+	for _, seq := range []uint64{q.headerSeq - 1, q.headerSeq} {
+		copy(f.Bytes()[seq%2*uint64(headerSlotLength):], encodeHeaderSlotVersion(q.header, seq, 1))
+	}
+	version := func() byte {
+		header, seq, err := q.readHeader()
+		assert.Nil(err)
+		assert.Equal(q.header, header)
+		return f.Bytes()[seq%2*uint64(headerSlotLength)+25]
+	}
+	assert.Equal(byte(1), version())
+
+	assert.NotNil(Migrate(f, 2, 3))
+	assert.NotNil(Migrate(f, 1, 0))
+	assert.NotNil(Migrate(f, 1, int(headerVersion)+1))
+	assert.Equal(byte(1), version())
+
+	assert.Nil(Migrate(f, 1, 2))
+	assert.Equal(byte(2), version())
+
+	q, err := New(f)
+	assert.Nil(err)
+	assert.True(q.sequenced())
+	assert.Nil(q.HealthCheck())
+
+	v, seq, err := q.DequeueWithSeq()
+	assert.Nil(err)
+	assert.Equal([]byte("a"), v)
+	assert.Equal(uint64(1), seq)
+
+	assert.Nil(q.Enqueue([]byte("c")))
+	elements, err := q.Elements()
+	assert.Nil(err)
+	assert.Equal([][]byte{[]byte("b"), []byte("c")}, elements)
+}
+
+func TestMigrateLegacy(t *testing.T) {
+	assert := assert.New(t)
+
+	// a legacy file whose first element has been dequeued
+	legacy := func() *MemBuffer {
+		b := make([]byte, 16)
+		binary.BigEndian.PutUint32(b[:4], 4096)
+		binary.BigEndian.PutUint32(b[4:8], 2)
+		binary.BigEndian.PutUint32(b[8:12], 21)
+		binary.BigEndian.PutUint32(b[12:], 34)
+		for _, v := range []string{"a", "bc", "def"} {
+			b = append(b, 0, 0, 0, byte(len(v)))
+			b = append(b, v...)
+		}
+
+		f := NewMemBuffer()
+		_, err := f.Write(b)
+		assert.Nil(err)
+		return f
+	}
+
+	f := legacy()
+	_, err := New(f)
+	assert.True(errors.Is(err, ErrInvalidHeader))
+
+	assert.NotNil(Migrate(f, LegacyVersion, -1))
+	assert.Nil(Migrate(f, LegacyVersion, int(headerVersion)))
+
+	q, err := New(f)
+	assert.Nil(err)
+	assert.Nil(q.HealthCheck())
+	assert.Equal(uint32(4096+headerLength-16), q.Capacity())
+
+	assert.Nil(q.Enqueue([]byte("g")))
+	elements, err := q.Elements()
+	assert.Nil(err)
+	assert.Equal([][]byte{[]byte("bc"), []byte("def"), []byte("g")}, elements)
+
+	// the migrated file is no longer legacy
+	assert.NotNil(Migrate(f, LegacyVersion, int(headerVersion)))
+
+	// frames that do not run from the head to the tail are rejected
+	f = legacy()
+	binary.BigEndian.PutUint32(f.Bytes()[4:8], 3)
+	assert.NotNil(Migrate(f, LegacyVersion, int(headerVersion)))
+}
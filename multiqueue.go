@@ -0,0 +1,284 @@
+package queue
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+const (
+	directoryLength uint32 = 4096 // bytes reserved for the MultiQueue directory
+	maxNameLength          = 255
+)
+
+var directoryMagic = []byte("FQMQ")
+
+// MultiQueue multiplexes independent named queues onto a single backing file
+//
+// The file starts with a directory recording the name and offset of each
+// sub-queue, followed by one fixed-size region per sub-queue laid out like
+// a standalone Queue file. Sub-queues are created on first enqueue.
+type MultiQueue struct {
+	mu     sync.Mutex
+	rws    io.ReadWriteSeeker
+	opts   []Option
+	queues map[string]*Queue
+	names  []string // sub-queue names in creation order
+	next   uint32   // offset at which the next region starts
+	used   uint32   // bytes of the directory in use
+}
+
+// NewMultiQueue opens the MultiQueue stored in f, creating an empty directory
+// if f is empty; opts are applied to every sub-queue
+func NewMultiQueue(f io.ReadWriteSeeker, opts ...Option) (*MultiQueue, error) {
+	mq := &MultiQueue{
+		rws:    f,
+		opts:   opts,
+		queues: make(map[string]*Queue),
+		next:   directoryLength,
+		used:   uint32(len(directoryMagic)) + 4,
+	}
+
+	if err := mq.load(); err != nil {
+		return nil, err
+	}
+
+	return mq, nil
+}
+
+// Enqueue adds a value to the named sub-queue, creating it if necessary
+func (mq *MultiQueue) Enqueue(name string, v []byte) error {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
+	q, err := mq.queue(name)
+	if err != nil {
+		return err
+	}
+
+	return q.Enqueue(v)
+}
+
+// Dequeue removes and returns the item at the front of the named sub-queue
+func (mq *MultiQueue) Dequeue(name string) ([]byte, error) {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
+	q, ok := mq.queues[name]
+	if !ok {
+		return nil, ErrQueueEmpty
+	}
+
+	return q.Dequeue()
+}
+
+// Names returns the names of all sub-queues in creation order
+func (mq *MultiQueue) Names() []string {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
+	names := make([]string, len(mq.names))
+	copy(names, mq.names)
+	return names
+}
+
+// queue returns the named sub-queue, creating and persisting it if it does
+// not exist yet
+func (mq *MultiQueue) queue(name string) (*Queue, error) {
+	if q, ok := mq.queues[name]; ok {
+		return q, nil
+	}
+
+	if len(name) == 0 || len(name) > maxNameLength {
+		return nil, fmt.Errorf("sub-queue name must be between 1 and %d bytes", maxNameLength)
+	}
+
+	entryLength := uint32(1 + len(name) + 4)
+	if mq.used+entryLength > directoryLength {
+		return nil, errors.New("multiqueue directory is full")
+	}
+
+	// initialize the region before recording it in the directory so that
+	// the directory never references an uninitialized region
+	q, err := mq.open(mq.next)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := make([]byte, entryLength)
+	entry[0] = byte(len(name))
+	copy(entry[1:], name)
+	binary.BigEndian.PutUint32(entry[1+len(name):], mq.next)
+
+	if _, err := mq.rws.Seek(int64(mq.used), io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := mq.rws.Write(entry); err != nil {
+		return nil, err
+	}
+	if err := mq.writeCount(uint32(len(mq.names) + 1)); err != nil {
+		return nil, err
+	}
+
+	mq.used += entryLength
+	mq.next += q.header.fileLength
+	mq.queues[name] = q
+	mq.names = append(mq.names, name)
+
+	return q, nil
+}
+
+// open initializes the sub-queue whose region starts at offset
+func (mq *MultiQueue) open(offset uint32) (*Queue, error) {
+	section := &sectionReadWriteSeeker{rws: mq.rws, base: int64(offset)}
+	q := newQueue(section, mq.opts)
+	section.length = int64(q.defaultFileHeader().fileLength)
+
+	if err := q.init(); err != nil {
+		return nil, err
+	}
+	section.length = int64(q.header.fileLength)
+
+	return q, nil
+}
+
+// load reads the directory and opens every sub-queue it records
+func (mq *MultiQueue) load() error {
+	if _, err := mq.rws.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	directory := make([]byte, directoryLength)
+	n, err := io.ReadFull(mq.rws, directory)
+	if err == io.EOF {
+		// initializing for the first time
+		return mq.writeDirectoryHeader()
+	}
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	directory = directory[:n]
+
+	if len(directory) < int(mq.used) || !bytes.Equal(directory[:len(directoryMagic)], directoryMagic) {
+		return errors.New("file does not contain a multiqueue directory")
+	}
+
+	count := binary.BigEndian.Uint32(directory[len(directoryMagic):])
+	for i := uint32(0); i < count; i++ {
+		if int(mq.used) >= len(directory) {
+			return errors.New("multiqueue directory is truncated")
+		}
+
+		nameLength := uint32(directory[mq.used])
+		entryLength := 1 + nameLength + 4
+		if int(mq.used+entryLength) > len(directory) {
+			return errors.New("multiqueue directory is truncated")
+		}
+
+		entry := directory[mq.used : mq.used+entryLength]
+		name := string(entry[1 : 1+nameLength])
+		offset := binary.BigEndian.Uint32(entry[1+nameLength:])
+
+		q, err := mq.open(offset)
+		if err != nil {
+			return fmt.Errorf("open sub-queue %q: %w", name, err)
+		}
+
+		mq.used += entryLength
+		if end := offset + q.header.fileLength; end > mq.next {
+			mq.next = end
+		}
+		mq.queues[name] = q
+		mq.names = append(mq.names, name)
+	}
+
+	return nil
+}
+
+func (mq *MultiQueue) writeDirectoryHeader() error {
+	if _, err := mq.rws.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := mq.rws.Write(directoryMagic); err != nil {
+		return err
+	}
+	return mq.writeCount(0)
+}
+
+func (mq *MultiQueue) writeCount(count uint32) error {
+	var countBytes [4]byte
+	binary.BigEndian.PutUint32(countBytes[:], count)
+
+	if _, err := mq.rws.Seek(int64(len(directoryMagic)), io.SeekStart); err != nil {
+		return err
+	}
+	_, err := mq.rws.Write(countBytes[:])
+	return err
+}
+
+// sectionReadWriteSeeker exposes the region [base, base+length) of an
+// underlying io.ReadWriteSeeker as if it were a file of its own
+//
+// Every read and write seeks the underlying io.ReadWriteSeeker first,
+// so several sections may share it as long as calls are serialized.
+type sectionReadWriteSeeker struct {
+	rws    io.ReadWriteSeeker
+	base   int64
+	length int64
+	pos    int64
+}
+
+func (s *sectionReadWriteSeeker) Read(b []byte) (int, error) {
+	if s.pos >= s.length {
+		return 0, io.EOF
+	}
+	if remaining := s.length - s.pos; int64(len(b)) > remaining {
+		b = b[:remaining]
+	}
+
+	if _, err := s.rws.Seek(s.base+s.pos, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	n, err := s.rws.Read(b)
+	s.pos += int64(n)
+	return n, err
+}
+
+func (s *sectionReadWriteSeeker) Write(b []byte) (int, error) {
+	if s.pos+int64(len(b)) > s.length {
+		return 0, errors.New("write past the end of the section")
+	}
+
+	if _, err := s.rws.Seek(s.base+s.pos, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	n, err := s.rws.Write(b)
+	s.pos += int64(n)
+	return n, err
+}
+
+func (s *sectionReadWriteSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.pos
+	case io.SeekEnd:
+		offset += s.length
+	default:
+		return 0, errors.New("invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	s.pos = offset
+	return offset, nil
+}
+
+var _ io.ReadWriteSeeker = new(sectionReadWriteSeeker)
@@ -0,0 +1,114 @@
+package queue
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultiQueue(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("sub-queues are isolated", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		mq, err := NewMultiQueue(f)
+		assert.Nil(err)
+
+		assert.Nil(mq.Enqueue("a", []byte("a1")))
+		assert.Nil(mq.Enqueue("b", []byte("b1")))
+		assert.Nil(mq.Enqueue("a", []byte("a2")))
+		assert.Nil(mq.Enqueue("b", []byte("b2")))
+
+		front, err := mq.Dequeue("b")
+		assert.Nil(err)
+		assert.Equal([]byte("b1"), front)
+
+		front, err = mq.Dequeue("a")
+		assert.Nil(err)
+		assert.Equal([]byte("a1"), front)
+
+		front, err = mq.Dequeue("a")
+		assert.Nil(err)
+		assert.Equal([]byte("a2"), front)
+
+		_, err = mq.Dequeue("a")
+		assert.Equal(ErrQueueEmpty, err)
+
+		front, err = mq.Dequeue("b")
+		assert.Nil(err)
+		assert.Equal([]byte("b2"), front)
+
+		_, err = mq.Dequeue("missing")
+		assert.Equal(ErrQueueEmpty, err)
+	})
+
+	t.Run("a full sub-queue does not affect its siblings", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		mq, err := NewMultiQueue(f)
+		assert.Nil(err)
+
+		large := nBytes(1500)
+		assert.Nil(mq.Enqueue("a", large))
+		assert.Nil(mq.Enqueue("a", large))
+		assert.Equal(ErrQueueFull, mq.Enqueue("a", large))
+
+		assert.Nil(mq.Enqueue("b", large))
+		assert.Nil(mq.Enqueue("b", large))
+
+		for _, name := range []string{"a", "b"} {
+			for i := 0; i < 2; i++ {
+				front, err := mq.Dequeue(name)
+				assert.Nil(err)
+				assert.Equal(large, front)
+			}
+		}
+	})
+
+	t.Run("reopening recovers all sub-queues", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		mq, err := NewMultiQueue(f)
+		assert.Nil(err)
+
+		assert.Nil(mq.Enqueue("a", []byte("a1")))
+		assert.Nil(mq.Enqueue("b", []byte("b1")))
+		assert.Nil(mq.Enqueue("b", []byte("b2")))
+
+		mq, err = NewMultiQueue(f)
+		assert.Nil(err)
+		assert.Equal([]string{"a", "b"}, mq.Names())
+
+		front, err := mq.Dequeue("a")
+		assert.Nil(err)
+		assert.Equal([]byte("a1"), front)
+
+		// new sub-queues are placed after recovered ones
+		assert.Nil(mq.Enqueue("c", []byte("c1")))
+
+		for _, want := range []string{"b1", "b2"} {
+			front, err := mq.Dequeue("b")
+			assert.Nil(err)
+			assert.Equal([]byte(want), front)
+		}
+
+		front, err = mq.Dequeue("c")
+		assert.Nil(err)
+		assert.Equal([]byte("c1"), front)
+	})
+
+	t.Run("rejects files without a directory", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		NewQueue(f).Enqueue([]byte("not a multiqueue"))
+
+		_, err = NewMultiQueue(f)
+		assert.NotNil(err)
+	})
+}
@@ -0,0 +1,27 @@
+package queue
+
+// Observer receives notifications about queue operations, for example to
+// feed external metrics or tracing systems
+//
+// Methods are called synchronously while the queue is locked, so they
+// should return quickly and must not call back into the queue.
+type Observer interface {
+	// OnEnqueue is called after an element of size bytes is enqueued
+	OnEnqueue(size int)
+
+	// OnDequeue is called after an element of size bytes is dequeued
+	OnDequeue(size int)
+
+	// OnFull is called when an enqueue is rejected with ErrQueueFull
+	OnFull()
+
+	// OnEmpty is called when a dequeue is rejected with ErrQueueEmpty
+	OnEmpty()
+}
+
+// WithObserver notifies obs of every enqueue and dequeue
+func WithObserver(obs Observer) Option {
+	return func(ls *Queue) {
+		ls.observer = obs
+	}
+}
@@ -0,0 +1,50 @@
+package queue
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingObserver records the number of calls to each Observer method
+type countingObserver struct {
+	enqueues, dequeues int
+	enqueued, dequeued int // total bytes
+	fulls, empties     int
+}
+
+func (o *countingObserver) OnEnqueue(size int) { o.enqueues++; o.enqueued += size }
+func (o *countingObserver) OnDequeue(size int) { o.dequeues++; o.dequeued += size }
+func (o *countingObserver) OnFull()            { o.fulls++ }
+func (o *countingObserver) OnEmpty()           { o.empties++ }
+
+func TestObserver(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := ioutil.TempFile("", "test-*")
+	assert.Nil(err)
+
+	obs := &countingObserver{}
+	q := NewQueue(f, WithCapacity(headerLength+32), WithObserver(obs))
+
+	assert.Nil(q.Enqueue(nBytes(10)))
+	assert.Nil(q.Enqueue(nBytes(12)))
+	assert.Equal(ErrQueueFull, q.Enqueue(nBytes(10)))
+
+	for i := 0; i < 2; i++ {
+		_, err := q.Dequeue()
+		assert.Nil(err)
+	}
+	_, err = q.Dequeue()
+	assert.Equal(ErrQueueEmpty, err)
+
+	assert.Equal(&countingObserver{
+		enqueues: 2,
+		dequeues: 2,
+		enqueued: 22,
+		dequeued: 22,
+		fulls:    1,
+		empties:  1,
+	}, obs)
+}
@@ -0,0 +1,36 @@
+package queue
+
+import (
+	"os"
+)
+
+// Open returns a Queue backed by the file at path, creating the file if it
+// does not exist
+//
+// The file is closed if the queue cannot be initialized, and otherwise
+// when the Queue is closed.
+func Open(path string, opts ...Option) (*Queue, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	q, err := New(f, opts...)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// MustOpen returns a Queue backed by the file at path like Open, but
+// panics if the file cannot be opened or the queue cannot be initialized
+func MustOpen(path string, opts ...Option) *Queue {
+	q, err := Open(path, opts...)
+	if err != nil {
+		panic(err)
+	}
+
+	return q
+}
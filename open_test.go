@@ -0,0 +1,44 @@
+package queue
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMustOpen(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "test-*")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+
+	t.Run("good file", func(t *testing.T) {
+		path := filepath.Join(dir, "good")
+
+		q := MustOpen(path)
+		assert.Nil(q.Enqueue([]byte("a")))
+		assert.Nil(q.Close())
+
+		q = MustOpen(path)
+		v, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Equal([]byte("a"), v)
+		assert.Nil(q.Close())
+	})
+
+	t.Run("corrupt file", func(t *testing.T) {
+		path := filepath.Join(dir, "corrupt")
+		assert.Nil(ioutil.WriteFile(path, nBytes(int(headerLength)), 0644))
+
+		_, err := Open(path)
+		assert.Equal(ErrInvalidHeader, err)
+
+		assert.Panics(func() {
+			MustOpen(path)
+		})
+	})
+}
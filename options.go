@@ -0,0 +1,73 @@
+package queue
+
+// Option configures optional behavior of a Queue
+type Option func(*Queue)
+
+// WithCapacity sets the total length in bytes, including the file header,
+// of the buffer backing a newly created queue
+//
+// The capacity of an existing queue is read from its file header, so this
+// option has no effect when reopening a queue.
+func WithCapacity(capacity uint32) Option {
+	return func(ls *Queue) {
+		ls.capacity = capacity
+	}
+}
+
+// WithHeaderPadding pads the metadata at the start of a newly created
+// queue file, such as the header and any user metadata block, so that the
+// element region starts on a multiple of blockSize, which must be a power
+// of two
+//
+// The block size is recorded in the file header, so reopening a queue
+// keeps its layout regardless of this option.
+func WithHeaderPadding(blockSize uint32) Option {
+	return func(ls *Queue) {
+		ls.headerPadding = blockSize
+	}
+}
+
+// WithInitialElements seeds a newly created queue with vs in order
+//
+// The option has no effect when reopening an existing queue, so the
+// elements are never enqueued twice.
+func WithInitialElements(vs [][]byte) Option {
+	return func(ls *Queue) {
+		ls.initialElements = vs
+	}
+}
+
+// WithMaxElements limits the queue to n elements, so that an enqueue into a
+// queue already holding n elements is handled like one into a queue out of
+// space, however many bytes are free
+//
+// The limit is not recorded in the file and applies only while the queue
+// is open with this option; a queue reopened with a lower limit than its
+// current length rejects enqueues until enough elements are removed.
+func WithMaxElements(n uint32) Option {
+	return func(ls *Queue) {
+		ls.maxElements = n
+	}
+}
+
+// WithNoResetOnEmpty keeps the head and tail where they are when the queue
+// is drained, instead of moving both back to the front of the buffer
+//
+// New elements then continue to be written after the last one, wrapping to
+// the front of the buffer only once they no longer fit at the end, which
+// spreads writes evenly across the buffer.
+func WithNoResetOnEmpty() Option {
+	return func(ls *Queue) {
+		ls.noResetOnEmpty = true
+	}
+}
+
+// WithReadOnly opens an existing queue for inspection only
+//
+// Opening an empty file fails, and every operation that would write to
+// the file, such as Enqueue and Dequeue, returns ErrReadOnly.
+func WithReadOnly() Option {
+	return func(ls *Queue) {
+		ls.readOnly = true
+	}
+}
@@ -0,0 +1,64 @@
+package queue
+
+// WithOverwriteOldest makes Enqueue evict elements from the head of the
+// queue until a new element fits instead of returning ErrQueueFull, giving
+// the queue ring buffer semantics
+func WithOverwriteOldest() Option {
+	return func(ls *Queue) {
+		ls.overwriteOldest = true
+	}
+}
+
+// Evicted returns the number of elements dropped from the head of the queue
+// to make room for new elements since the queue was opened
+func (ls *Queue) Evicted() uint64 {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	return ls.evicted
+}
+
+// evict drops elements from the head of the queue until an element of
+// bytesNeeded bytes fits, persisting the new head before returning so
+// that the evicted region can be safely overwritten
+func (ls *Queue) evict(bytesNeeded uint32) error {
+	original := ls.header
+
+	var freed [][2]uint32
+	var evicted [][]byte
+	for {
+		if _, ok := ls.reserve(bytesNeeded); ok {
+			break
+		}
+
+		body, frameLength, err := ls.readElement(ls.header.headPosition, ls.header.fileLength)
+		if err != nil {
+			ls.header = original
+			return err
+		}
+
+		freedStart, freedEnd := ls.advanceHead(frameLength)
+		freed = append(freed, [2]uint32{freedStart, freedEnd})
+		evicted = append(evicted, body)
+	}
+
+	if err := ls.syncHeader(); err != nil {
+		ls.header = original
+		return err
+	}
+	ls.evicted += uint64(len(freed))
+
+	for _, body := range evicted {
+		ls.removeSpilled(body)
+	}
+
+	if ls.zeroOnDequeue {
+		for _, region := range freed {
+			if err := ls.zero(region[0], region[1]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package queue
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverwriteOldest(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("sustained enqueues retain the newest elements", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f, WithOverwriteOldest())
+
+		const total = 500
+		for i := 0; i < total; i++ {
+			assert.Nil(q.Enqueue([]byte(fmt.Sprintf("element-%04d", i))))
+		}
+
+		retained := int(q.header.queueSize)
+		assert.Equal(uint64(total-retained), q.Evicted())
+
+		for i := total - retained; i < total; i++ {
+			front, err := q.Dequeue()
+			assert.Nil(err)
+			assert.Equal([]byte(fmt.Sprintf("element-%04d", i)), front)
+		}
+
+		_, err = q.Dequeue()
+		assert.Equal(ErrQueueEmpty, err)
+	})
+
+	t.Run("evictions survive reopen", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f, WithOverwriteOldest())
+
+		large := nBytes(1500)
+		assert.Nil(q.Enqueue([]byte("oldest")))
+		assert.Nil(q.Enqueue(large))
+		assert.Nil(q.Enqueue(large))
+		assert.Nil(q.Enqueue(large))
+		assert.Equal(uint64(2), q.Evicted())
+
+		q = NewQueue(f, WithOverwriteOldest())
+
+		for i := 0; i < 2; i++ {
+			front, err := q.Dequeue()
+			assert.Nil(err)
+			assert.Equal(large, front)
+		}
+
+		_, err = q.Dequeue()
+		assert.Equal(ErrQueueEmpty, err)
+	})
+
+	t.Run("full queue rejects without the option", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f)
+
+		large := nBytes(1500)
+		assert.Nil(q.Enqueue(large))
+		assert.Nil(q.Enqueue(large))
+		assert.Equal(ErrQueueFull, q.Enqueue(large))
+		assert.Equal(uint64(0), q.Evicted())
+	})
+}
@@ -0,0 +1,57 @@
+package queue
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrPaused = errors.New("dequeuing is paused")
+
+// Pause stops elements from being dequeued until Resume is called, while
+// still allowing enqueues
+//
+// While paused, Dequeue, DequeueContext, BlockingQueue.Take, and
+// subscriptions wait for Resume even when elements are present, and other
+// dequeues, such as TryDequeue, DequeueIf, and DequeueInto, return
+// ErrPaused. Draining on Close also returns ErrPaused. Pausing is not
+// persisted, so a reopened queue is not paused.
+func (ls *Queue) Pause() {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	ls.paused = true
+}
+
+// Resume allows elements to be dequeued again after Pause, waking any
+// dequeues waiting for it
+func (ls *Queue) Resume() {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	ls.paused = false
+	ls.cond.Broadcast()
+}
+
+// Paused reports whether dequeuing is paused
+func (ls *Queue) Paused() bool {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	return ls.paused
+}
+
+// awaitResume blocks while dequeuing is paused until Resume is called,
+// ctx is done, or the queue is closed
+//
+// Queue.mu must be held by the caller
+func (ls *Queue) awaitResume(ctx context.Context) error {
+	for ls.paused {
+		if ls.isClosed() {
+			return ErrClosed
+		}
+		if err := ls.wait(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPause(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("paused queue blocks a consumer until resumed", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+		q.Pause()
+		assert.True(q.Paused())
+
+		// enqueues are still allowed
+		assert.Nil(q.Enqueue([]byte("a")))
+		assert.Nil(q.Enqueue([]byte("b")))
+
+		done := make(chan []byte)
+		go func() {
+			v, err := q.Dequeue()
+			assert.Nil(err)
+			done <- v
+		}()
+
+		select {
+		case <-done:
+			t.Fatal("dequeue should block while the queue is paused")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		q.Resume()
+		assert.False(q.Paused())
+
+		select {
+		case v := <-done:
+			assert.Equal([]byte("a"), v)
+		case <-time.After(time.Second):
+			t.Fatal("dequeue should unblock after Resume")
+		}
+	})
+
+	t.Run("subscriptions wait until resumed", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		ch, err := q.Subscribe(ctx)
+		assert.Nil(err)
+
+		q.Pause()
+		assert.Nil(q.Enqueue([]byte("a")))
+
+		select {
+		case v, ok := <-ch:
+			t.Fatalf("subscription should wait while the queue is paused, got %q, open %v", v, ok)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		q.Resume()
+
+		select {
+		case v, ok := <-ch:
+			assert.True(ok)
+			assert.Equal([]byte("a"), v)
+		case <-time.After(time.Second):
+			t.Fatal("subscription should deliver after Resume")
+		}
+	})
+
+	t.Run("non-blocking dequeues fail", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+		assert.Nil(q.Enqueue([]byte("a")))
+		q.Pause()
+
+		_, _, err := q.TryDequeue()
+		assert.Equal(ErrPaused, err)
+		_, _, err = q.DequeueIf(func([]byte) bool { return true })
+		assert.Equal(ErrPaused, err)
+		_, err = q.DequeueInto(make([]byte, 8))
+		assert.Equal(ErrPaused, err)
+		assert.Equal(1, q.Len())
+	})
+
+	t.Run("cursors still reclaim space", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithCursors(1))
+		c, err := q.NewCursor()
+		assert.Nil(err)
+		assert.Nil(q.Enqueue([]byte("a")))
+		q.Pause()
+
+		v, err := c.Next()
+		assert.Nil(err)
+		assert.Equal([]byte("a"), v)
+		assert.Equal(0, q.Len())
+	})
+
+	t.Run("waiting dequeues give up", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+		assert.Nil(q.Enqueue([]byte("a")))
+		q.Pause()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err := q.DequeueContext(ctx)
+		assert.Equal(context.DeadlineExceeded, err)
+
+		done := make(chan error)
+		go func() {
+			_, err := q.Dequeue()
+			done <- err
+		}()
+		time.Sleep(20 * time.Millisecond)
+		assert.Nil(q.Close())
+
+		select {
+		case err := <-done:
+			assert.Equal(ErrClosed, err)
+		case <-time.After(time.Second):
+			t.Fatal("dequeue should unblock after Close")
+		}
+	})
+}
@@ -0,0 +1,22 @@
+package queue
+
+// WithPreallocate extends a newly created queue file to its full capacity
+// so that later enqueues write into space that is already allocated
+//
+// The file is extended with Truncate when the backing store supports it
+// and by writing zeros otherwise. The option has no effect when reopening
+// an existing queue.
+func WithPreallocate() Option {
+	return func(ls *Queue) {
+		ls.preallocate = true
+	}
+}
+
+// allocate extends Queue.rws to the full length of the buffer
+func (ls *Queue) allocate() error {
+	if t, ok := ls.rws.(interface{ Truncate(int64) error }); ok {
+		return t.Truncate(int64(ls.header.fileLength))
+	}
+
+	return ls.zero(0, ls.header.fileLength)
+}
@@ -1,35 +1,145 @@
 package queue
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
 )
 
 const (
-	headerLength        uint32 = 16 // 16 bytes
-	elementHeaderLength uint32 = 8  // 4 next pointer bytes + 4 size bytes
+	defaultCapacity     uint32 = 4096
+	headerLength        uint32 = 2 * headerSlotLength // two header slots
+	elementHeaderLength uint32 = 8                    // 4 next pointer bytes + 4 size bytes
 )
 
 var (
 	ErrQueueFull  = errors.New("queue is full")
 	ErrQueueEmpty = errors.New("cannot dequeue from empty queue")
+
+	ErrInvalidOffset    = errors.New("offset is outside of the element region")
+	ErrIndexOutOfRange  = errors.New("index is out of range")
+	ErrElementTooLarge  = errors.New("element is too large to enqueue")
+	ErrTruncatedElement = errors.New("element is truncated")
+	ErrCorruptElement   = errors.New("element length is corrupt")
+	ErrInvalidHeader    = errors.New("no valid queue header found")
+	ErrReadOnly         = errors.New("queue is read-only")
+	ErrCapacityTooSmall = errors.New("capacity is too small to hold the queue header and an element")
+	ErrCorrupt          = errors.New("queue file is corrupt")
 )
 
 // Queue is a FIFO queue backed by a file
+//
+// A Queue is safe for concurrent use by multiple goroutines.
 type Queue struct {
-	rws    io.ReadWriteSeeker
-	header fileHeader // cached file header
+	stats counters // accessed atomically; kept first for 64-bit alignment
+
+	mu        sync.Mutex
+	cond      *sync.Cond    // signalled whenever elements are enqueued or dequeued
+	closed    chan struct{} // closed by Close
+	rws       io.ReadWriteSeeker
+	ra        io.ReaderAt // set when rws supports positioned reads
+	wa        io.WriterAt // set when rws supports positioned writes
+	header    fileHeader  // cached file header
+	headerSeq uint64      // sequence number of the most recently written header
+	durable   fileHeader  // most recently written file header
+	unsynced  int         // header updates not yet written, with checkpointEvery
+
+	framer           Framer             // lays out element bodies in the file
+	codec            CompressionCodec   // optional payload compression
+	zeroOnDequeue    bool               // overwrite dequeued elements with zeros
+	overwriteOldest  bool               // evict head elements instead of rejecting enqueues when full
+	capacity         uint32             // buffer length used when creating a new queue file
+	initialElements  [][]byte           // elements enqueued when creating a new queue file
+	readOnly         bool               // reject every operation that writes to the file
+	preallocate      bool               // extend a new queue file to its full capacity
+	spillDir         string             // directory holding elements too large for the buffer
+	limiter          *rate.Limiter      // optional pacing of dequeues
+	tee              *Queue             // secondary queue mirroring enqueues
+	teePolicy        TeePolicy          // handling of failed enqueues to tee
+	drain            func([]byte) error // receives remaining elements on Close
+	alignment        uint32             // element frames are padded to a multiple of this length
+	userHeaderLength uint32             // user metadata block reserved when creating a new queue file
+	byteOrder        binary.ByteOrder   // byte order used when creating a new queue file
+	retryAttempts    int                // retries of failed reads and writes
+	retryBackoff     time.Duration      // wait before the first retry
+	observer         Observer           // optional operation notifications
+	fullPolicy       FullPolicy         // decides how Enqueue handles a full queue
+	verifyWrites     bool               // read back every write to check it
+	compactOnOpen    bool               // compact an existing queue file when opening it
+	compactOnClose   bool               // shrink the queue file to its live contents on Close
+	appendOnly       bool               // never reuse space freed by dequeues
+	subscribed       bool               // a Subscribe channel is active
+	sequenceNumbers  bool               // number the elements of a new queue file
+	firstSequence    uint64             // sequence number of the first element of a new queue file
+	timestamps       bool               // timestamp the elements of a new queue file
+	tags             bool               // tag the elements of a new queue file
+	consumerOffset   bool               // keep dequeued elements of a new queue file
+	elementSize      uint32             // fixed payload length of a new queue file, or 0
+	noResetOnEmpty   bool               // keep head and tail in place when the queue empties
+	paused           bool               // dequeues wait or fail until Resume
+	maxElements      uint32             // maximum number of elements, or 0 for no limit
+	headerPadding    uint32             // block size the element region of a new queue file starts on
+	alert            *utilizationAlert  // optional utilization threshold callback
+	latency          *latencies         // optional Enqueue and Dequeue durations
+	cursorSlots      uint32             // cursor slots reserved when creating a new queue file
+	cursors          []*Cursor          // open cursors by slot, nil for free slots
+	tracked          []tracked          // elements from EnqueueTracked in FIFO order
+	consumer         *Cursor            // elements read by Dequeue, with a consumer offset
+	checkpointEvery  int                // header updates per header write, or 0 to write every update
+	validator        func([]byte) error // rejects malformed elements on dequeue
+	deadLetter       *Queue             // receives elements rejected by validator
+
+	evicted     uint64 // number of elements evicted by overwriteOldest
+	headMoves   uint64 // changes whenever elements are removed or relocated
+	relocations uint64 // changes whenever live elements are moved
 }
 
-func NewQueue(f io.ReadWriteSeeker) *Queue {
-	q := &Queue{rws: f}
+// NewQueue returns a Queue backed by f like New, but panics if the queue
+// cannot be initialized
+func NewQueue(f io.ReadWriteSeeker, opts ...Option) *Queue {
+	q, err := New(f, opts...)
+	if err != nil {
+		panic(err)
+	}
+
+	return q
+}
+
+// New returns a Queue backed by f, writing a fresh header if f is empty
+// and loading the existing queue otherwise
+func New(f io.ReadWriteSeeker, opts ...Option) (*Queue, error) {
+	q := newQueue(f, opts)
 
 	// initialize queue state
 	if err := q.init(); err != nil {
-		panic(err)
+		return nil, err
+	}
+	if q.alert != nil {
+		q.alert.above = q.utilization() >= q.alert.threshold
 	}
 
+	return q, nil
+}
+
+// newQueue returns a Queue over f with defaults and opts applied
+// that has not yet been initialized
+func newQueue(f io.ReadWriteSeeker, opts []Option) *Queue {
+	q := &Queue{rws: f, framer: lengthPrefixFramer{}, capacity: defaultCapacity, firstSequence: 1}
+	q.cond = sync.NewCond(&q.mu)
+	q.closed = make(chan struct{})
+	q.ra, _ = f.(io.ReaderAt)
+	q.wa, _ = f.(io.WriterAt)
+	for _, opt := range opts {
+		opt(q)
+	}
 	return q
 }
 
@@ -37,37 +147,146 @@ func NewQueue(f io.ReadWriteSeeker) *Queue {
 func (ls *Queue) init() error {
 	ls.header = ls.defaultFileHeader()
 
-	header, err := ls.readHeader()
+	header, seq, err := ls.readHeader()
+	if err == io.EOF && ls.readOnly {
+		return fmt.Errorf("%w: file does not contain a queue", ErrReadOnly)
+	}
 	if err == io.EOF {
 		// if here we are initializing for the first time
 		// and need to write the default header
-		return ls.syncHeader()
+		if ls.headerPadding != 0 && bits.OnesCount32(ls.headerPadding) != 1 {
+			return fmt.Errorf("header padding block size %d is not a power of two", ls.headerPadding)
+		}
+		if min := ls.dataStart() + elementHeaderLength; ls.capacity < min {
+			return fmt.Errorf("%w: capacity of %d bytes is below the minimum of %d bytes", ErrCapacityTooSmall, ls.capacity, min)
+		}
+
+		if ls.byteOrder != nil && ls.byteOrder != binary.BigEndian && ls.byteOrder != binary.LittleEndian {
+			return fmt.Errorf("unsupported byte order %v", ls.byteOrder)
+		}
+		if err := ls.adoptFraming(); err != nil {
+			return err
+		}
+
+		if ls.preallocate {
+			if err := ls.allocate(); err != nil {
+				return err
+			}
+		}
+
+		if err := ls.zero(headerLength, ls.dataStart()); err != nil {
+			return err
+		}
+
+		if err := ls.syncHeader(); err != nil {
+			return err
+		}
+		if err := ls.loadConsumer(); err != nil {
+			return err
+		}
+
+		for _, v := range ls.initialElements {
+			if _, err := ls.enqueue(v); err != nil {
+				return err
+			}
+		}
+
+		return nil
 	}
 
+	if err == ErrInvalidHeader {
+		if _, _, legacyErr := ls.readLegacy(); legacyErr == nil {
+			return fmt.Errorf("%w: file has a legacy header, upgrade it with Migrate from LegacyVersion", err)
+		}
+	}
 	if err != nil {
 		return err
 	}
 
 	ls.header = header
+	ls.headerSeq = seq
+	ls.durable = header
+	if err := ls.checkFlagged(); err != nil {
+		return err
+	}
+	if err := ls.adoptFraming(); err != nil {
+		return err
+	}
+	if err := ls.loadCursors(); err != nil {
+		return err
+	}
+	if err := ls.loadConsumer(); err != nil {
+		return err
+	}
+
+	if ls.compactOnOpen && !ls.readOnly && !ls.appendOnly && ls.fragmented() {
+		return ls.compact()
+	}
+
 	return nil
 }
 
-// syncHeader writes the in-memory queue header to Queue.rws
-func (ls *Queue) syncHeader() error {
-	// Build header buffer
-	var headerBytes [16]byte
-	binary.BigEndian.PutUint32(headerBytes[:4], ls.header.fileLength)
-	binary.BigEndian.PutUint32(headerBytes[4:8], ls.header.queueSize)
-	binary.BigEndian.PutUint32(headerBytes[8:12], ls.header.headPosition)
-	binary.BigEndian.PutUint32(headerBytes[12:], ls.header.tailPosition)
+// Capacity returns the total length in bytes of the buffer backing the
+// queue, including the file header
+func (ls *Queue) Capacity() uint32 {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
 
-	// Write header
-	if _, err := ls.rws.Seek(0, io.SeekStart); err != nil {
+	return ls.header.fileLength
+}
+
+// Flush writes the in-memory queue header to Queue.rws and, if the
+// backing store supports it, commits its contents to stable storage
+func (ls *Queue) Flush() error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if err := ls.writeHeader(); err != nil {
 		return err
 	}
 
-	if _, err := ls.rws.Write(headerBytes[:]); err != nil {
-		return err
+	if s, ok := ls.rws.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+
+	return nil
+}
+
+// Close drains any remaining elements if WithDrainOnClose is set, shrinks
+// the file to its live contents if WithCompactOnClose is set, and then
+// closes the backing store if it implements io.Closer
+//
+// If draining or shrinking fails the backing store is left open and the
+// error is returned. Otherwise any Subscribe channel is closed.
+func (ls *Queue) Close() error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if ls.drain != nil && !ls.readOnly {
+		if err := ls.drainAll(); err != nil {
+			return err
+		}
+	}
+
+	if ls.compactOnClose && !ls.readOnly {
+		if err := ls.shrinkWrap(); err != nil {
+			return err
+		}
+	}
+
+	if !ls.readOnly {
+		if err := ls.checkpoint(); err != nil {
+			return err
+		}
+	}
+
+	if !ls.isClosed() {
+		close(ls.closed)
+		ls.cond.Broadcast()
+	}
+
+	if c, ok := ls.rws.(io.Closer); ok {
+		return c.Close()
 	}
 
 	return nil
@@ -79,143 +298,521 @@ func (ls *Queue) syncHeader() error {
 // nearest boundary, where the boundary is either the end of the file
 // or the position of the head element
 func (ls *Queue) Enqueue(v []byte) error {
-	bytesNeeded := uint32(4 + len(v))
-	if bytesNeeded > ls.header.fileLength {
-		return errors.New("element is too large to enqueue")
-	}
-
-	// check for queue fullness and seek to the appropriate position
-	// when we can accept a write
-	//
-	// queue is full if there is neither space at
-	// the end of the buffer nor at the front of the buffer
-	//
-	// writes do not wrap around the end of the buffer
-	// to avoid needing to write twice
-	var writePosition int64
-	if bytesNeeded <= ls.tailSpaceAvailable() {
-		writePosition = int64(ls.header.tailPosition)
-	} else if bytesNeeded <= ls.headSpaceAvailable() {
-		writePosition = int64(headerLength)
-	} else {
-		return ErrQueueFull
+	if ls.latency != nil {
+		defer ls.latency.enqueue.since(time.Now())
 	}
 
-	if _, err := ls.rws.Seek(writePosition, io.SeekStart); err != nil {
-		return err
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	_, err := ls.enqueueWithPolicy(v, nil)
+	return err
+}
+
+// errUnstableOffsets is returned when elements are indexed by offset in a
+// queue that may evict them or keep them after they are dequeued
+var errUnstableOffsets = errors.New("elements cannot be indexed by offset in a queue that evicts elements or has a consumer offset")
+
+// checkIndexable returns errUnstableOffsets if the queue may evict
+// elements to make room, or keeps dequeued elements in place with a
+// consumer offset, either of which would leave an index of the offsets
+// returned by EnqueueAt pointing at the wrong elements
+func (ls *Queue) checkIndexable() error {
+	if ls.overwriteOldest || ls.fullPolicy != nil || ls.consumer != nil {
+		return errUnstableOffsets
 	}
+	return nil
+}
 
-	// Write new queue element
-	elem := make([]byte, bytesNeeded)
-	binary.BigEndian.PutUint32(elem[:4], uint32(len(v)))
-	copy(elem[4:], v)
-	n, err := ls.rws.Write(elem)
+// EnqueueAt adds a value to the queue like Enqueue and returns the absolute
+// offset in the backing file at which the element's frame was written
+//
+// An offset only identifies the element while it remains in the queue;
+// once the element is dequeued its space may be reused by later elements,
+// unless the queue was opened with WithAppendOnly.
+// Operations that relocate elements within the file also invalidate
+// previously returned offsets.
+func (ls *Queue) EnqueueAt(v []byte) (uint32, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	return ls.enqueueWithPolicy(v, nil)
+}
+
+func (ls *Queue) enqueue(v []byte) (uint32, error) {
+	return ls.enqueueEvict(v, nil, ls.overwriteOldest)
+}
+
+// enqueueEvict adds v, tagged with tags, to the queue, evicting head
+// elements to make room if evict is set
+func (ls *Queue) enqueueEvict(v []byte, tags map[string]string, evict bool) (uint32, error) {
+	if ls.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	frame, body, err := ls.frameElement(v, tags)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	// Update local file header
-	ls.header.tailPosition += uint32(n)
-	ls.header.queueSize += 1
+	offset, err := ls.write(frame, v, tags, evict)
+	if err != nil {
+		// the spilled payload is unreachable if its reference was not written
+		ls.removeSpilled(body)
+		return 0, err
+	}
 
-	// Sync header updates to finalize the write
-	if err := ls.syncHeader(); err != nil {
-		return err
+	ls.recordEnqueue(len(v))
+
+	return offset, nil
+}
+
+// frameElement returns the frame holding v, along with tags in a tagged
+// queue, and the element body within it, spilling v to a separate file if
+// its frame would not fit in the buffer
+func (ls *Queue) frameElement(v []byte, tags map[string]string) (frame, body []byte, err error) {
+	if size := ls.header.elementSize; size != 0 && uint32(len(v)) != size {
+		return nil, nil, fmt.Errorf("%w: %d byte element in a queue of %d byte elements", ErrElementSize, len(v), size)
+	}
+	if ls.tagged() {
+		v = appendTags(nil, tags, v)
 	}
 
-	return nil
+	body, err = ls.encodeElement(v)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	body = ls.number(body)
+	frame = ls.pad(ls.framer.Frame(body))
+	if uint32(len(frame)) > ls.header.fileLength-ls.dataStart() && ls.spillDir != "" {
+		if body, err = ls.spill(v); err != nil {
+			return nil, nil, err
+		}
+		body = ls.number(body)
+		frame = ls.pad(ls.framer.Frame(body))
+	}
+
+	return frame, body, nil
+}
+
+// write appends frame, which holds v and its tags, at the tail of the
+// queue, evicting head elements to make room if evict is set, and returns
+// the offset at which it was written
+func (ls *Queue) write(frame, v []byte, tags map[string]string, evict bool) (uint32, error) {
+	offset, prev, err := ls.append(frame, evict)
+	if err != nil {
+		return 0, err
+	}
+
+	// Sync header updates to finalize the write, abandoning the element
+	// if the header on disk could not be updated
+	if err := ls.commitMirrored(prev, func() error { return ls.mirror(v, tags) }); err != nil {
+		return 0, err
+	}
+
+	return offset, nil
+}
+
+// append writes frame at the tail of the queue and updates the in-memory
+// header to include it, evicting head elements to make room if allowed,
+// and returns the header from just before the element was added
+//
+// The element is not visible in the file until the header is synced.
+func (ls *Queue) append(frame []byte, evict bool) (uint32, fileHeader, error) {
+	return ls.appendFunc(uint32(len(frame)), evict, func(offset uint32) error {
+		if _, err := ls.writeAt(frame, int64(offset)); err != nil {
+			return ioError(OpElementWrite, int64(offset), err)
+		}
+		return ioError(OpElementWrite, int64(offset), ls.verifyWrite(frame, int64(offset)))
+	})
+}
+
+// appendFunc is like append, but calls write to write the bytesNeeded
+// bytes of the frame at offset
+func (ls *Queue) appendFunc(bytesNeeded uint32, evict bool, write func(offset uint32) error) (uint32, fileHeader, error) {
+	if limit := ls.header.fileLength - ls.dataStart(); bytesNeeded > limit {
+		return 0, fileHeader{}, fmt.Errorf("%w: frame of %d bytes exceeds the %d byte element region", ErrElementTooLarge, bytesNeeded, limit)
+	}
+
+	header, ok := ls.reserve(bytesNeeded)
+	if !ok {
+		// evicting elements frees no space in an append-only queue
+		if !evict || ls.appendOnly {
+			atomic.AddUint64(&ls.stats.fullRejects, 1)
+			if ls.observer != nil {
+				ls.observer.OnFull()
+			}
+			return 0, fileHeader{}, ErrQueueFull
+		}
+
+		if err := ls.evict(bytesNeeded); err != nil {
+			return 0, fileHeader{}, err
+		}
+		header, _ = ls.reserve(bytesNeeded)
+	}
+
+	offset := header.tailPosition
+	if err := ls.protect(offset, offset+bytesNeeded); err != nil {
+		return 0, fileHeader{}, err
+	}
+
+	// Write new queue element
+	if err := write(offset); err != nil {
+		return 0, fileHeader{}, err
+	}
+
+	// Update local file header
+	prev := ls.header
+	header.tailPosition += bytesNeeded
+	header.queueSize += 1
+	if ls.sequenced() {
+		header.nextSequence++
+	}
+	ls.header = header
+
+	return offset, prev, nil
 }
 
 // Dequeue and return the item at the front of the queue
+//
+// While dequeuing is paused, Dequeue waits until Resume is called or the
+// queue is closed.
 func (ls *Queue) Dequeue() ([]byte, error) {
-	if ls.header.queueSize == 0 {
-		return nil, ErrQueueEmpty
+	if err := ls.pace(context.Background()); err != nil {
+		return nil, err
 	}
 
-	// Seek to first element
-	if _, err := ls.rws.Seek(int64(ls.header.headPosition), io.SeekStart); err != nil {
-		return nil, err
+	if ls.latency != nil {
+		defer ls.latency.dequeue.since(time.Now())
 	}
 
-	// Read element length from its header
-	var elementHeader [4]byte
-	if _, err := ls.rws.Read(elementHeader[:]); err != nil {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if err := ls.awaitResume(context.Background()); err != nil {
 		return nil, err
 	}
 
-	// Read element data
-	elementLength := binary.BigEndian.Uint32(elementHeader[:])
-	elementData := make([]byte, elementLength)
-	if _, err := ls.rws.Read(elementData[:]); err != nil {
-		return nil, err
+	return ls.dequeue()
+}
+
+// DequeueIf removes and returns the item at the front of the queue only if
+// pred returns true for it
+//
+// If pred returns false the element is left in place and DequeueIf returns
+// (nil, false, nil).
+func (ls *Queue) DequeueIf(pred func([]byte) bool) ([]byte, bool, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	return ls.dequeueIf(pred)
+}
+
+// TryDequeue removes and returns the item at the front of the queue,
+// returning false instead of ErrQueueEmpty when the queue is empty
+//
+// TryDequeue never blocks: with a dequeue rate limit it also returns false
+// when no dequeue is currently allowed. Errors are reserved for failures
+// such as I/O errors.
+func (ls *Queue) TryDequeue() ([]byte, bool, error) {
+	if ls.limiter != nil && !ls.limiter.Allow() {
+		return nil, false, nil
 	}
 
-	ls.header.headPosition += elementLength + 4 // head position moves the length of the removed element plus its header
-	ls.header.queueSize -= 1
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	v, err := ls.dequeue()
+	if err == ErrQueueEmpty {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return v, true, nil
+}
+
+func (ls *Queue) dequeue() ([]byte, error) {
+	v, _, err := ls.dequeueIf(nil)
+	return v, err
+}
+
+// dequeueIf removes the head element if pred is nil or returns true for it
+func (ls *Queue) dequeueIf(pred func([]byte) bool) ([]byte, bool, error) {
+	v, _, _, ok, err := ls.dequeueNumbered(pred)
+	return v, ok, err
+}
+
+// dequeueNumbered is like dequeueIf, but also returns the sequence number
+// of the element, which is 0 unless the queue is sequenced, and its tags,
+// which are nil unless the queue is tagged
+func (ls *Queue) dequeueNumbered(pred func([]byte) bool) ([]byte, uint64, map[string]string, bool, error) {
+	if ls.validator != nil {
+		return ls.dequeueValid(pred)
+	}
+	return ls.dequeueHead(pred)
+}
+
+// dequeueHead is dequeueNumbered without validation
+func (ls *Queue) dequeueHead(pred func([]byte) bool) ([]byte, uint64, map[string]string, bool, error) {
+	if err := ls.checkDequeue(); err != nil {
+		return nil, 0, nil, false, err
+	}
+
+	if ls.consumer != nil {
+		return ls.consumeNumbered(pred)
+	}
+
+	// Read first element
+	elementData, frameLength, err := ls.readElement(ls.header.headPosition, ls.header.fileLength)
+	if err != nil {
+		return nil, 0, nil, false, err
+	}
+
+	_, seq, err := ls.unnumber(elementData)
+	if err != nil {
+		return nil, 0, nil, false, err
+	}
+
+	v, tags, err := ls.decodeTagged(elementData)
+	if err != nil {
+		return nil, 0, nil, false, err
+	}
+
+	// the header is untouched until the element is accepted
+	if pred != nil && !pred(v) {
+		return nil, 0, nil, false, nil
+	}
+
+	if err := ls.removeHead(frameLength); err != nil {
+		return nil, 0, nil, false, err
+	}
+	ls.removeSpilled(elementData)
+
+	ls.recordDequeue(len(v))
+
+	return v, seq, tags, true, nil
+}
 
-	if ls.header.queueSize == 0 {
-		ls.header = ls.defaultFileHeader()
+// checkDequeue returns the error, if any, that prevents dequeuing
+func (ls *Queue) checkDequeue() error {
+	if ls.readOnly {
+		return ErrReadOnly
 	}
 
-	// Sync header updates to finalize the write
+	if ls.paused {
+		return ErrPaused
+	}
+
+	if ls.unread() == 0 {
+		atomic.AddUint64(&ls.stats.emptyPolls, 1)
+		if ls.observer != nil {
+			ls.observer.OnEmpty()
+		}
+		return ErrQueueEmpty
+	}
+
+	return nil
+}
+
+// removeHead removes the head element, whose frame is frameLength bytes
+// long, and persists the new head
+func (ls *Queue) removeHead(frameLength uint32) error {
+	original := ls.header
+	freedStart, freedEnd := ls.advanceHead(frameLength)
+
+	// Sync header updates to finalize the write, keeping the element in
+	// the queue if the header on disk could not be updated
 	if err := ls.syncHeader(); err != nil {
-		return nil, err
+		ls.header = original
+		return err
+	}
+	ls.cond.Broadcast()
+
+	// Zero the freed region only once the header no longer references it,
+	// so that a crash in between cannot expose a zeroed head element
+	if ls.zeroOnDequeue {
+		if err := ls.zero(freedStart, freedEnd); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reserve returns the header describing where an element of
+// bytesNeeded bytes would be written, with tailPosition set to the
+// write position, or false if the queue is full
+//
+// queue is full if it holds the maximum number of elements, or if
+// there is neither space at the end of the buffer nor at the front
+// of the buffer
+//
+// writes do not wrap around the end of the buffer
+// to avoid needing to write twice; instead the position
+// at which live data stops is recorded so that dequeues
+// know when to jump back to the front of the buffer
+func (ls *Queue) reserve(bytesNeeded uint32) (fileHeader, bool) {
+	header := ls.header
+	if ls.maxElements != 0 && header.queueSize >= ls.maxElements {
+		return fileHeader{}, false
+	} else if bytesNeeded <= ls.tailSpaceAvailable() {
+		// write at the current tail
+	} else if !ls.appendOnly && header.queueSize == 0 {
+		// an empty queue that was not reset restarts at the front
+		header.headPosition = ls.dataStart()
+		header.tailPosition = ls.dataStart()
+	} else if !ls.appendOnly && bytesNeeded <= ls.headSpaceAvailable() {
+		header.wrapPosition = header.tailPosition
+		header.tailPosition = ls.dataStart()
+	} else {
+		return fileHeader{}, false
+	}
+	return header, true
+}
+
+// advanceHead moves the head past an element whose frame is frameLength
+// bytes long and returns the region of the buffer that it freed
+func (ls *Queue) advanceHead(frameLength uint32) (freedStart, freedEnd uint32) {
+	freedStart = ls.header.headPosition
+	freedEnd = freedStart + frameLength
+
+	ls.header.headPosition = freedEnd // head position moves the length of the removed element frame
+	ls.header.queueSize -= 1
+	ls.header.removed++
+	ls.headMoves++
+
+	// jump back to the front of the buffer once the elements
+	// at the end of a wrapped queue have been consumed
+	if ls.isWrapped() && ls.header.headPosition == ls.header.wrapPosition {
+		freedEnd = ls.header.fileLength
+		ls.header.headPosition = ls.dataStart()
+		ls.header.wrapPosition = 0
+	}
+
+	// reclaim the whole buffer once the queue is empty
+	if ls.header.queueSize == 0 && !ls.appendOnly && !ls.noResetOnEmpty {
+		ls.header.headPosition = ls.dataStart()
+		ls.header.tailPosition = ls.dataStart()
+		ls.header.wrapPosition = 0
 	}
 
-	return elementData, nil
+	return freedStart, freedEnd
+}
+
+// walk calls fn with the offset, frame length, and body of each live
+// element in FIFO order, stopping early if fn returns false
+func (ls *Queue) walk(fn func(pos, frameLength uint32, body []byte) bool) error {
+	return ls.walkFrom(ls.header.headPosition, ls.header.queueSize, fn)
+}
+
+// walkFrom is walk over the n live elements starting with the one whose
+// frame starts at pos
+func (ls *Queue) walkFrom(pos, n uint32, fn func(pos, frameLength uint32, body []byte) bool) error {
+	// elements before the head have already wrapped to the front
+	wrapped := ls.isWrapped() && pos >= ls.header.headPosition
+	for i := uint32(0); i < n; i++ {
+		if wrapped && pos == ls.header.wrapPosition {
+			pos = ls.dataStart()
+			wrapped = false
+		}
+
+		body, frameLength, err := ls.readElement(pos, ls.header.fileLength)
+		if err != nil {
+			return err
+		}
+
+		if !fn(pos, frameLength, body) {
+			return nil
+		}
+		pos += frameLength
+	}
+	return nil
+}
+
+// usedBytes returns the number of bytes occupied by live element frames
+func (ls *Queue) usedBytes() uint32 {
+	if ls.isWrapped() {
+		return ls.header.wrapPosition - ls.header.headPosition + ls.header.tailPosition - ls.dataStart()
+	}
+	return ls.header.tailPosition - ls.header.headPosition
+}
+
+// isWrapped reports whether live elements straddle the end of the buffer
+func (ls *Queue) isWrapped() bool {
+	return ls.header.wrapPosition != 0
 }
 
 func (ls *Queue) headSpaceAvailable() uint32 {
-	if ls.header.tailPosition < ls.header.headPosition {
+	if ls.isWrapped() {
 		return ls.header.headPosition - ls.header.tailPosition
 	}
-	return ls.header.headPosition - headerLength
+	return ls.header.headPosition - ls.dataStart()
 }
 
 func (ls *Queue) tailSpaceAvailable() uint32 {
 	// if queue is wrapped around the end of the buffer
-	if ls.header.tailPosition < ls.header.headPosition {
+	if ls.isWrapped() {
 		return ls.header.headPosition - ls.header.tailPosition
 	}
 	return ls.header.fileLength - ls.header.tailPosition
 }
 
 func (ls *Queue) defaultFileHeader() fileHeader {
-	return fileHeader{4096, 0, 16, 16}
-}
-
-func (ls *Queue) readHeader() (fileHeader, error) {
-	if _, err := ls.rws.Seek(0, io.SeekStart); err != nil {
-		return fileHeader{}, err
+	header := fileHeader{ls.capacity, 0, 0, 0, 0, ls.userHeaderLength, orderBigEndian, 0, 0, ls.elementSize, ls.cursorSlots, 0, 0}
+	if ls.headerPadding > 1 && bits.OnesCount32(ls.headerPadding) == 1 {
+		header.blockShift = byte(bits.TrailingZeros32(ls.headerPadding))
 	}
-
-	var headerBytes [16]byte
-	if _, err := io.ReadFull(ls.rws, headerBytes[:]); err != nil {
-		return fileHeader{}, err
+	if ls.sequenceNumbers {
+		header.flags |= headerSequenced
+		header.nextSequence = ls.firstSequence
 	}
-
-	return fileHeader{
-		fileLength:   binary.BigEndian.Uint32(headerBytes[:4]),
-		queueSize:    binary.BigEndian.Uint32(headerBytes[4:8]),
-		headPosition: binary.BigEndian.Uint32(headerBytes[8:12]),
-		tailPosition: binary.BigEndian.Uint32(headerBytes[12:]),
-	}, nil
+	if ls.timestamps {
+		header.flags |= headerTimestamped
+	}
+	if ls.tags {
+		header.flags |= headerTagged
+	}
+	if ls.consumerOffset {
+		header.flags |= headerConsumerOffset
+	}
+	if ls.codec != nil || ls.spillDir != "" {
+		header.flags |= headerFlagged
+	}
+	if ls.byteOrder == binary.LittleEndian {
+		header.byteOrder = orderLittleEndian
+	}
+	// the flags decide how much metadata precedes the elements
+	header.headPosition = header.dataStart()
+	header.tailPosition = header.dataStart()
+	return header
 }
 
-func (ls *Queue) readElementHeader(pos uint32) (uint32, error) {
-	if _, err := ls.rws.Seek(int64(pos), io.SeekStart); err != nil {
-		return 0, err
+// adoptFraming makes the framing follow the fixed element size or, for the
+// default framing, the byte order recorded in the header
+func (ls *Queue) adoptFraming() error {
+	if ls.header.elementSize != 0 {
+		if ls.flagged() {
+			return errors.New("fixed size elements cannot be compressed or spilled")
+		}
+		if ls.tagged() {
+			return errors.New("fixed size elements cannot be tagged")
+		}
+
+		length := ls.header.elementSize + ls.prefixLength()
+		ls.framer = fixedFramer{length: length}
+		return nil
 	}
-	var header [4]byte
-	if _, err := ls.rws.Read(header[:]); err != nil {
-		return 0, err
+
+	if _, ok := ls.framer.(lengthPrefixFramer); ok {
+		ls.framer = lengthPrefixFramer{order: ls.header.order()}
 	}
-	return binary.BigEndian.Uint32(header[:]), nil
+	return nil
 }
 
-type fileHeader struct {
-	fileLength   uint32 // total length of the buffer backing a queue
-	queueSize    uint32 // total number of elements in a queue
-	headPosition uint32 // offset at which the first-in element can be found
-	tailPosition uint32 // offset at which the last-in  element can be found
+// dataStart returns the offset at which the element region begins
+func (ls *Queue) dataStart() uint32 {
+	return ls.header.dataStart()
 }
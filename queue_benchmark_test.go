@@ -2,7 +2,9 @@ package queue
 
 import (
 	"crypto/rand"
+	"io"
 	"io/ioutil"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -55,3 +57,79 @@ func nBytes(n int) []byte {
 	rand.Read(bs)
 	return bs
 }
+
+// seekOnly hides the io.ReaderAt and io.WriterAt methods of the wrapped
+// file so that the queue falls back to seek-based I/O
+type seekOnly struct {
+	io.ReadWriteSeeker
+}
+
+func benchmarkRoundTrip(b *testing.B, wrap func(*os.File) io.ReadWriteSeeker, value []byte, opts ...Option) {
+	f, err := ioutil.TempFile("", "test-*")
+	assert := assert.New(b)
+	assert.Nil(err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	q := NewQueue(wrap(f), opts...)
+
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		assert.Nil(q.Enqueue(value))
+		_, err := q.Dequeue()
+		assert.Nil(err)
+	}
+}
+
+func positioned(f *os.File) io.ReadWriteSeeker { return f }
+func seeking(f *os.File) io.ReadWriteSeeker    { return seekOnly{f} }
+
+func BenchmarkRoundTripPositioned10(b *testing.B)  { benchmarkRoundTrip(b, positioned, nBytes(10)) }
+func BenchmarkRoundTripPositioned100(b *testing.B) { benchmarkRoundTrip(b, positioned, nBytes(100)) }
+func BenchmarkRoundTripSeek10(b *testing.B)        { benchmarkRoundTrip(b, seeking, nBytes(10)) }
+func BenchmarkRoundTripSeek100(b *testing.B)       { benchmarkRoundTrip(b, seeking, nBytes(100)) }
+
+func BenchmarkRoundTripFixed10(b *testing.B) {
+	benchmarkRoundTrip(b, positioned, nBytes(10), WithFixedElementSize(10))
+}
+
+func BenchmarkRoundTripFixed100(b *testing.B) {
+	benchmarkRoundTrip(b, positioned, nBytes(100), WithFixedElementSize(100))
+}
+
+func benchmarkDequeueInto(b *testing.B, dequeue func(q *Queue, buf []byte) error) {
+	q := NewQueue(NewMemBuffer())
+	value := nBytes(100)
+	buf := make([]byte, len(value))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		if err := q.Enqueue(value); err != nil {
+			b.Fatal(err)
+		}
+		if err := dequeue(q, buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDequeueAlloc(b *testing.B) {
+	benchmarkDequeueInto(b, func(q *Queue, _ []byte) error {
+		_, err := q.Dequeue()
+		return err
+	})
+}
+
+func BenchmarkDequeueInto(b *testing.B) {
+	benchmarkDequeueInto(b, func(q *Queue, buf []byte) error {
+		_, err := q.DequeueInto(buf)
+		return err
+	})
+}
+
+func BenchmarkRoundTripCheckpointEvery100(b *testing.B) {
+	benchmarkRoundTrip(b, positioned, nBytes(100), WithHeaderCheckpointEvery(100))
+}
@@ -36,9 +36,9 @@ func TestQueueModel(t *testing.T) {
 		},
 		GenCommandFunc: func(st commands.State) gopter.Gen {
 			return gen.Weighted([]gen.WeightedGen{
-				{45, genEnqueueCommand},
-				{45, genDequeueCommand(st)},
-				{10, genCrashCommand},
+				{Weight: 45, Gen: genEnqueueCommand},
+				{Weight: 45, Gen: genDequeueCommand(st)},
+				{Weight: 10, Gen: genCrashCommand},
 			})
 		},
 	}
@@ -238,6 +238,9 @@ type flakyReadWriteSeeker struct {
 	readShouldFail  bool
 	writeShouldFail bool
 	seekShouldFail  bool
+	tornWriteLength int // when positive, the next write stops after this many bytes and fails
+	readFailures    int // number of upcoming reads that fail
+	writeFailures   int // number of upcoming writes that fail
 }
 
 func newFlakyReadWriteSeeker(rws io.ReadWriteSeeker) *flakyReadWriteSeeker {
@@ -248,6 +251,10 @@ func (rws *flakyReadWriteSeeker) Read(b []byte) (int, error) {
 	if rws.readShouldFail {
 		return 0, errors.New("Oh no!")
 	}
+	if rws.readFailures > 0 {
+		rws.readFailures--
+		return 0, errors.New("Oh no!")
+	}
 	return rws.inner.Read(b)
 }
 
@@ -255,6 +262,15 @@ func (rws *flakyReadWriteSeeker) Write(b []byte) (int, error) {
 	if rws.writeShouldFail {
 		return 0, errors.New("Oh no!")
 	}
+	if rws.writeFailures > 0 {
+		rws.writeFailures--
+		return 0, errors.New("Oh no!")
+	}
+	if n := rws.tornWriteLength; n > 0 && n < len(b) {
+		rws.tornWriteLength = 0
+		written, _ := rws.inner.Write(b[:n])
+		return written, errors.New("Oh no!")
+	}
 	return rws.inner.Write(b)
 }
 
@@ -273,6 +289,21 @@ func (rws *flakyReadWriteSeeker) failNextWrite() {
 	rws.writeShouldFail = true
 }
 
+// tearNextWrite causes the next write to stop after n bytes and fail
+func (rws *flakyReadWriteSeeker) tearNextWrite(n int) {
+	rws.tornWriteLength = n
+}
+
+// failReads causes the next n reads to fail
+func (rws *flakyReadWriteSeeker) failReads(n int) {
+	rws.readFailures = n
+}
+
+// failWrites causes the next n writes to fail
+func (rws *flakyReadWriteSeeker) failWrites(n int) {
+	rws.writeFailures = n
+}
+
 func (rws *flakyReadWriteSeeker) failNextSeek() {
 	rws.seekShouldFail = true
 }
@@ -2,7 +2,11 @@ package queue
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
 	"io/ioutil"
+	"os"
 	"testing"
 
 	"github.com/leanovate/gopter"
@@ -12,6 +16,28 @@ import (
 )
 
 func TestQueueProperties(t *testing.T) {
+	t.Run("file", func(t *testing.T) {
+		testQueueProperties(t, func() (io.ReadWriteSeeker, error) {
+			return ioutil.TempFile("", "test-*")
+		})
+	})
+
+	t.Run("memory", func(t *testing.T) {
+		testQueueProperties(t, func() (io.ReadWriteSeeker, error) {
+			return NewMemBuffer(), nil
+		})
+	})
+
+	t.Run("memory without positioned I/O", func(t *testing.T) {
+		testQueueProperties(t, func() (io.ReadWriteSeeker, error) {
+			return seekOnly{NewMemBuffer()}, nil
+		})
+	})
+}
+
+// testQueueProperties checks the queue properties against backing stores
+// returned by newStore
+func testQueueProperties(t *testing.T, newStore func() (io.ReadWriteSeeker, error)) {
 	parameters := gopter.DefaultTestParameters()
 	parameters.MinSize = 1 // ensures minimum one element generated in random slices
 
@@ -19,12 +45,13 @@ func TestQueueProperties(t *testing.T) {
 
 	properties.Property("first enqueued element is always the result of dequeue", prop.ForAll(
 		func(ss []string) (bool, error) {
-			f, err := ioutil.TempFile("", "test-*")
+			f, err := newStore()
 			if err != nil {
 				return false, err
 			}
 
-			q := NewQueue(f)
+			// large enough to hold any generated slice of identifiers
+			q := NewQueue(f, WithCapacity(1<<16))
 
 			for _, s := range ss {
 				if err := q.Enqueue([]byte(s)); err != nil {
@@ -48,7 +75,7 @@ func TestQueueProperties(t *testing.T) {
 
 	properties.Property("repeated enqueue and dequeue works", prop.ForAll(
 		func(ss []string) (bool, error) {
-			f, err := ioutil.TempFile("", "test-*")
+			f, err := newStore()
 			if err != nil {
 				return false, err
 			}
@@ -76,7 +103,7 @@ func TestQueueProperties(t *testing.T) {
 	))
 
 	properties.Property("file size never exceeds capacity", func(params *gopter.GenParameters) *gopter.PropResult {
-		f, err := ioutil.TempFile("", "test-*")
+		f, err := newStore()
 		if err != nil {
 			return &gopter.PropResult{Status: gopter.PropError, Error: err}
 		}
@@ -88,30 +115,25 @@ func TestQueueProperties(t *testing.T) {
 
 			switch command := cmd.(type) {
 			case enqueueCommand:
+				// rejected enqueues must not grow the file either
 				err := q.Enqueue(command.x)
-				if err == ErrQueueFull {
-					return &gopter.PropResult{Status: gopter.PropUndecided}
-				}
-				if err != nil {
+				if err != nil && err != ErrQueueFull {
 					return &gopter.PropResult{Status: gopter.PropError, Error: err}
 				}
 			case dequeueCommand:
 				_, err := q.Dequeue()
-				if err == ErrQueueEmpty {
-					return &gopter.PropResult{Status: gopter.PropUndecided}
-				}
-				if err != nil {
+				if err != nil && err != ErrQueueEmpty {
 					return &gopter.PropResult{Status: gopter.PropError, Error: err}
 				}
 			}
 		}
 
-		fi, err := f.Stat()
+		size, err := f.Seek(0, io.SeekEnd)
 		if err != nil {
 			return &gopter.PropResult{Status: gopter.PropError, Error: err}
 		}
 
-		if fi.Size() > int64(q.header.fileLength) {
+		if size > int64(q.Header().FileLength) {
 			return gopter.NewPropResult(false, "file size is over capacity")
 		}
 
@@ -121,6 +143,585 @@ func TestQueueProperties(t *testing.T) {
 	properties.TestingRun(t)
 }
 
+func TestCapacity(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("default capacity", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f)
+		assert.Equal(uint32(4096), q.Capacity())
+	})
+
+	t.Run("configured capacity", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f, WithCapacity(8192))
+		assert.Equal(uint32(8192), q.Capacity())
+
+		// elements larger than the default capacity fit
+		large := nBytes(6000)
+		assert.Nil(q.Enqueue(large))
+
+		front, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Equal(large, front)
+
+		// draining the queue keeps the configured capacity
+		assert.Equal(uint32(8192), q.Capacity())
+	})
+
+	t.Run("reopened capacity comes from the file header", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		NewQueue(f, WithCapacity(8192))
+
+		q := NewQueue(f)
+		assert.Equal(uint32(8192), q.Capacity())
+
+		q = NewQueue(f, WithCapacity(1024))
+		assert.Equal(uint32(8192), q.Capacity())
+	})
+}
+
+func TestCapacityTooSmall(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, capacity := range []uint32{0, 8, headerLength, headerLength + elementHeaderLength - 1} {
+		f := NewMemBuffer()
+		_, err := New(f, WithCapacity(capacity))
+		assert.True(errors.Is(err, ErrCapacityTooSmall), "capacity %d", capacity)
+		assert.Empty(f.Bytes(), "capacity %d", capacity)
+	}
+
+	// the user header counts against the capacity
+	_, err := New(NewMemBuffer(), WithCapacity(headerLength+elementHeaderLength), WithUserHeader(4))
+	assert.True(errors.Is(err, ErrCapacityTooSmall))
+
+	_, err = New(NewMemBuffer(), WithCapacity(headerLength+elementHeaderLength))
+	assert.Nil(err)
+}
+
+func TestMaxElements(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("reject", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithMaxElements(3))
+		for i := 0; i < 3; i++ {
+			assert.Nil(q.Enqueue(nBytes(1)))
+		}
+		assert.Equal(ErrQueueFull, q.Enqueue(nBytes(1)))
+		assert.Equal(3, q.Len())
+		assert.Equal(0, q.RemainingCapacityFor(1))
+
+		_, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Nil(q.Enqueue(nBytes(1)))
+		assert.Equal(ErrQueueFull, q.Enqueue(nBytes(1)))
+	})
+
+	t.Run("overwrite oldest", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithMaxElements(2), WithOverwriteOldest())
+		for _, v := range []string{"a", "b", "c"} {
+			assert.Nil(q.Enqueue([]byte(v)))
+		}
+
+		elements, err := q.Elements()
+		assert.Nil(err)
+		assert.Equal([][]byte{[]byte("b"), []byte("c")}, elements)
+		assert.Equal(uint64(1), q.Evicted())
+	})
+}
+
+func TestResetOnEmpty(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("reset", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithCapacity(headerLength+100))
+		assert.Nil(q.Enqueue(nBytes(10)))
+		assert.Nil(q.Enqueue(nBytes(20)))
+		_, err := q.Dequeue()
+		assert.Nil(err)
+		_, err = q.Dequeue()
+		assert.Nil(err)
+
+		assert.Equal(q.dataStart(), q.header.headPosition)
+		assert.Equal(q.dataStart(), q.header.tailPosition)
+		assert.Equal(headerLength+100, q.Capacity())
+		assert.Equal(headerLength+100, NewQueue(q.rws).Capacity())
+	})
+
+	t.Run("no reset", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithCapacity(headerLength+100), WithNoResetOnEmpty())
+		assert.Nil(q.Enqueue(nBytes(10)))
+		assert.Nil(q.Enqueue(nBytes(20)))
+		_, err := q.Dequeue()
+		assert.Nil(err)
+		_, err = q.Dequeue()
+		assert.Nil(err)
+
+		end := q.dataStart() + 14 + 24
+		assert.Equal(end, q.header.headPosition)
+		assert.Equal(end, q.header.tailPosition)
+		assert.Equal(headerLength+100, q.Capacity())
+
+		// elements keep moving forward and wrap around the buffer
+		wrapped := false
+		for i := 0; i < 100; i++ {
+			a, b := nBytes(i%21), nBytes(i%13)
+			assert.Nil(q.Enqueue(a))
+			assert.Nil(q.Enqueue(b))
+			wrapped = wrapped || q.isWrapped()
+
+			for _, want := range [][]byte{a, b} {
+				got, err := q.Dequeue()
+				assert.Nil(err)
+				assert.Equal(want, got)
+			}
+		}
+		assert.True(wrapped)
+
+		// an element larger than the space left at the end restarts at
+		// the front rather than being rejected
+		assert.Nil(q.Enqueue(nBytes(96)))
+		assert.Equal(q.dataStart(), q.header.headPosition)
+	})
+}
+
+func TestPreallocate(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("truncate", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		NewQueue(f, WithCapacity(1<<16), WithPreallocate())
+
+		fi, err := f.Stat()
+		assert.Nil(err)
+		assert.Equal(int64(1<<16), fi.Size())
+	})
+
+	t.Run("zero fill", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		// hide Truncate to force writing zeros
+		q := NewQueue(seekOnly{f}, WithCapacity(1000), WithPreallocate())
+
+		fi, err := f.Stat()
+		assert.Nil(err)
+		assert.Equal(int64(1000), fi.Size())
+
+		assert.Nil(q.Enqueue([]byte("hello")))
+		v, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Equal([]byte("hello"), v)
+	})
+
+	t.Run("reopen", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f, WithCapacity(1000))
+		assert.Nil(q.Enqueue([]byte("hello")))
+
+		q = NewQueue(f, WithPreallocate())
+		fi, err := f.Stat()
+		assert.Nil(err)
+		assert.True(fi.Size() < 1000)
+		assert.Equal(uint32(1), q.header.queueSize)
+	})
+}
+
+func TestInitialElements(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := ioutil.TempFile("", "test-*")
+	assert.Nil(err)
+
+	seed := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	q := NewQueue(f, WithInitialElements(seed))
+
+	// reopening an existing queue does not seed it again
+	q = NewQueue(f, WithInitialElements(seed))
+
+	for _, v := range seed {
+		front, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Equal(v, front)
+	}
+
+	_, err = q.Dequeue()
+	assert.Equal(ErrQueueEmpty, err)
+}
+
+func TestReadOnly(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("inspection works while mutations fail", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f)
+		assert.Nil(q.Enqueue([]byte("a")))
+		assert.Nil(q.Enqueue([]byte("b")))
+
+		before, err := f.Stat()
+		assert.Nil(err)
+
+		q, err = New(f, WithReadOnly())
+		assert.Nil(err)
+
+		assert.Equal(2, q.Len())
+
+		front, err := q.Peek()
+		assert.Nil(err)
+		assert.Equal([]byte("a"), front)
+
+		var seen []string
+		assert.Nil(q.ForEach(func(v []byte) error {
+			seen = append(seen, string(v))
+			return nil
+		}))
+		assert.Equal([]string{"a", "b"}, seen)
+
+		// reading the queue only reads the backing store
+		stats := q.Stats()
+		assert.NotZero(stats.Reads)
+		stats.Reads = 0
+		assert.Equal(Stats{}, stats)
+
+		assert.Equal(ErrReadOnly, q.Enqueue([]byte("c")))
+		_, err = q.Dequeue()
+		assert.Equal(ErrReadOnly, err)
+		assert.Equal(ErrReadOnly, q.Flush())
+		assert.Equal(ErrReadOnly, q.Shrink(q.Capacity()))
+		assert.Equal(2, q.Len())
+
+		after, err := f.Stat()
+		assert.Nil(err)
+		assert.Equal(before.ModTime(), after.ModTime())
+		assert.Equal(before.Size(), after.Size())
+	})
+
+	t.Run("empty file cannot be opened", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		_, err = New(f, WithReadOnly())
+		assert.True(errors.Is(err, ErrReadOnly))
+
+		fi, err := f.Stat()
+		assert.Nil(err)
+		assert.Equal(int64(0), fi.Size())
+	})
+}
+
+func TestFlush(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("flush syncs the backing file once", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		rws := &syncRecorder{File: f}
+		q := NewQueue(rws)
+
+		assert.Nil(q.Enqueue([]byte("a")))
+		assert.Nil(q.Enqueue([]byte("b")))
+		assert.Equal(0, rws.syncs)
+
+		assert.Nil(q.Flush())
+		assert.Equal(1, rws.syncs)
+	})
+
+	t.Run("flush without sync support writes the header", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		rws := newFlakyReadWriteSeeker(f)
+		q := NewQueue(rws)
+		assert.Nil(q.Enqueue([]byte("a")))
+		assert.Nil(q.Flush())
+
+		rws.failNextWrite()
+		var ioErr *IOError
+		assert.True(errors.As(q.Flush(), &ioErr))
+		assert.Equal(OpHeaderSync, ioErr.Op)
+	})
+}
+
+func TestEnqueueAt(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := ioutil.TempFile("", "test-*")
+	assert.Nil(err)
+
+	q := NewQueue(f)
+
+	values := [][]byte{[]byte("a"), []byte("bc"), []byte("def")}
+	for _, v := range values {
+		offset, err := q.EnqueueAt(v)
+		assert.Nil(err)
+
+		frame := make([]byte, 4+len(v))
+		_, err = f.ReadAt(frame, int64(offset))
+		assert.Nil(err)
+		assert.Equal(lengthPrefixFramer{}.Frame(v), frame)
+	}
+
+	// an element that wraps lands at the front of the buffer
+	_, err = q.Dequeue()
+	assert.Nil(err)
+	_, err = q.EnqueueAt(nBytes(int(q.tailSpaceAvailable()) - 4))
+	assert.Nil(err)
+
+	offset, err := q.EnqueueAt([]byte("x"))
+	assert.Nil(err)
+	assert.Equal(headerLength, offset)
+}
+
+func TestDequeueIf(t *testing.T) {
+	assert := assert.New(t)
+
+	isHello := func(v []byte) bool { return string(v) == "hello" }
+
+	t.Run("accept", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f)
+		assert.Nil(q.Enqueue([]byte("hello")))
+		assert.Nil(q.Enqueue([]byte("world")))
+
+		v, ok, err := q.DequeueIf(isHello)
+		assert.Nil(err)
+		assert.True(ok)
+		assert.Equal([]byte("hello"), v)
+		assert.Equal(1, q.Len())
+
+		// the removal is persisted
+		q = NewQueue(f)
+		front, err := q.Peek()
+		assert.Nil(err)
+		assert.Equal([]byte("world"), front)
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f)
+		assert.Nil(q.Enqueue([]byte("world")))
+		header, seq := q.header, q.headerSeq
+
+		v, ok, err := q.DequeueIf(isHello)
+		assert.Nil(err)
+		assert.False(ok)
+		assert.Nil(v)
+		assert.Equal(header, q.header)
+		assert.Equal(seq, q.headerSeq)
+
+		v, err = q.Dequeue()
+		assert.Nil(err)
+		assert.Equal([]byte("world"), v)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f)
+		_, ok, err := q.DequeueIf(isHello)
+		assert.Equal(ErrQueueEmpty, err)
+		assert.False(ok)
+	})
+}
+
+func TestTryDequeue(t *testing.T) {
+	assert := assert.New(t)
+
+	q := NewQueue(NewMemBuffer())
+
+	v, ok, err := q.TryDequeue()
+	assert.Nil(err)
+	assert.False(ok)
+	assert.Nil(v)
+
+	assert.Nil(q.Enqueue([]byte("hello")))
+
+	v, ok, err = q.TryDequeue()
+	assert.Nil(err)
+	assert.True(ok)
+	assert.Equal([]byte("hello"), v)
+
+	_, ok, err = q.TryDequeue()
+	assert.Nil(err)
+	assert.False(ok)
+
+	t.Run("I/O errors are reported", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		rws := newFlakyReadWriteSeeker(f)
+		q := NewQueue(rws)
+		assert.Nil(q.Enqueue([]byte("hello")))
+
+		rws.failNextRead()
+		_, ok, err := q.TryDequeue()
+		assert.NotNil(err)
+		assert.False(ok)
+	})
+}
+
+func TestEnqueueTooLarge(t *testing.T) {
+	assert := assert.New(t)
+
+	q := NewQueue(NewMemBuffer(), WithCapacity(headerLength+16))
+
+	err := q.Enqueue(nBytes(13))
+	assert.True(errors.Is(err, ErrElementTooLarge))
+	assert.Contains(err.Error(), "17 bytes")
+	assert.Equal(0, q.Len())
+
+	// the largest element that fits is still accepted
+	assert.Nil(q.Enqueue(nBytes(12)))
+}
+
+func TestEnqueueSyncFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := ioutil.TempFile("", "test-*")
+	assert.Nil(err)
+
+	rws := newFlakyReadWriteSeeker(f)
+	q := NewQueue(rws)
+	assert.Nil(q.Enqueue([]byte("a")))
+	header := q.header
+
+	// the 5-byte frame is written but the header write is torn
+	rws.tearNextWrite(5)
+	assert.NotNil(q.Enqueue([]byte("b")))
+	assert.Equal(1, q.Len())
+	assert.Equal(header, q.header)
+
+	assert.Nil(q.Enqueue([]byte("c")))
+	assert.Nil(q.HealthCheck())
+
+	elements, err := NewQueue(f).Elements()
+	assert.Nil(err)
+	assert.Equal([][]byte{[]byte("a"), []byte("c")}, elements)
+}
+
+func TestDequeueSyncFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := ioutil.TempFile("", "test-*")
+	assert.Nil(err)
+
+	rws := newFlakyReadWriteSeeker(f)
+	q := NewQueue(rws)
+	assert.Nil(q.Enqueue([]byte("a")))
+	assert.Nil(q.Enqueue([]byte("b")))
+	header := q.header
+
+	rws.failNextWrite()
+	_, err = q.Dequeue()
+	assert.NotNil(err)
+	assert.Equal(header, q.header)
+	rws.writeShouldFail = false
+
+	for _, v := range []string{"a", "b"} {
+		front, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Equal([]byte(v), front)
+	}
+	assert.Nil(q.HealthCheck())
+}
+
+func TestDequeueTruncated(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("partial element body", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f)
+		assert.Nil(q.Enqueue([]byte("a")))
+		assert.Nil(q.Enqueue([]byte("hello world")))
+
+		// cut the second element off partway through its body
+		offset := headerLength + 4 + 1
+		assert.Nil(f.Truncate(int64(offset + 4 + 3)))
+
+		front, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Equal([]byte("a"), front)
+
+		_, err = q.Dequeue()
+		assert.True(errors.Is(err, ErrTruncatedElement))
+
+		var truncated *TruncatedElementError
+		assert.True(errors.As(err, &truncated))
+		assert.Equal(offset, truncated.Offset)
+		assert.Equal(uint32(len("hello world")), truncated.Length)
+	})
+
+	t.Run("missing element", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f)
+		assert.Nil(q.Enqueue([]byte("a")))
+		assert.Nil(f.Truncate(int64(headerLength)))
+
+		_, err = q.Dequeue()
+		assert.True(errors.Is(err, ErrTruncatedElement))
+		assert.NotEqual(ErrQueueEmpty, err)
+	})
+}
+
+func TestDequeueCorruptLength(t *testing.T) {
+	assert := assert.New(t)
+
+	corrupt := func() *Queue {
+		f := NewMemBuffer()
+		q := NewQueue(f)
+		assert.Nil(q.Enqueue([]byte("a")))
+
+		// overwrite the length prefix of the head element
+		binary.BigEndian.PutUint32(f.Bytes()[headerLength:], 0xfffffff0)
+		return q
+	}
+
+	q := corrupt()
+	_, err := q.Dequeue()
+	assert.True(errors.Is(err, ErrCorruptElement))
+	assert.False(errors.Is(err, ErrTruncatedElement))
+	assert.Equal(1, q.Len())
+
+	_, err = corrupt().DequeueInto(make([]byte, 16))
+	assert.True(errors.Is(err, ErrCorruptElement))
+
+	_, err = corrupt().ElementSizes()
+	assert.True(errors.Is(err, ErrCorruptElement))
+}
+
+// syncRecorder counts calls to Sync on a file
+type syncRecorder struct {
+	*os.File
+	syncs int
+}
+
+func (s *syncRecorder) Sync() error {
+	s.syncs++
+	return s.File.Sync()
+}
+
 // Capture failed model test sequences
 func TestRegressions(t *testing.T) {
 	assert := assert.New(t)
@@ -160,6 +761,31 @@ func TestRegressions(t *testing.T) {
 		assert.Nil(err)
 		assert.Equal([]byte("b"), front)
 	})
+
+	t.Run("regression 2", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f)
+
+		// the third element wraps to the front of the buffer
+		q.Enqueue(bytes.Repeat([]byte("a"), 1500))
+		q.Enqueue(bytes.Repeat([]byte("b"), 1500))
+		q.Dequeue()
+		assert.Nil(q.Enqueue(bytes.Repeat([]byte("c"), 1500)))
+
+		front, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Equal(bytes.Repeat([]byte("b"), 1500), front)
+
+		front, err = q.Dequeue()
+		assert.Nil(err)
+		assert.Equal(bytes.Repeat([]byte("c"), 1500), front)
+
+		fi, err := f.Stat()
+		assert.Nil(err)
+		assert.LessOrEqual(fi.Size(), int64(q.Header().FileLength))
+	})
 }
 
 // generate one of either an enqueueCommand or dequeueCommand at random
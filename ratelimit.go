@@ -0,0 +1,31 @@
+package queue
+
+import (
+	"context"
+
+	"golang.org/x/time/rate"
+)
+
+// WithDequeueRateLimit paces Dequeue and DequeueContext so that elements
+// are delivered at no more than ratePerSec per second on average, with
+// bursts of up to burst elements
+//
+// Each call waits for a token before looking at the queue, so calls that
+// find the queue empty also consume a token.
+func WithDequeueRateLimit(ratePerSec float64, burst int) Option {
+	return func(ls *Queue) {
+		ls.limiter = rate.NewLimiter(rate.Limit(ratePerSec), burst)
+	}
+}
+
+// pace blocks until the dequeue rate limit, if any, allows another
+// dequeue or ctx is done
+//
+// Queue.mu must not be held by the caller, so that other operations can
+// proceed while a dequeue waits.
+func (ls *Queue) pace(ctx context.Context) error {
+	if ls.limiter == nil {
+		return nil
+	}
+	return ls.limiter.Wait(ctx)
+}
@@ -0,0 +1,58 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDequeueRateLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("dequeues are paced", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithDequeueRateLimit(50, 1))
+		for i := 0; i < 6; i++ {
+			assert.Nil(q.Enqueue([]byte("x")))
+		}
+
+		// the first dequeue spends the burst and each later one waits 20ms
+		start := time.Now()
+		for i := 0; i < 6; i++ {
+			_, err := q.Dequeue()
+			assert.Nil(err)
+		}
+		assert.GreaterOrEqual(int64(time.Since(start)), int64(100*time.Millisecond))
+	})
+
+	t.Run("waiting respects the context", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithDequeueRateLimit(0.1, 1))
+		assert.Nil(q.Enqueue([]byte("a")))
+		assert.Nil(q.Enqueue([]byte("b")))
+
+		_, err := q.DequeueContext(context.Background())
+		assert.Nil(err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err = q.DequeueContext(ctx)
+		assert.NotNil(err)
+		assert.Equal(1, q.Len())
+	})
+
+	t.Run("TryDequeue does not wait", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithDequeueRateLimit(0.1, 1))
+		assert.Nil(q.Enqueue([]byte("a")))
+		assert.Nil(q.Enqueue([]byte("b")))
+
+		_, ok, err := q.TryDequeue()
+		assert.Nil(err)
+		assert.True(ok)
+
+		_, ok, err = q.TryDequeue()
+		assert.Nil(err)
+		assert.False(ok)
+		assert.Equal(1, q.Len())
+	})
+}
@@ -0,0 +1,78 @@
+package queue
+
+import (
+	"errors"
+	"io"
+)
+
+// Repair rebuilds the header of a queue whose header has been lost or
+// corrupted but whose element data is intact
+//
+// Element frames are scanned forward from the start of the data region,
+// and the scan stops at the first frame whose length would run past the
+// end of the buffer or that is empty, since zeroed or preallocated space
+// reads as a run of empty frames. Every frame before that point is assumed
+// to be live, so elements that were dequeued but not yet reclaimed may be
+// delivered again after a repair, while an empty element and the elements
+// after it are not recovered.
+func Repair(f io.ReadWriteSeeker, opts ...Option) (*Queue, error) {
+	q := newQueue(f, opts)
+	q.header = q.defaultFileHeader()
+	if err := q.adoptFraming(); err != nil {
+		return nil, err
+	}
+
+	// the repaired header must supersede any header slot that is still valid
+	if _, seq, err := q.readHeader(); err == nil {
+		q.headerSeq = seq
+	}
+
+	end, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	// only bytes that were actually written can hold elements
+	limit := q.header.fileLength
+	if uint32(end) < limit {
+		limit = uint32(end)
+	}
+
+	pos := q.dataStart()
+	var size uint32
+	for pos < limit {
+		// stop at the first implausible frame
+		body, frameLength, err := q.readElement(pos, limit)
+		if errors.Is(err, ErrTruncatedElement) || errors.Is(err, ErrCorruptElement) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(body) == 0 {
+			break
+		}
+
+		// never reuse the sequence number of a recovered element
+		if _, seq, err := q.unnumber(body); err == nil && q.sequenced() && seq >= q.header.nextSequence {
+			q.header.nextSequence = seq + 1
+		}
+
+		pos += frameLength
+		size++
+	}
+
+	if size > 0 {
+		q.header.queueSize = size
+		q.header.tailPosition = pos
+	}
+
+	if err := q.syncHeader(); err != nil {
+		return nil, err
+	}
+	if err := q.loadConsumer(); err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
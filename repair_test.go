@@ -0,0 +1,116 @@
+package queue
+
+import (
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepair(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("recovers elements after the header is zeroed", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f)
+
+		values := [][]byte{[]byte("a"), []byte("bc"), []byte("def")}
+		for _, v := range values {
+			assert.Nil(q.Enqueue(v))
+		}
+
+		// wipe out the header
+		_, err = f.Seek(0, io.SeekStart)
+		assert.Nil(err)
+		_, err = f.Write(make([]byte, headerLength))
+		assert.Nil(err)
+
+		q, err = Repair(f)
+		assert.Nil(err)
+
+		for _, v := range values {
+			front, err := q.Dequeue()
+			assert.Nil(err)
+			assert.Equal(v, front)
+		}
+
+		_, err = q.Dequeue()
+		assert.Equal(ErrQueueEmpty, err)
+	})
+
+	t.Run("stops at the first implausible frame", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f)
+		assert.Nil(q.Enqueue([]byte("a")))
+
+		// append a frame whose length runs past the end of the file
+		_, err = f.Seek(0, io.SeekEnd)
+		assert.Nil(err)
+		_, err = f.Write([]byte{0x00, 0x00, 0xff, 0xff, 'x'})
+		assert.Nil(err)
+
+		q, err = Repair(f)
+		assert.Nil(err)
+		assert.Equal(uint32(1), q.header.queueSize)
+
+		front, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Equal([]byte("a"), front)
+	})
+
+	t.Run("stops at preallocated space", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f, WithPreallocate())
+
+		values := [][]byte{[]byte("a"), []byte("bc"), []byte("def")}
+		for _, v := range values {
+			assert.Nil(q.Enqueue(v))
+		}
+
+		_, err = f.Seek(0, io.SeekStart)
+		assert.Nil(err)
+		_, err = f.Write(make([]byte, headerLength))
+		assert.Nil(err)
+
+		q, err = Repair(f)
+		assert.Nil(err)
+		assert.Equal(len(values), q.Len())
+
+		elements, err := q.Elements()
+		assert.Nil(err)
+		assert.Equal(values, elements)
+	})
+
+	t.Run("zero-filled file repairs to an empty queue", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		_, err = f.Write(make([]byte, 4096))
+		assert.Nil(err)
+
+		q, err := Repair(f)
+		assert.Nil(err)
+		assert.Equal(0, q.Len())
+
+		_, err = q.Dequeue()
+		assert.Equal(ErrQueueEmpty, err)
+	})
+
+	t.Run("empty file repairs to an empty queue", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q, err := Repair(f)
+		assert.Nil(err)
+
+		_, err = q.Dequeue()
+		assert.Equal(ErrQueueEmpty, err)
+	})
+}
@@ -0,0 +1,86 @@
+package queue
+
+import (
+	"fmt"
+)
+
+// ReplaceHead overwrites the payload of the head element with v, keeping
+// its place at the front of the queue, its sequence number, its
+// timestamp, and its tags, or returns ErrElementTooLarge if the frame for v does not fit
+// in the space the head element occupies
+//
+// Frames are found by their length, so a shorter frame is written at the
+// end of the old one and the head position moves forward by the bytes
+// saved; no other element moves and the length of the queue is unchanged.
+// The frame is overwritten in place, so a crash part way through the
+// write can corrupt the head element.
+func (ls *Queue) ReplaceHead(v []byte) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if ls.readOnly {
+		return ErrReadOnly
+	}
+	if ls.header.queueSize == 0 {
+		return ErrQueueEmpty
+	}
+	if size := ls.header.elementSize; size != 0 && uint32(len(v)) != size {
+		return fmt.Errorf("%w: %d byte element in a queue of %d byte elements", ErrElementSize, len(v), size)
+	}
+
+	head := ls.header.headPosition
+	old, slotLength, err := ls.readElement(head, ls.header.fileLength)
+	if err != nil {
+		return err
+	}
+	_, seq, err := ls.unnumber(old)
+	if err != nil {
+		return err
+	}
+	stamp, _ := ls.stamp(old)
+	if ls.tagged() {
+		_, tags, err := ls.decodeTagged(old)
+		if err != nil {
+			return err
+		}
+		v = appendTags(nil, tags, v)
+	}
+
+	body, err := ls.encodeElement(v)
+	if err != nil {
+		return err
+	}
+	frame := ls.pad(ls.framer.Frame(ls.numberAs(body, seq, stamp)))
+	if uint32(len(frame)) > slotLength {
+		return fmt.Errorf("%w: frame of %d bytes exceeds the %d byte head slot", ErrElementTooLarge, len(frame), slotLength)
+	}
+
+	offset := head + slotLength - uint32(len(frame))
+	if err := ls.protect(offset, offset+uint32(len(frame))); err != nil {
+		return err
+	}
+	if _, err := ls.writeAt(frame, int64(offset)); err != nil {
+		return ioError(OpElementWrite, int64(offset), err)
+	}
+	if err := ls.verifyWrite(frame, int64(offset)); err != nil {
+		return ioError(OpElementWrite, int64(offset), err)
+	}
+
+	if offset != head {
+		original := ls.header
+		ls.header.headPosition = offset
+		if err := ls.syncHeader(); err != nil {
+			ls.header = original
+			return err
+		}
+		ls.headMoves++
+	}
+
+	ls.removeSpilled(old)
+	ls.cond.Broadcast()
+
+	if ls.zeroOnDequeue {
+		return ls.zero(head, offset)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package queue
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplaceHead(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("fits", func(t *testing.T) {
+		f := NewMemBuffer()
+		q := NewQueue(f)
+		assert.Nil(q.Enqueue([]byte("job retries=10")))
+		assert.Nil(q.Enqueue([]byte("next")))
+		tail := q.Header().TailPosition
+
+		assert.Nil(q.ReplaceHead([]byte("job retries=11")))
+		assert.Nil(q.ReplaceHead([]byte("job retries=9")))
+		assert.Equal(2, q.Len())
+		assert.Equal(tail, q.Header().TailPosition)
+		assert.Nil(q.HealthCheck())
+
+		q = NewQueue(f)
+		elements, err := q.Elements()
+		assert.Nil(err)
+		assert.Equal([][]byte{[]byte("job retries=9"), []byte("next")}, elements)
+	})
+
+	t.Run("does not fit", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+		assert.Nil(q.Enqueue([]byte("short")))
+		assert.Nil(q.Enqueue([]byte("next")))
+		header := q.Header()
+
+		err := q.ReplaceHead([]byte("much longer"))
+		assert.True(errors.Is(err, ErrElementTooLarge))
+		assert.Equal(header, q.Header())
+
+		front, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Equal([]byte("short"), front)
+	})
+
+	t.Run("keeps the sequence number", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithSequenceNumbers())
+		assert.Nil(q.Enqueue([]byte("first")))
+		assert.Nil(q.Enqueue([]byte("second")))
+
+		assert.Nil(q.ReplaceHead([]byte("1st")))
+		v, seq, err := q.DequeueWithSeq()
+		assert.Nil(err)
+		assert.Equal([]byte("1st"), v)
+		assert.Equal(uint64(1), seq)
+	})
+
+	t.Run("empty queue", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+		assert.Equal(ErrQueueEmpty, q.ReplaceHead([]byte("a")))
+	})
+}
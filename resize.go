@@ -0,0 +1,196 @@
+package queue
+
+import (
+	"fmt"
+	"math"
+)
+
+// Shrink reduces the capacity of the queue to targetCapacity bytes,
+// compacting live elements towards the front of the buffer if any lie
+// beyond the new capacity, and truncates the backing file when it
+// supports Truncate
+//
+// Shrink returns an error without modifying the queue if the live
+// elements do not fit in targetCapacity, if targetCapacity is below the
+// minimum New accepts, in which case the error is ErrCapacityTooSmall, or
+// if the queue is append-only and elements would have to move.
+func (ls *Queue) Shrink(targetCapacity uint32) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	return ls.shrink(targetCapacity)
+}
+
+// shrink is Shrink without locking Queue.mu
+func (ls *Queue) shrink(targetCapacity uint32) error {
+	if ls.readOnly {
+		return ErrReadOnly
+	}
+
+	if targetCapacity > ls.header.fileLength {
+		return fmt.Errorf("cannot shrink capacity from %d to larger capacity %d", ls.header.fileLength, targetCapacity)
+	}
+
+	if min := ls.dataStart() + elementHeaderLength; targetCapacity < min {
+		return fmt.Errorf("%w: capacity of %d bytes is below the minimum of %d bytes", ErrCapacityTooSmall, targetCapacity, min)
+	}
+	if required := ls.dataStart() + ls.usedBytes(); targetCapacity < required {
+		return fmt.Errorf("cannot shrink capacity to %d below the %d bytes required by live elements", targetCapacity, required)
+	}
+
+	// the furthest offset referenced by a live element
+	end := ls.header.tailPosition
+	if ls.isWrapped() {
+		end = ls.header.wrapPosition
+	}
+
+	if end > targetCapacity && ls.appendOnly {
+		return fmt.Errorf("cannot shrink append-only queue to %d below its tail at %d", targetCapacity, end)
+	}
+
+	if end > targetCapacity {
+		if err := ls.compact(); err != nil {
+			return err
+		}
+	}
+
+	original := ls.header
+	ls.header.fileLength = targetCapacity
+	if err := ls.writeHeader(); err != nil {
+		ls.header = original
+		return err
+	}
+
+	if t, ok := ls.rws.(interface{ Truncate(int64) error }); ok {
+		return t.Truncate(int64(targetCapacity))
+	}
+
+	return nil
+}
+
+// Grow increases the capacity of the queue to newCapacity bytes, extending
+// the backing file when it supports Truncate
+//
+// If the live elements wrap around the end of the buffer and the elements
+// at the front of the buffer fit in the added space, they are moved after
+// the elements at the end, so the added space can be used straight away.
+// They are copied before the header is updated, so a crash part way
+// through leaves the queue as it was. Otherwise the added space becomes
+// usable once the queue no longer wraps. Grow returns an error if
+// newCapacity is below the current capacity; use Shrink to reduce it.
+func (ls *Queue) Grow(newCapacity uint32) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	return ls.grow(newCapacity)
+}
+
+// grow is Grow without locking Queue.mu
+func (ls *Queue) grow(newCapacity uint32) error {
+	if ls.readOnly {
+		return ErrReadOnly
+	}
+
+	if newCapacity < ls.header.fileLength {
+		return fmt.Errorf("cannot grow capacity from %d to smaller capacity %d", ls.header.fileLength, newCapacity)
+	}
+	if newCapacity == ls.header.fileLength {
+		return nil
+	}
+
+	// extend the file before the header refers to the added space
+	if t, ok := ls.rws.(interface{ Truncate(int64) error }); ok {
+		if err := t.Truncate(int64(newCapacity)); err != nil {
+			return err
+		}
+	} else if ls.preallocate {
+		if err := ls.zero(ls.header.fileLength, newCapacity); err != nil {
+			return err
+		}
+	}
+
+	header := ls.header
+	header.fileLength = newCapacity
+
+	// move the wrapped front segment after the end segment
+	var moved []byte
+	if ls.isWrapped() {
+		front := header.tailPosition - ls.dataStart()
+		if front <= newCapacity-header.wrapPosition {
+			moved = make([]byte, front)
+			if err := ls.readAt(moved, int64(ls.dataStart())); err != nil {
+				return ioError(OpElementRead, int64(ls.dataStart()), err)
+			}
+			if err := ls.protect(header.wrapPosition, header.wrapPosition+front); err != nil {
+				return err
+			}
+			if _, err := ls.writeAt(moved, int64(header.wrapPosition)); err != nil {
+				return ioError(OpElementWrite, int64(header.wrapPosition), err)
+			}
+
+			header.tailPosition = header.wrapPosition + front
+			header.wrapPosition = 0
+		}
+	}
+
+	original := ls.header
+	ls.header = header
+	if err := ls.writeHeader(); err != nil {
+		ls.header = original
+		return err
+	}
+	ls.cond.Broadcast()
+
+	if moved != nil {
+		ls.headMoves++
+		ls.relocations++
+
+		if ls.zeroOnDequeue {
+			return ls.zero(ls.dataStart(), original.tailPosition)
+		}
+	}
+
+	return nil
+}
+
+// EnsureCapacity grows the queue, like Grow, if needed so that element
+// frames totalling totalBytes can be enqueued without evicting elements or
+// failing with ErrQueueFull, and does nothing if they already fit
+//
+// A frame is the payload plus its framing, as counted by ByteLen. Frames
+// that do not fit at the tail of the queue wrap to the front of the
+// buffer, so the frames fit if the larger of the contiguous free regions
+// holds all of them. When the elements wrap, the queue is grown far enough
+// for Grow to unwrap them and leave totalBytes free after the tail.
+func (ls *Queue) EnsureCapacity(totalBytes uint32) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if ls.readOnly {
+		return ErrReadOnly
+	}
+
+	h := ls.header
+	var available, end uint64
+	switch {
+	case ls.isWrapped():
+		available = uint64(ls.headSpaceAvailable())
+		end = uint64(h.wrapPosition) + uint64(h.tailPosition-ls.dataStart())
+	default:
+		available = uint64(ls.tailSpaceAvailable())
+		if head := uint64(ls.headSpaceAvailable()); !ls.appendOnly && head > available {
+			available = head
+		}
+		end = uint64(h.tailPosition)
+	}
+	if uint64(totalBytes) <= available {
+		return nil
+	}
+
+	newCapacity := end + uint64(totalBytes)
+	if newCapacity > math.MaxUint32 {
+		return fmt.Errorf("cannot grow capacity to %d bytes, beyond the maximum of %d", newCapacity, uint32(math.MaxUint32))
+	}
+
+	return ls.grow(uint32(newCapacity))
+}
@@ -0,0 +1,278 @@
+package queue
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/leanovate/gopter"
+	"github.com/leanovate/gopter/gen"
+	"github.com/leanovate/gopter/prop"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShrink(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("shrinking a drained queue truncates the file", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f, WithCapacity(1<<16))
+
+		for i := 0; i < 50; i++ {
+			assert.Nil(q.Enqueue(nBytes(1000)))
+		}
+		for i := 0; i < 50; i++ {
+			_, err := q.Dequeue()
+			assert.Nil(err)
+		}
+
+		before, err := f.Stat()
+		assert.Nil(err)
+
+		assert.Nil(q.Shrink(4096))
+		assert.Equal(uint32(4096), q.Capacity())
+
+		after, err := f.Stat()
+		assert.Nil(err)
+		assert.Less(after.Size(), before.Size())
+		assert.Equal(int64(4096), after.Size())
+
+		// the shrunken queue survives reopen
+		q = NewQueue(f)
+		assert.Equal(uint32(4096), q.Capacity())
+		assert.Nil(q.Enqueue([]byte("a")))
+	})
+
+	t.Run("live elements beyond the new capacity are compacted", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f, WithCapacity(1<<16))
+
+		for i := 0; i < 20; i++ {
+			assert.Nil(q.Enqueue([]byte(fmt.Sprintf("element-%d", i))))
+		}
+		for i := 0; i < 15; i++ {
+			_, err := q.Dequeue()
+			assert.Nil(err)
+		}
+
+		assert.Nil(q.Shrink(headerLength + q.usedBytes()))
+
+		q = NewQueue(f)
+		for i := 15; i < 20; i++ {
+			front, err := q.Dequeue()
+			assert.Nil(err)
+			assert.Equal([]byte(fmt.Sprintf("element-%d", i)), front)
+		}
+	})
+
+	t.Run("wrapped live elements are compacted", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f, WithCapacity(8192))
+
+		values := [][]byte{nBytes(3000), nBytes(2500)}
+		assert.Nil(q.Enqueue(nBytes(3000)))
+		assert.Nil(q.Enqueue(values[0]))
+		_, err = q.Dequeue()
+		assert.Nil(err)
+		assert.Nil(q.Enqueue(values[1]))
+		assert.True(q.isWrapped())
+
+		assert.Nil(q.Shrink(5700))
+		assert.False(q.isWrapped())
+
+		for _, v := range values {
+			front, err := q.Dequeue()
+			assert.Nil(err)
+			assert.Equal(v, front)
+		}
+	})
+
+	t.Run("refuses to shrink below live elements", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f, WithCapacity(8192))
+		assert.Nil(q.Enqueue(nBytes(5000)))
+
+		assert.NotNil(q.Shrink(4096))
+		assert.Equal(uint32(8192), q.Capacity())
+	})
+
+	t.Run("refuses to shrink below the minimum capacity", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+
+		assert.True(errors.Is(q.Shrink(headerLength), ErrCapacityTooSmall))
+		assert.True(errors.Is(q.Shrink(headerLength+elementHeaderLength-1), ErrCapacityTooSmall))
+		assert.Equal(uint32(defaultCapacity), q.Capacity())
+
+		assert.Nil(q.Shrink(headerLength + elementHeaderLength))
+	})
+}
+
+func TestGrow(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("wrapped front segment is moved into the added space", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f, WithCapacity(4096))
+
+		values := [][]byte{nBytes(2000), nBytes(1500)}
+		assert.Nil(q.Enqueue(nBytes(1500)))
+		assert.Nil(q.Enqueue(values[0]))
+		_, err = q.Dequeue()
+		assert.Nil(err)
+		assert.Nil(q.Enqueue(values[1]))
+		assert.True(q.IsWrapped())
+
+		assert.Nil(q.Grow(8192))
+		assert.Equal(uint32(8192), q.Capacity())
+		assert.False(q.IsWrapped())
+
+		fi, err := f.Stat()
+		assert.Nil(err)
+		assert.Equal(int64(8192), fi.Size())
+
+		// the added space is usable straight away
+		values = append(values, nBytes(2000))
+		assert.Nil(q.Enqueue(values[2]))
+
+		q = NewQueue(f)
+		elements, err := q.Elements()
+		assert.Nil(err)
+		assert.Equal(values, elements)
+	})
+
+	t.Run("refuses to shrink", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithCapacity(8192))
+
+		assert.NotNil(q.Grow(4096))
+		assert.Equal(uint32(8192), q.Capacity())
+	})
+
+	parameters := gopter.DefaultTestParameters()
+	properties := gopter.NewProperties(parameters)
+
+	properties.Property("growing never loses or reorders elements", prop.ForAll(
+		func(ops []int) (bool, error) {
+			f := NewMemBuffer()
+			q := NewQueue(f, WithCapacity(headerLength+64))
+
+			var model [][]byte
+			for i, op := range ops {
+				switch {
+				case op < 5:
+					v := []byte(fmt.Sprintf("e%d", i))
+					err := q.Enqueue(v)
+					if err == ErrQueueFull {
+						continue
+					}
+					if err != nil {
+						return false, err
+					}
+					model = append(model, v)
+				case op < 8:
+					v, err := q.Dequeue()
+					if len(model) == 0 {
+						if err != ErrQueueEmpty {
+							return false, err
+						}
+						continue
+					}
+					if err != nil {
+						return false, err
+					}
+					if !bytes.Equal(model[0], v) {
+						return false, nil
+					}
+					model = model[1:]
+				default:
+					if err := q.Grow(q.Capacity() + uint32(op)*4); err != nil {
+						return false, err
+					}
+				}
+			}
+
+			elements, err := NewQueue(f).Elements()
+			if err != nil {
+				return false, err
+			}
+			if len(elements) != len(model) {
+				return false, nil
+			}
+			for i := range model {
+				if !bytes.Equal(model[i], elements[i]) {
+					return false, nil
+				}
+			}
+
+			return true, nil
+		},
+		gen.SliceOf(gen.IntRange(0, 9)),
+	))
+
+	properties.TestingRun(t)
+}
+
+func TestEnsureCapacity(t *testing.T) {
+	assert := assert.New(t)
+
+	// frames of 4 byte payloads take 8 bytes with the default framing
+	frames := func(q *Queue, n int) error {
+		for i := 0; i < n; i++ {
+			if err := q.Enqueue(nBytes(4)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	t.Run("grows to fit", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithCapacity(headerLength+64))
+		assert.Nil(frames(q, 4))
+
+		assert.Nil(q.EnsureCapacity(100 * 8))
+		assert.Equal(uint32(headerLength+32+100*8), q.Capacity())
+		assert.Nil(frames(q, 100))
+		assert.Equal(ErrQueueFull, q.Enqueue(nBytes(4)))
+	})
+
+	t.Run("no-op when the space is free", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithCapacity(headerLength+64))
+		assert.Nil(frames(q, 6))
+		for i := 0; i < 5; i++ {
+			_, err := q.Dequeue()
+			assert.Nil(err)
+		}
+
+		// the free space at the front of the buffer holds the frames
+		assert.Nil(q.EnsureCapacity(40))
+		assert.Equal(uint32(headerLength+64), q.Capacity())
+		assert.Nil(frames(q, 5))
+	})
+
+	t.Run("wrapped", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithCapacity(headerLength+64))
+		assert.Nil(frames(q, 8))
+		for i := 0; i < 4; i++ {
+			_, err := q.Dequeue()
+			assert.Nil(err)
+		}
+		assert.Nil(frames(q, 2))
+		assert.True(q.IsWrapped())
+
+		assert.Nil(q.EnsureCapacity(10 * 8))
+		assert.False(q.IsWrapped())
+		assert.Nil(frames(q, 10))
+		assert.Equal(16, q.Len())
+	})
+}
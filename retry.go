@@ -0,0 +1,50 @@
+package queue
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// WithIORetry retries reads and writes of the backing store that fail,
+// up to attempts more times, waiting backoff before the first retry and
+// doubling the wait before each one after that
+//
+// Every retry repeats the whole read or write at its intended offset, so
+// a retried write never appends twice. Reaching the end of the file is
+// not treated as a failure and is never retried.
+func WithIORetry(attempts int, backoff time.Duration) Option {
+	return func(ls *Queue) {
+		ls.retryAttempts = attempts
+		ls.retryBackoff = backoff
+	}
+}
+
+// retry calls op until it succeeds, it fails with an error that transient
+// rejects, or the configured retries are used up
+func (ls *Queue) retry(transient func(error) bool, op func() error) error {
+	err := op()
+
+	delay := ls.retryBackoff
+	for i := 0; i < ls.retryAttempts && err != nil && transient(err); i++ {
+		time.Sleep(delay)
+		delay *= 2
+
+		err = op()
+	}
+
+	return err
+}
+
+// isTransient reports whether err returned by the backing store may
+// succeed if the operation is retried
+func isTransient(err error) bool {
+	return !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// isIOError reports whether err was caused by the backing store rather
+// than by the data read from it
+func isIOError(err error) bool {
+	var ioErr *IOError
+	return errors.As(err, &ioErr)
+}
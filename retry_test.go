@@ -0,0 +1,64 @@
+package queue
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIORetry(t *testing.T) {
+	assert := assert.New(t)
+
+	newFlakyQueue := func(opts ...Option) (*Queue, *flakyReadWriteSeeker) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		rws := newFlakyReadWriteSeeker(f)
+		return NewQueue(rws, opts...), rws
+	}
+
+	t.Run("failed writes are retried at the same offset", func(t *testing.T) {
+		q, rws := newFlakyQueue(WithIORetry(2, time.Millisecond))
+
+		// fail the element write, then the header write
+		rws.failWrites(1)
+		assert.Nil(q.Enqueue([]byte("a")))
+		rws.failWrites(2)
+		assert.Nil(q.Enqueue([]byte("b")))
+		assert.Nil(q.HealthCheck())
+
+		elements, err := q.Elements()
+		assert.Nil(err)
+		assert.Equal([][]byte{[]byte("a"), []byte("b")}, elements)
+	})
+
+	t.Run("failed reads are retried", func(t *testing.T) {
+		q, rws := newFlakyQueue(WithIORetry(1, time.Millisecond))
+		assert.Nil(q.Enqueue([]byte("a")))
+
+		rws.failReads(1)
+		front, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Equal([]byte("a"), front)
+	})
+
+	t.Run("retries are bounded", func(t *testing.T) {
+		q, rws := newFlakyQueue(WithIORetry(2, time.Millisecond))
+
+		rws.failWrites(3)
+		assert.NotNil(q.Enqueue([]byte("a")))
+		assert.Equal(0, q.Len())
+
+		assert.Nil(q.Enqueue([]byte("b")))
+	})
+
+	t.Run("no retries by default", func(t *testing.T) {
+		q, rws := newFlakyQueue()
+
+		rws.failWrites(1)
+		assert.NotNil(q.Enqueue([]byte("a")))
+		assert.Nil(q.Enqueue([]byte("b")))
+	})
+}
@@ -0,0 +1,124 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// sequenceLength is the length of the sequence number at the start of
+// each element body in a sequenced queue
+const sequenceLength = 8
+
+var ErrNotSequenced = errors.New("queue does not store sequence numbers")
+
+// WithSequenceNumbers makes a newly created queue tag each element with a
+// durable sequence number, starting at 1 and increasing by one with every
+// enqueue, which DequeueWithSeq returns alongside the element
+//
+// The next sequence number is kept in the file header, so numbering
+// continues where it left off when the queue is reopened, and numbers are
+// never reused even after elements are dequeued. Each element grows by 8
+// bytes. Whether a queue is sequenced is recorded in the file, so the
+// option has no effect when reopening an existing queue, but it must be
+// passed to Repair to recover a sequenced queue.
+func WithSequenceNumbers() Option {
+	return func(ls *Queue) {
+		ls.sequenceNumbers = true
+	}
+}
+
+// WithStartingSequence makes a newly created queue with WithSequenceNumbers
+// number its first element seq instead of 1, for example to continue the
+// numbering of another system
+//
+// Like the numbering itself, the option only applies when the queue file
+// is created, so reopening a queue never changes its next sequence number.
+func WithStartingSequence(seq uint64) Option {
+	return func(ls *Queue) {
+		ls.firstSequence = seq
+	}
+}
+
+// DequeueWithSeq removes and returns the item at the front of the queue
+// along with its sequence number, or ErrNotSequenced if the queue was not
+// created with WithSequenceNumbers
+func (ls *Queue) DequeueWithSeq() ([]byte, uint64, error) {
+	if err := ls.pace(context.Background()); err != nil {
+		return nil, 0, err
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if !ls.sequenced() {
+		return nil, 0, ErrNotSequenced
+	}
+
+	v, seq, _, _, err := ls.dequeueNumbered(nil)
+	return v, seq, err
+}
+
+// sequenced reports whether element bodies begin with a sequence number
+func (ls *Queue) sequenced() bool {
+	return ls.header.flags&headerSequenced != 0
+}
+
+// prefixLength returns the length of the sequence number and timestamp,
+// as the queue requires, at the start of each element body
+func (ls *Queue) prefixLength() uint32 {
+	var n uint32
+	if ls.sequenced() {
+		n += sequenceLength
+	}
+	if ls.timestamped() {
+		n += timestampLength
+	}
+	return n
+}
+
+// number prefixes body with the sequence number of the next element when
+// the queue is sequenced, and with the current time when it is timestamped
+func (ls *Queue) number(body []byte) []byte {
+	return ls.numberAs(body, ls.header.nextSequence, time.Now().UnixNano())
+}
+
+// numberAs prefixes body with seq when the queue is sequenced and with
+// stamp when it is timestamped
+func (ls *Queue) numberAs(body []byte, seq uint64, stamp int64) []byte {
+	n := ls.prefixLength()
+	if n == 0 {
+		return body
+	}
+
+	numbered := make([]byte, n+uint32(len(body)))
+	prefix := numbered
+	if ls.sequenced() {
+		ls.header.order().PutUint64(prefix, seq)
+		prefix = prefix[sequenceLength:]
+	}
+	if ls.timestamped() {
+		ls.header.order().PutUint64(prefix, uint64(stamp))
+	}
+	copy(numbered[n:], body)
+	return numbered
+}
+
+// unnumber splits the sequence number, if the queue is sequenced, and the
+// timestamp, if it is timestamped, from the rest of body
+func (ls *Queue) unnumber(body []byte) ([]byte, uint64, error) {
+	n := ls.prefixLength()
+	if n == 0 {
+		return body, 0, nil
+	}
+
+	if uint32(len(body)) < n {
+		return nil, 0, errors.New("element is missing its sequence number or timestamp")
+	}
+
+	var seq uint64
+	if ls.sequenced() {
+		seq = ls.header.order().Uint64(body)
+	}
+	return body[n:], seq, nil
+}
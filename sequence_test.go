@@ -0,0 +1,100 @@
+package queue
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSequenceNumbers(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("increase across reopens", func(t *testing.T) {
+		f := NewMemBuffer()
+		q := NewQueue(f, WithCapacity(headerLength+128), WithSequenceNumbers())
+
+		var last uint64
+		dequeue := func(q *Queue, want []byte) {
+			v, seq, err := q.DequeueWithSeq()
+			assert.Nil(err)
+			assert.Equal(want, v)
+			assert.Greater(seq, last)
+			last = seq
+		}
+
+		assert.Nil(q.Enqueue([]byte("a")))
+		assert.Nil(q.Enqueue([]byte("b")))
+		dequeue(q, []byte("a"))
+		assert.Equal(uint64(1), last)
+
+		// simulate a restart with an element still queued
+		q = NewQueue(f)
+		assert.Nil(q.Transaction(func(tx *Tx) error {
+			tx.Enqueue([]byte("c"))
+			tx.Enqueue([]byte("d"))
+			return nil
+		}))
+		assert.Nil(q.EnqueueReader(bytes.NewReader([]byte("e")), 1))
+
+		elements, err := q.Elements()
+		assert.Nil(err)
+		assert.Equal([][]byte{[]byte("b"), []byte("c"), []byte("d"), []byte("e")}, elements)
+
+		for _, v := range elements {
+			dequeue(q, v)
+		}
+		assert.Equal(uint64(5), last)
+
+		// numbers are not reused once the queue is empty
+		q = NewQueue(f)
+		for i := 0; i < 20; i++ {
+			assert.Nil(q.Enqueue([]byte("f")))
+			dequeue(q, []byte("f"))
+		}
+		assert.Equal(uint64(25), last)
+	})
+
+	t.Run("repair", func(t *testing.T) {
+		f := NewMemBuffer()
+		q := NewQueue(f, WithSequenceNumbers())
+		for i := 0; i < 3; i++ {
+			assert.Nil(q.Enqueue([]byte("a")))
+		}
+
+		q, err := Repair(f, WithSequenceNumbers())
+		assert.Nil(err)
+		assert.Nil(q.Enqueue([]byte("b")))
+		assert.Equal(uint64(5), q.header.nextSequence)
+	})
+
+	t.Run("starting sequence", func(t *testing.T) {
+		f := NewMemBuffer()
+		q := NewQueue(f, WithSequenceNumbers(), WithStartingSequence(1000))
+		assert.Nil(q.Enqueue([]byte("a")))
+		assert.Nil(q.Enqueue([]byte("b")))
+
+		v, seq, err := q.DequeueWithSeq()
+		assert.Nil(err)
+		assert.Equal([]byte("a"), v)
+		assert.Equal(uint64(1000), seq)
+
+		// reopening keeps the numbering of the existing queue
+		q = NewQueue(f, WithSequenceNumbers(), WithStartingSequence(1))
+		assert.Nil(q.Enqueue([]byte("c")))
+		for _, want := range []uint64{1001, 1002} {
+			_, seq, err := q.DequeueWithSeq()
+			assert.Nil(err)
+			assert.Equal(want, seq)
+		}
+	})
+
+	t.Run("not sequenced", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+		assert.Nil(q.Enqueue([]byte("a")))
+
+		_, _, err := q.DequeueWithSeq()
+		assert.Equal(ErrNotSequenced, err)
+		assert.Equal(1, q.Len())
+	})
+}
@@ -0,0 +1,97 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+// ShardedQueue spreads elements across several queues by key, so that
+// elements with the same key always land on the same shard and keep their
+// relative order
+//
+// The shard of a key is its FNV-1a hash modulo the number of shards, so
+// the shards must be passed in the same order, and their number must not
+// change, for keys to map to the same shards across restarts.
+type ShardedQueue struct {
+	mu     sync.Mutex
+	shards []*Queue
+	next   int // shard at which the next round-robin Dequeue starts
+}
+
+// NewShardedQueue returns a ShardedQueue over shards
+func NewShardedQueue(shards ...*Queue) (*ShardedQueue, error) {
+	if len(shards) == 0 {
+		return nil, errors.New("sharded queue needs at least one shard")
+	}
+
+	return &ShardedQueue{shards: shards}, nil
+}
+
+// Shard returns the index of the shard that holds elements with key
+func (sq *ShardedQueue) Shard(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(sq.shards)))
+}
+
+// Enqueue adds a value to the shard of key
+func (sq *ShardedQueue) Enqueue(key string, v []byte) error {
+	return sq.shards[sq.Shard(key)].Enqueue(v)
+}
+
+// Dequeue removes and returns the item at the front of the next non-empty
+// shard, visiting the shards in turn so that none is starved, or returns
+// ErrQueueEmpty if every shard is empty
+//
+// Elements are only ordered within a shard, not across shards.
+func (sq *ShardedQueue) Dequeue() ([]byte, error) {
+	sq.mu.Lock()
+	defer sq.mu.Unlock()
+
+	for i := 0; i < len(sq.shards); i++ {
+		shard := (sq.next + i) % len(sq.shards)
+
+		v, ok, err := sq.shards[shard].TryDequeue()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			sq.next = (shard + 1) % len(sq.shards)
+			return v, nil
+		}
+	}
+
+	return nil, ErrQueueEmpty
+}
+
+// DequeueShard removes and returns the item at the front of the shard at
+// index
+func (sq *ShardedQueue) DequeueShard(index int) ([]byte, error) {
+	if index < 0 || index >= len(sq.shards) {
+		return nil, fmt.Errorf("shard %d of %d: %w", index, len(sq.shards), ErrIndexOutOfRange)
+	}
+
+	return sq.shards[index].Dequeue()
+}
+
+// Len returns the number of elements across all shards
+func (sq *ShardedQueue) Len() int {
+	n := 0
+	for _, q := range sq.shards {
+		n += q.Len()
+	}
+	return n
+}
+
+// Close closes every shard, returning the first error encountered
+func (sq *ShardedQueue) Close() error {
+	var first error
+	for _, q := range sq.shards {
+		if err := q.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,101 @@
+package queue
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShardedQueue(t *testing.T) {
+	assert := assert.New(t)
+
+	newShards := func(n int) ([]*MemBuffer, []*Queue) {
+		var files []*MemBuffer
+		var shards []*Queue
+		for i := 0; i < n; i++ {
+			f := NewMemBuffer()
+			files = append(files, f)
+			shards = append(shards, NewQueue(f))
+		}
+		return files, shards
+	}
+
+	t.Run("keys land on stable shards", func(t *testing.T) {
+		files, shards := newShards(4)
+		sq, err := NewShardedQueue(shards...)
+		assert.Nil(err)
+
+		used := make(map[int]bool)
+		for i := 0; i < 20; i++ {
+			key := fmt.Sprintf("key-%d", i)
+			shard := sq.Shard(key)
+			used[shard] = true
+
+			assert.Nil(sq.Enqueue(key, []byte(key)))
+			tail, err := shards[shard].PeekTail()
+			assert.Nil(err)
+			assert.Equal([]byte(key), tail)
+		}
+		assert.Greater(len(used), 1)
+
+		// the same shards are chosen after reopening
+		var reopened []*Queue
+		for _, f := range files {
+			reopened = append(reopened, NewQueue(f))
+		}
+		sq2, err := NewShardedQueue(reopened...)
+		assert.Nil(err)
+		for i := 0; i < 20; i++ {
+			key := fmt.Sprintf("key-%d", i)
+			assert.Equal(sq.Shard(key), sq2.Shard(key))
+		}
+	})
+
+	t.Run("aggregate operations cover every shard", func(t *testing.T) {
+		_, shards := newShards(3)
+		sq, err := NewShardedQueue(shards...)
+		assert.Nil(err)
+
+		for i, q := range shards {
+			for j := 0; j <= i; j++ {
+				assert.Nil(q.Enqueue([]byte(fmt.Sprintf("%d-%d", i, j))))
+			}
+		}
+		assert.Equal(6, sq.Len())
+
+		// round-robin visits each non-empty shard in turn
+		var got []string
+		for {
+			v, err := sq.Dequeue()
+			if err == ErrQueueEmpty {
+				break
+			}
+			assert.Nil(err)
+			got = append(got, string(v))
+		}
+		assert.Equal([]string{"0-0", "1-0", "2-0", "1-1", "2-1", "2-2"}, got)
+		assert.Equal(0, sq.Len())
+	})
+
+	t.Run("drain a shard", func(t *testing.T) {
+		_, shards := newShards(2)
+		sq, err := NewShardedQueue(shards...)
+		assert.Nil(err)
+
+		assert.Nil(shards[1].Enqueue([]byte("a")))
+		v, err := sq.DequeueShard(1)
+		assert.Nil(err)
+		assert.Equal([]byte("a"), v)
+
+		_, err = sq.DequeueShard(0)
+		assert.Equal(ErrQueueEmpty, err)
+		_, err = sq.DequeueShard(2)
+		assert.NotNil(err)
+	})
+
+	t.Run("no shards", func(t *testing.T) {
+		_, err := NewShardedQueue()
+		assert.NotNil(err)
+	})
+}
@@ -0,0 +1,341 @@
+package queue
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+const (
+	sortedElement   byte = 0 // record holding an element
+	sortedTombstone byte = 1 // record removing the element at the offset it holds
+
+	sortedTombstoneLength = 5 // kind and offset of a tombstone record
+)
+
+// SortedQueue is a queue whose Dequeue returns the smallest element
+// according to a comparator rather than the oldest, with elements that
+// compare equal returned in the order they were enqueued
+//
+// Elements stay in the file in the order they were enqueued, and an index
+// of their offsets in comparator order is kept in memory and rebuilt by
+// scanning the queue when it is opened. Enqueue costs O(log n) element
+// reads to find the insertion point plus an O(n) shift of the in-memory
+// index; the element itself is written once, at the tail.
+//
+// Dequeuing an element that is not at the front of the file appends a
+// tombstone naming it, and the element is discarded once it reaches the
+// head. Enqueue keeps room for a tombstone for every element, failing
+// with ErrQueueFull otherwise, so Dequeue never needs free space.
+type SortedQueue struct {
+	mu      sync.Mutex
+	q       *Queue
+	cmp     func(a, b []byte) int
+	order   []uint32        // offsets of the live elements, smallest first
+	removed map[uint32]bool // offsets of removed elements not yet discarded
+}
+
+// NewSortedQueue opens the SortedQueue stored in f, creating it if f is
+// empty, and indexes its elements by cmp, which returns a negative number
+// when a sorts before b, zero when they are equal, and a positive number
+// otherwise
+//
+// Options that evict elements or keep them after they are dequeued are
+// rejected, as is WithCompactOnClose, which would give up the space kept
+// for tombstones.
+func NewSortedQueue(f io.ReadWriteSeeker, cmp func(a, b []byte) int, opts ...Option) (*SortedQueue, error) {
+	q, err := New(f, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.checkIndexable(); err != nil {
+		return nil, err
+	}
+	if q.compactOnClose {
+		return nil, errors.New("sorted queue does not support compacting on close")
+	}
+
+	sq := &SortedQueue{q: q, cmp: cmp, removed: make(map[uint32]bool)}
+	if err := sq.load(); err != nil {
+		return nil, err
+	}
+
+	return sq, nil
+}
+
+// Enqueue adds v to the queue after any elements that compare equal to it
+func (sq *SortedQueue) Enqueue(v []byte) error {
+	sq.mu.Lock()
+	defer sq.mu.Unlock()
+
+	// find the first element that sorts after v
+	var readErr error
+	i := sort.Search(len(sq.order), func(i int) bool {
+		other, err := sq.value(sq.order[i])
+		if err != nil {
+			readErr = err
+			return true
+		}
+		return sq.cmp(v, other) < 0
+	})
+	if readErr != nil {
+		return readErr
+	}
+
+	// reclaim the space of removed elements before checking for room
+	if _, _, err := sq.discard(); err != nil {
+		return err
+	}
+
+	offset, err := sq.enqueue(append([]byte{sortedElement}, v...))
+	if err != nil {
+		return err
+	}
+
+	sq.order = append(sq.order, 0)
+	copy(sq.order[i+1:], sq.order[i:])
+	sq.order[i] = offset
+
+	return nil
+}
+
+// Dequeue removes and returns the smallest element, or ErrQueueEmpty if
+// the queue is empty
+func (sq *SortedQueue) Dequeue() ([]byte, error) {
+	sq.mu.Lock()
+	defer sq.mu.Unlock()
+
+	if len(sq.order) == 0 {
+		return nil, ErrQueueEmpty
+	}
+
+	smallest := sq.order[0]
+	v, err := sq.value(smallest)
+	if err != nil {
+		return nil, err
+	}
+
+	front, ok, err := sq.discard()
+	if err != nil {
+		return nil, err
+	}
+
+	if ok && front == smallest {
+		if _, err := sq.q.Dequeue(); err != nil {
+			return nil, err
+		}
+	} else {
+		tombstone := make([]byte, sortedTombstoneLength)
+		tombstone[0] = sortedTombstone
+		binary.BigEndian.PutUint32(tombstone[1:], smallest)
+		if err := sq.q.Enqueue(tombstone); err != nil {
+			return nil, err
+		}
+		sq.removed[smallest] = true
+	}
+
+	sq.order = sq.order[1:]
+	return v, nil
+}
+
+// Peek returns the smallest element without removing it
+func (sq *SortedQueue) Peek() ([]byte, error) {
+	sq.mu.Lock()
+	defer sq.mu.Unlock()
+
+	if len(sq.order) == 0 {
+		return nil, ErrQueueEmpty
+	}
+
+	return sq.value(sq.order[0])
+}
+
+// Len returns the number of elements in the queue that have not been
+// dequeued
+func (sq *SortedQueue) Len() int {
+	sq.mu.Lock()
+	defer sq.mu.Unlock()
+
+	return len(sq.order)
+}
+
+// Close closes the underlying queue
+func (sq *SortedQueue) Close() error {
+	return sq.q.Close()
+}
+
+// enqueue writes record at the tail if the queue still has room for a
+// tombstone for every element afterwards, and returns its offset
+func (sq *SortedQueue) enqueue(record []byte) (uint32, error) {
+	q := sq.q
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !sq.fits(uint32(len(record))) {
+		return 0, ErrQueueFull
+	}
+	return q.enqueueWithPolicy(record, nil)
+}
+
+// fits reports whether a record of recordLength bytes could be enqueued
+// followed by a tombstone for each live element and for the record
+//
+// Queue.mu must be held by the caller
+func (sq *SortedQueue) fits(recordLength uint32) bool {
+	q := sq.q
+	original := q.header
+	defer func() { q.header = original }()
+
+	frameLength := q.frameLengthFor(recordLength)
+	tombstoneLength := q.frameLengthFor(sortedTombstoneLength)
+	for i := 0; i <= len(sq.order)+1; i++ {
+		if i > 0 {
+			frameLength = tombstoneLength
+		}
+		header, ok := q.reserve(frameLength)
+		if !ok {
+			return false
+		}
+		header.tailPosition += frameLength
+		header.queueSize++
+		q.header = header
+	}
+	return true
+}
+
+// value returns the element stored in the record at offset
+func (sq *SortedQueue) value(offset uint32) ([]byte, error) {
+	record, err := sq.q.ReadElementAt(offset)
+	if err != nil {
+		return nil, err
+	}
+
+	kind, v, err := decodeSortedRecord(record)
+	if err != nil {
+		return nil, err
+	}
+	if kind != sortedElement {
+		return nil, fmt.Errorf("record at %d is not an element", offset)
+	}
+	return v, nil
+}
+
+// front returns the offset and record at the front of the file
+//
+// Queue.mu must be held by the caller and the queue must not be empty.
+func (sq *SortedQueue) front() (uint32, []byte, error) {
+	q := sq.q
+	var (
+		offset    uint32
+		record    []byte
+		decodeErr error
+	)
+	err := q.walk(func(pos, _ uint32, body []byte) bool {
+		offset = pos
+		record, decodeErr = q.decodeElement(body)
+		return false
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return offset, record, decodeErr
+}
+
+// discard dequeues the tombstones and removed elements at the front of
+// the file and returns the offset of the element then at the front, if any
+func (sq *SortedQueue) discard() (front uint32, ok bool, err error) {
+	q := sq.q
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for q.header.queueSize > 0 {
+		pos, record, err := sq.front()
+		if err != nil {
+			return 0, false, err
+		}
+		kind, _, err := decodeSortedRecord(record)
+		if err != nil {
+			return 0, false, err
+		}
+		if kind == sortedElement && !sq.removed[pos] {
+			return pos, true, nil
+		}
+
+		if _, err := q.dequeue(); err != nil {
+			return 0, false, err
+		}
+		delete(sq.removed, pos)
+	}
+
+	return 0, false, nil
+}
+
+// load rebuilds the index by scanning the records in the queue
+func (sq *SortedQueue) load() error {
+	q := sq.q
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var (
+		offsets []uint32
+		values  = make(map[uint32][]byte)
+		loadErr error
+	)
+	err := q.walk(func(pos, _ uint32, body []byte) bool {
+		record, err := q.decodeElement(body)
+		if err != nil {
+			loadErr = err
+			return false
+		}
+
+		kind, v, err := decodeSortedRecord(record)
+		if err != nil {
+			loadErr = fmt.Errorf("record at %d: %w", pos, err)
+			return false
+		}
+
+		switch kind {
+		case sortedElement:
+			offsets = append(offsets, pos)
+			values[pos] = v
+		case sortedTombstone:
+			// a tombstone follows the element it names, unless that
+			// element has already left the file
+			target := binary.BigEndian.Uint32(v)
+			if _, ok := values[target]; ok {
+				delete(values, target)
+				sq.removed[target] = true
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if loadErr != nil {
+		return loadErr
+	}
+
+	for _, offset := range offsets {
+		if _, ok := values[offset]; ok {
+			sq.order = append(sq.order, offset)
+		}
+	}
+	sort.SliceStable(sq.order, func(i, j int) bool {
+		return sq.cmp(values[sq.order[i]], values[sq.order[j]]) < 0
+	})
+
+	return nil
+}
+
+// decodeSortedRecord splits a record into its kind and its element, or
+// the offset a tombstone names
+func decodeSortedRecord(record []byte) (kind byte, v []byte, err error) {
+	if len(record) < 1 || record[0] > sortedTombstone || (record[0] == sortedTombstone && len(record) != sortedTombstoneLength) {
+		return 0, nil, errors.New("malformed sorted queue record")
+	}
+	return record[0], record[1:], nil
+}
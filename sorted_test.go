@@ -0,0 +1,156 @@
+package queue
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortedQueue(t *testing.T) {
+	assert := assert.New(t)
+
+	dequeueAll := func(sq *SortedQueue) []string {
+		var values []string
+		for {
+			v, err := sq.Dequeue()
+			if err == ErrQueueEmpty || !assert.Nil(err) {
+				return values
+			}
+			values = append(values, string(v))
+		}
+	}
+
+	t.Run("out of order", func(t *testing.T) {
+		sq, err := NewSortedQueue(NewMemBuffer(), bytes.Compare)
+		assert.Nil(err)
+
+		for _, v := range []string{"d", "b", "e", "a", "c"} {
+			assert.Nil(sq.Enqueue([]byte(v)))
+		}
+		assert.Equal(5, sq.Len())
+
+		v, err := sq.Peek()
+		assert.Nil(err)
+		assert.Equal([]byte("a"), v)
+
+		assert.Equal([]string{"a", "b", "c", "d", "e"}, dequeueAll(sq))
+		assert.Equal(0, sq.Len())
+
+		_, err = sq.Peek()
+		assert.Equal(ErrQueueEmpty, err)
+	})
+
+	t.Run("interleaved", func(t *testing.T) {
+		sq, err := NewSortedQueue(NewMemBuffer(), bytes.Compare, WithCapacity(512))
+		assert.Nil(err)
+
+		// enough rounds to wrap the file many times, reusing the space of
+		// removed elements and tombstones; enqueues may find the queue
+		// full while removed elements wait to reach the front, but
+		// dequeues always succeed
+		rng := rand.New(rand.NewSource(1))
+		var model []string
+		for i := 0; i < 500; i++ {
+			if len(model) < 16 && rng.Intn(3) > 0 {
+				v := string([]byte{byte('a' + rng.Intn(26)), byte('a' + rng.Intn(26))})
+				err := sq.Enqueue([]byte(v))
+				if err == nil {
+					model = append(model, v)
+					sort.Strings(model)
+					continue
+				}
+				if !assert.Equal(ErrQueueFull, err) {
+					return
+				}
+			}
+
+			if len(model) > 0 {
+				got, err := sq.Dequeue()
+				if !assert.Nil(err) {
+					return
+				}
+				assert.Equal(model[0], string(got))
+				model = model[1:]
+			}
+		}
+
+		assert.Equal(model, dequeueAll(sq))
+	})
+
+	t.Run("full", func(t *testing.T) {
+		sq, err := NewSortedQueue(NewMemBuffer(), bytes.Compare, WithCapacity(256))
+		assert.Nil(err)
+
+		// fill the queue in descending order, so that every dequeue but
+		// the last removes an element from behind the front of the file
+		var want []string
+		for i := 99; i >= 0; i-- {
+			v := fmt.Sprintf("%02d", i)
+			err := sq.Enqueue([]byte(v))
+			if err == ErrQueueFull {
+				break
+			}
+			assert.Nil(err)
+			want = append([]string{v}, want...)
+		}
+		assert.NotEmpty(want)
+		assert.Less(len(want), 100)
+
+		assert.Equal(want, dequeueAll(sq))
+	})
+
+	t.Run("ties", func(t *testing.T) {
+		// compare on the first byte only, so equal elements keep the
+		// order they were enqueued in
+		cmp := func(a, b []byte) int {
+			return int(a[0]) - int(b[0])
+		}
+		sq, err := NewSortedQueue(NewMemBuffer(), cmp)
+		assert.Nil(err)
+
+		for _, v := range []string{"b1", "a1", "b2", "a2", "b3"} {
+			assert.Nil(sq.Enqueue([]byte(v)))
+		}
+
+		assert.Equal([]string{"a1", "a2", "b1", "b2", "b3"}, dequeueAll(sq))
+	})
+
+	t.Run("reopen", func(t *testing.T) {
+		f := NewMemBuffer()
+		sq, err := NewSortedQueue(f, bytes.Compare)
+		assert.Nil(err)
+
+		for _, v := range []string{"c", "a", "d", "b"} {
+			assert.Nil(sq.Enqueue([]byte(v)))
+		}
+		// "a" is not at the front of the file, so it leaves a tombstone
+		v, err := sq.Dequeue()
+		assert.Nil(err)
+		assert.Equal([]byte("a"), v)
+
+		sq, err = NewSortedQueue(f, bytes.Compare)
+		assert.Nil(err)
+		assert.Equal(3, sq.Len())
+
+		assert.Nil(sq.Enqueue([]byte("a")))
+		assert.Equal([]string{"a", "b", "c", "d"}, dequeueAll(sq))
+	})
+}
+
+func TestSortedQueueOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	for name, opt := range map[string]Option{
+		"overwrite oldest": WithOverwriteOldest(),
+		"full policy":      WithFullPolicy(func([]byte, *Queue) FullAction { return ActionDropOldest }),
+		"compact on close": WithCompactOnClose(),
+		"consumer offset":  WithConsumerOffset(),
+	} {
+		_, err := NewSortedQueue(NewMemBuffer(), bytes.Compare, opt)
+		assert.NotNil(err, name)
+	}
+}
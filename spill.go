@@ -0,0 +1,106 @@
+package queue
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// spillPrefix starts the name of every spilled file
+const spillPrefix = "fq-spill-"
+
+// WithSpillDir stores elements too large to fit in the buffer as separate
+// files in dir, keeping only a small reference to each in the queue
+//
+// Spilled files are read back transparently and deleted once their element
+// is dequeued. Each element carries a 1-byte flag recording whether it was
+// spilled, which is recorded in the header as for WithCompression, so a
+// queue written with spillover must be reopened with it or with
+// compression.
+func WithSpillDir(dir string) Option {
+	return func(ls *Queue) {
+		ls.spillDir = dir
+	}
+}
+
+// spill writes v to a new file in the spill directory and returns the
+// element body referencing it: the spilled flag, the 8-byte length of v,
+// and the name of the file
+func (ls *Queue) spill(v []byte) ([]byte, error) {
+	f, err := ioutil.TempFile(ls.spillDir, spillPrefix+"*")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := f.Write(v); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return nil, err
+	}
+
+	name := filepath.Base(f.Name())
+	body := make([]byte, 1+8+len(name))
+	body[0] = elementSpilled
+	binary.BigEndian.PutUint64(body[1:9], uint64(len(v)))
+	copy(body[9:], name)
+	return body, nil
+}
+
+// readSpilled returns the payload referenced by ref, the body of a spilled
+// element without its flag
+func (ls *Queue) readSpilled(ref []byte) ([]byte, error) {
+	if len(ref) < 8 {
+		return nil, fmt.Errorf("%w: spilled element reference is truncated", ErrCorrupt)
+	}
+
+	path, err := ls.spillPath(ref[8:])
+	if err != nil {
+		return nil, err
+	}
+
+	length := binary.BigEndian.Uint64(ref[:8])
+	v, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if uint64(len(v)) != length {
+		return nil, fmt.Errorf("spilled element %s has length %d, expected %d", ref[8:], len(v), length)
+	}
+
+	return v, nil
+}
+
+// removeSpilled deletes the file referenced by body if it is the body of a
+// spilled element
+//
+// Failing to remove the file only leaks disk space, so errors are ignored.
+func (ls *Queue) removeSpilled(body []byte) {
+	body, _, err := ls.unnumber(body)
+	if err != nil || ls.spillDir == "" || len(body) < 9 || body[0] != elementSpilled {
+		return
+	}
+
+	if path, err := ls.spillPath(body[9:]); err == nil {
+		os.Remove(path)
+	}
+}
+
+// spillPath returns the path of the spilled file called name, which comes
+// from the queue file and so is checked to name a spilled file directly
+// inside the spill directory
+func (ls *Queue) spillPath(name []byte) (string, error) {
+	s := string(name)
+	if filepath.Base(s) != s || !strings.HasPrefix(s, spillPrefix) {
+		return "", fmt.Errorf("%w: invalid spilled file name %q", ErrCorrupt, s)
+	}
+	return filepath.Join(ls.spillDir, s), nil
+}
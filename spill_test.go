@@ -0,0 +1,125 @@
+package queue
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpillDir(t *testing.T) {
+	assert := assert.New(t)
+
+	newSpillQueue := func(opts ...Option) (*Queue, string) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		dir, err := ioutil.TempDir("", "spill-*")
+		assert.Nil(err)
+
+		opts = append([]Option{WithCapacity(headerLength + 256), WithSpillDir(dir)}, opts...)
+		return NewQueue(f, opts...), dir
+	}
+
+	spilledFiles := func(dir string) int {
+		entries, err := ioutil.ReadDir(dir)
+		assert.Nil(err)
+		return len(entries)
+	}
+
+	t.Run("inline and spilled elements round trip", func(t *testing.T) {
+		q, dir := newSpillQueue()
+		defer os.RemoveAll(dir)
+
+		small, giant := nBytes(100), nBytes(10000)
+		assert.Nil(q.Enqueue(small))
+		assert.Nil(q.Enqueue(giant))
+		assert.Nil(q.Enqueue(small))
+		assert.Equal(1, spilledFiles(dir))
+
+		elements, err := q.Elements()
+		assert.Nil(err)
+		assert.Equal([][]byte{small, giant, small}, elements)
+
+		for _, expected := range [][]byte{small, giant, small} {
+			v, err := q.Dequeue()
+			assert.Nil(err)
+			assert.Equal(expected, v)
+		}
+		assert.Equal(0, spilledFiles(dir))
+	})
+
+	t.Run("spilled file is removed when enqueue fails", func(t *testing.T) {
+		q, dir := newSpillQueue()
+		defer os.RemoveAll(dir)
+
+		assert.Nil(q.Enqueue(nBytes(240)))
+		assert.Equal(ErrQueueFull, q.Enqueue(nBytes(10000)))
+		assert.Equal(0, spilledFiles(dir))
+	})
+
+	t.Run("spilled file is removed when evicted", func(t *testing.T) {
+		q, dir := newSpillQueue(WithOverwriteOldest())
+		defer os.RemoveAll(dir)
+
+		assert.Nil(q.Enqueue(nBytes(10000)))
+		assert.Nil(q.Enqueue(nBytes(240)))
+		assert.Equal(uint64(1), q.Evicted())
+		assert.Equal(0, spilledFiles(dir))
+	})
+
+	t.Run("spilling with compression", func(t *testing.T) {
+		codec, err := NewFlateCodec(1)
+		assert.Nil(err)
+
+		q, dir := newSpillQueue(WithCompression(codec))
+		defer os.RemoveAll(dir)
+
+		compressible := make([]byte, 10000)
+		giant := nBytes(10000)
+		assert.Nil(q.Enqueue(compressible))
+		assert.Nil(q.Enqueue(giant))
+		assert.Equal(1, spilledFiles(dir))
+
+		for _, expected := range [][]byte{compressible, giant} {
+			v, err := q.Dequeue()
+			assert.Nil(err)
+			assert.Equal(expected, v)
+		}
+	})
+
+	t.Run("references outside the spill directory are rejected", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "spill-*")
+		assert.Nil(err)
+		defer os.RemoveAll(dir)
+
+		f := NewMemBuffer()
+		q := NewQueue(f, WithCapacity(headerLength+256), WithSpillDir(dir))
+		assert.Nil(q.Enqueue(nBytes(10000)))
+
+		entries, err := ioutil.ReadDir(dir)
+		assert.Nil(err)
+		name := entries[0].Name()
+
+		// point the reference at a file next to the spill directory
+		hostile := "../" + strings.TrimPrefix(name, "fq-")
+		victim := filepath.Join(dir, hostile)
+		assert.Nil(ioutil.WriteFile(victim, nBytes(10000), 0600))
+		defer os.Remove(victim)
+
+		i := bytes.Index(f.Bytes(), []byte(name))
+		assert.True(i > 0)
+		copy(f.Bytes()[i:], hostile)
+
+		_, err = q.Dequeue()
+		assert.True(errors.Is(err, ErrCorrupt))
+
+		_, err = os.Stat(victim)
+		assert.Nil(err)
+	})
+}
@@ -0,0 +1,160 @@
+package queue
+
+import (
+	"io"
+	"sync"
+)
+
+// Stack is a LIFO stack stored in the queue file format, pushing and
+// popping elements at the tail
+//
+// Element frames only link forward, so the offset of every element is kept
+// in memory and rebuilt by scanning the stack when it is opened. A Stack
+// file can be opened as a Queue, which sees the elements oldest first.
+type Stack struct {
+	mu      sync.Mutex
+	q       *Queue
+	offsets []uint32 // offset of each element, oldest first
+}
+
+// NewStack opens the Stack stored in f, creating it if f is empty, and
+// indexes its elements
+//
+// Evicting the oldest elements to make room would pull them out from
+// under the bottom of the stack, so options that evict, such as
+// WithOverwriteOldest, are rejected, as is WithConsumerOffset.
+func NewStack(f io.ReadWriteSeeker, opts ...Option) (*Stack, error) {
+	q, err := New(f, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.checkIndexable(); err != nil {
+		return nil, err
+	}
+
+	s := &Stack{q: q}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	err = q.walk(func(pos, _ uint32, _ []byte) bool {
+		s.offsets = append(s.offsets, pos)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Push adds v to the top of the stack
+func (s *Stack) Push(v []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	offset, err := s.q.EnqueueAt(v)
+	if err != nil {
+		return err
+	}
+
+	s.offsets = append(s.offsets, offset)
+	return nil
+}
+
+// Pop removes and returns the most recently pushed element, or
+// ErrQueueEmpty if the stack is empty
+func (s *Stack) Pop() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.offsets) == 0 {
+		return nil, ErrQueueEmpty
+	}
+
+	v, err := s.q.removeTail(s.offsets[len(s.offsets)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	s.offsets = s.offsets[:len(s.offsets)-1]
+	return v, nil
+}
+
+// Peek returns the most recently pushed element without removing it
+func (s *Stack) Peek() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.offsets) == 0 {
+		return nil, ErrQueueEmpty
+	}
+
+	return s.q.ReadElementAt(s.offsets[len(s.offsets)-1])
+}
+
+// Len returns the number of elements on the stack
+func (s *Stack) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.offsets)
+}
+
+// Close closes the underlying queue
+func (s *Stack) Close() error {
+	return s.q.Close()
+}
+
+// removeTail removes the last element, whose frame starts at pos, and
+// returns its payload
+func (ls *Queue) removeTail(pos uint32) ([]byte, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if ls.readOnly {
+		return nil, ErrReadOnly
+	}
+
+	body, frameLength, err := ls.readElement(pos, ls.header.fileLength)
+	if err != nil {
+		return nil, err
+	}
+	v, err := ls.decodeElement(body)
+	if err != nil {
+		return nil, err
+	}
+
+	original := ls.header
+	ls.header.tailPosition = pos
+	ls.header.queueSize--
+
+	// the elements at the end of the buffer are on top once the front of
+	// a wrapped queue has been popped
+	if ls.isWrapped() && pos == ls.dataStart() {
+		ls.header.tailPosition = ls.header.wrapPosition
+		ls.header.wrapPosition = 0
+	}
+
+	if ls.header.queueSize == 0 && !ls.appendOnly && !ls.noResetOnEmpty {
+		ls.header.headPosition = ls.dataStart()
+		ls.header.tailPosition = ls.dataStart()
+		ls.header.wrapPosition = 0
+	}
+
+	if err := ls.syncHeader(); err != nil {
+		ls.header = original
+		return nil, err
+	}
+	ls.cond.Broadcast()
+
+	ls.removeSpilled(body)
+	ls.recordDequeue(len(v))
+
+	if ls.zeroOnDequeue {
+		if err := ls.zero(pos, pos+frameLength); err != nil {
+			return nil, err
+		}
+	}
+
+	return v, nil
+}
@@ -0,0 +1,92 @@
+package queue
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStack(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("lifo order across reopens", func(t *testing.T) {
+		f := NewMemBuffer()
+		s, err := NewStack(f)
+		assert.Nil(err)
+
+		for _, v := range []string{"a", "b", "c"} {
+			assert.Nil(s.Push([]byte(v)))
+		}
+
+		top, err := s.Peek()
+		assert.Nil(err)
+		assert.Equal([]byte("c"), top)
+
+		v, err := s.Pop()
+		assert.Nil(err)
+		assert.Equal([]byte("c"), v)
+		assert.Nil(s.Push([]byte("d")))
+
+		s, err = NewStack(f)
+		assert.Nil(err)
+		assert.Equal(3, s.Len())
+
+		for _, want := range []string{"d", "b", "a"} {
+			v, err := s.Pop()
+			assert.Nil(err)
+			assert.Equal([]byte(want), v)
+		}
+
+		_, err = s.Pop()
+		assert.Equal(ErrQueueEmpty, err)
+		assert.Nil(s.q.HealthCheck())
+	})
+
+	t.Run("popping frees space", func(t *testing.T) {
+		s, err := NewStack(NewMemBuffer(), WithCapacity(headerLength+32))
+		assert.Nil(err)
+
+		for i := 0; i < 100; i++ {
+			assert.Nil(s.Push(nBytes(12)))
+			assert.Nil(s.Push([]byte(fmt.Sprintf("%012d", i))))
+
+			v, err := s.Pop()
+			assert.Nil(err)
+			assert.Equal([]byte(fmt.Sprintf("%012d", i)), v)
+			_, err = s.Pop()
+			assert.Nil(err)
+		}
+	})
+
+	t.Run("wrapped elements", func(t *testing.T) {
+		f := NewMemBuffer()
+		q := NewQueue(f, WithCapacity(headerLength+30))
+		assert.Nil(q.Enqueue([]byte("0000000000")))
+		assert.Nil(q.Enqueue([]byte("aaaaaaaaaa")))
+		_, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Nil(q.Enqueue([]byte("b")))
+		assert.True(q.IsWrapped())
+
+		s, err := NewStack(f)
+		assert.Nil(err)
+		for _, want := range []string{"b", "aaaaaaaaaa"} {
+			v, err := s.Pop()
+			assert.Nil(err)
+			assert.Equal([]byte(want), v)
+			assert.Nil(s.q.HealthCheck())
+		}
+	})
+
+	t.Run("evicting options are rejected", func(t *testing.T) {
+		for name, opt := range map[string]Option{
+			"overwrite oldest": WithOverwriteOldest(),
+			"full policy":      WithFullPolicy(func([]byte, *Queue) FullAction { return ActionDropOldest }),
+			"consumer offset":  WithConsumerOffset(),
+		} {
+			_, err := NewStack(NewMemBuffer(), opt)
+			assert.NotNil(err, name)
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package queue
+
+import (
+	"sync/atomic"
+)
+
+// Stats holds counters describing how a Queue has been used since it was
+// opened; counters are not persisted across reopens
+//
+// The totals count every successful operation since the queue was opened
+// and are unrelated to the number of elements currently in the queue.
+// Byte totals count payload bytes, before compression and framing.
+type Stats struct {
+	FullRejects   uint64 // enqueues rejected with ErrQueueFull
+	EmptyPolls    uint64 // dequeues rejected with ErrQueueEmpty
+	TotalEnqueued uint64 // elements enqueued
+	TotalDequeued uint64 // elements dequeued
+	TotalBytesIn  uint64 // payload bytes enqueued
+	TotalBytesOut uint64 // payload bytes dequeued
+	Seeks         uint64 // Seek calls made to the backing store
+	Reads         uint64 // Read and ReadAt calls made to the backing store
+	Writes        uint64 // Write and WriteAt calls made to the backing store
+
+	EnqueueLatency LatencyStats // durations of Enqueue calls, with WithLatencyTracking
+	DequeueLatency LatencyStats // durations of Dequeue calls, with WithLatencyTracking
+}
+
+// counters backs Stats and is updated atomically
+type counters struct {
+	fullRejects uint64
+	emptyPolls  uint64
+	enqueued    uint64
+	dequeued    uint64
+	bytesIn     uint64
+	bytesOut    uint64
+	seeks       uint64
+	reads       uint64
+	writes      uint64
+}
+
+// Stats returns a snapshot of the queue's counters
+func (ls *Queue) Stats() Stats {
+	s := Stats{
+		FullRejects:   atomic.LoadUint64(&ls.stats.fullRejects),
+		EmptyPolls:    atomic.LoadUint64(&ls.stats.emptyPolls),
+		TotalEnqueued: atomic.LoadUint64(&ls.stats.enqueued),
+		TotalDequeued: atomic.LoadUint64(&ls.stats.dequeued),
+		TotalBytesIn:  atomic.LoadUint64(&ls.stats.bytesIn),
+		TotalBytesOut: atomic.LoadUint64(&ls.stats.bytesOut),
+		Seeks:         atomic.LoadUint64(&ls.stats.seeks),
+		Reads:         atomic.LoadUint64(&ls.stats.reads),
+		Writes:        atomic.LoadUint64(&ls.stats.writes),
+	}
+	if ls.latency != nil {
+		s.EnqueueLatency = ls.latency.enqueue.stats()
+		s.DequeueLatency = ls.latency.dequeue.stats()
+	}
+	return s
+}
+
+// recordEnqueue counts a successful enqueue of size payload bytes and
+// notifies the observer
+func (ls *Queue) recordEnqueue(size int) {
+	atomic.AddUint64(&ls.stats.enqueued, 1)
+	atomic.AddUint64(&ls.stats.bytesIn, uint64(size))
+	if ls.observer != nil {
+		ls.observer.OnEnqueue(size)
+	}
+	ls.checkUtilization()
+}
+
+// recordDequeue counts a successful dequeue of size payload bytes and
+// notifies the observer
+func (ls *Queue) recordDequeue(size int) {
+	atomic.AddUint64(&ls.stats.dequeued, 1)
+	atomic.AddUint64(&ls.stats.bytesOut, uint64(size))
+	if ls.observer != nil {
+		ls.observer.OnDequeue(size)
+	}
+	ls.checkUtilization()
+}
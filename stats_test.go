@@ -0,0 +1,173 @@
+package queue
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("full rejects", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f, WithCapacity(headerLength+16))
+
+		assert.Nil(q.Enqueue(nBytes(12)))
+		for i := 0; i < 5; i++ {
+			assert.Equal(ErrQueueFull, q.Enqueue(nBytes(1)))
+		}
+		assert.Equal(uint64(5), q.Stats().FullRejects)
+
+		_, err = q.Dequeue()
+		assert.Nil(err)
+		assert.Nil(q.Enqueue(nBytes(1)))
+		assert.Equal(uint64(5), q.Stats().FullRejects)
+	})
+
+	t.Run("empty polls", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f)
+
+		for i := 0; i < 3; i++ {
+			_, err := q.Dequeue()
+			assert.Equal(ErrQueueEmpty, err)
+		}
+
+		assert.Nil(q.Enqueue([]byte("a")))
+		_, err = q.Dequeue()
+		assert.Nil(err)
+
+		// I/O counts are covered by the io counts test
+		stats := q.Stats()
+		stats.Seeks, stats.Reads, stats.Writes = 0, 0, 0
+		assert.Equal(Stats{
+			EmptyPolls:    3,
+			TotalEnqueued: 1,
+			TotalDequeued: 1,
+			TotalBytesIn:  1,
+			TotalBytesOut: 1,
+		}, stats)
+	})
+
+	t.Run("io counts", func(t *testing.T) {
+		fill := func() *Queue {
+			q := NewQueue(seekOnly{NewMemBuffer()})
+			for i := 0; i < 10; i++ {
+				assert.Nil(q.Enqueue(nBytes(8)))
+			}
+			return q
+		}
+
+		q := fill()
+		before := q.Stats()
+		for i := 0; i < 10; i++ {
+			_, err := q.Dequeue()
+			assert.Nil(err)
+		}
+		single := q.Stats()
+
+		q = fill()
+		batchBefore := q.Stats()
+		elements, err := q.DequeueUntil(10, 1000)
+		assert.Nil(err)
+		assert.Len(elements, 10)
+		batch := q.Stats()
+
+		// a batch syncs the header once and reads the elements in one pass
+		singleSeeks := single.Seeks - before.Seeks
+		batchSeeks := batch.Seeks - batchBefore.Seeks
+		assert.Less(batchSeeks, singleSeeks)
+		assert.Less(batch.Writes-batchBefore.Writes, single.Writes-before.Writes)
+		assert.NotZero(batch.Reads - batchBefore.Reads)
+
+		// positioned I/O needs no seeks
+		q = NewQueue(NewMemBuffer())
+		assert.Nil(q.Enqueue([]byte("a")))
+		_, err = q.Dequeue()
+		assert.Nil(err)
+		stats := q.Stats()
+		assert.Zero(stats.Seeks)
+		assert.NotZero(stats.Reads)
+		assert.NotZero(stats.Writes)
+	})
+
+	t.Run("totals", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithCapacity(headerLength+32))
+
+		// totals keep growing as the queue is filled and emptied repeatedly
+		for round := 0; round < 10; round++ {
+			for i := 1; i <= 3; i++ {
+				assert.Nil(q.Enqueue(nBytes(i)))
+			}
+			for i := 0; i < 3; i++ {
+				_, err := q.Dequeue()
+				assert.Nil(err)
+			}
+		}
+
+		assert.Nil(q.Transaction(func(tx *Tx) error {
+			tx.Enqueue(nBytes(4))
+			return nil
+		}))
+
+		// failed operations are not counted
+		assert.Equal(ErrQueueFull, q.Enqueue(nBytes(24)))
+
+		assert.Equal(1, q.Len())
+		stats := q.Stats()
+		assert.Equal(uint64(31), stats.TotalEnqueued)
+		assert.Equal(uint64(30), stats.TotalDequeued)
+		assert.Equal(uint64(10*6+4), stats.TotalBytesIn)
+		assert.Equal(uint64(10*6), stats.TotalBytesOut)
+	})
+}
+
+func TestLatencyTracking(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("counts samples", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithLatencyTracking())
+		for i := 0; i < 10; i++ {
+			assert.Nil(q.Enqueue(nBytes(8)))
+		}
+		for i := 0; i < 4; i++ {
+			_, err := q.Dequeue()
+			assert.Nil(err)
+		}
+
+		s := q.Stats()
+		assert.Equal(uint64(10), s.EnqueueLatency.Count)
+		assert.Equal(uint64(4), s.DequeueLatency.Count)
+		assert.Greater(int64(s.EnqueueLatency.P50), int64(0))
+		assert.LessOrEqual(int64(s.EnqueueLatency.P50), int64(s.EnqueueLatency.P99))
+	})
+
+	t.Run("off by default", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+		assert.Nil(q.Enqueue(nBytes(8)))
+		assert.Equal(LatencyStats{}, q.Stats().EnqueueLatency)
+	})
+
+	t.Run("percentiles", func(t *testing.T) {
+		var h histogram
+		for i := 0; i < 99; i++ {
+			h.buckets[4]++ // under 16ns
+		}
+		h.buckets[20]++ // under about 1ms
+
+		s := h.stats()
+		assert.Equal(uint64(100), s.Count)
+		assert.Equal(16*time.Nanosecond, s.P50)
+		assert.Equal(16*time.Nanosecond, s.P99)
+
+		h.buckets[20]++
+		assert.Equal(time.Duration(1<<20), h.stats().P99)
+	})
+}
@@ -0,0 +1,103 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// EnqueueReader adds an element holding the next size bytes of r to the
+// queue, copying them straight into the backing store instead of
+// buffering the whole payload in memory
+//
+// EnqueueReader requires the default length prefix framing and does not
+// compress, spill, or mirror the element. If r holds fewer than size bytes
+// an error wrapping io.ErrUnexpectedEOF is returned and the queue is left
+// unchanged.
+func (ls *Queue) EnqueueReader(r io.Reader, size uint32) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if ls.readOnly {
+		return ErrReadOnly
+	}
+
+	framer, ok := ls.framer.(lengthPrefixFramer)
+	if !ok {
+		return errors.New("streaming enqueues require the default framing")
+	}
+	if ls.tee != nil {
+		return errors.New("streaming enqueues cannot be mirrored to a tee")
+	}
+
+	// the prefix, along with the sequence number, flag byte, and empty
+	// tag block if elements carry them, precedes the body
+	var head []byte
+	if ls.flagged() {
+		head = []byte{elementRaw}
+	}
+	if ls.tagged() {
+		head = appendTags(head, nil, nil)
+	}
+	head = ls.number(head)
+	prefix := framer.Frame(head)
+	framer.byteOrder().PutUint32(prefix[:4], uint32(len(head))+size)
+
+	frameLength := uint32(len(prefix)) + size
+	_, prev, err := ls.appendFunc(ls.align(frameLength), ls.overwriteOldest, func(offset uint32) error {
+		w, err := ls.writerAt(int64(offset))
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.Write(prefix); err != nil {
+			return ioError(OpElementWrite, int64(offset), err)
+		}
+
+		src := &errorReader{r: r}
+		if _, err := io.CopyN(w, src, int64(size)); err != nil {
+			if src.err == io.EOF {
+				return fmt.Errorf("read element body: %w", io.ErrUnexpectedEOF)
+			}
+			if src.err != nil {
+				return fmt.Errorf("read element body: %w", src.err)
+			}
+			return ioError(OpElementWrite, int64(offset), err)
+		}
+
+		if padding := ls.align(frameLength) - frameLength; padding > 0 {
+			if _, err := w.Write(make([]byte, padding)); err != nil {
+				return ioError(OpElementWrite, int64(offset), err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := ls.syncHeader(); err != nil {
+		ls.header = prev
+		return err
+	}
+	ls.cond.Broadcast()
+
+	ls.recordEnqueue(int(size))
+
+	return nil
+}
+
+// errorReader records the error returned by the reader it wraps
+type errorReader struct {
+	r   io.Reader
+	err error
+}
+
+func (r *errorReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	if err != nil {
+		r.err = err
+	}
+	return n, err
+}
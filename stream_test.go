@@ -0,0 +1,88 @@
+package queue
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnqueueReader(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("streamed element round trips", func(t *testing.T) {
+		for name, opts := range map[string][]Option{
+			"default":     nil,
+			"seek":        nil,
+			"compression": {WithCompression(flateCodecOrPanic())},
+			"alignment":   {WithAlignment(8)},
+		} {
+			t.Run(name, func(t *testing.T) {
+				f, err := ioutil.TempFile("", "test-*")
+				assert.Nil(err)
+
+				var rws io.ReadWriteSeeker = f
+				if name == "seek" {
+					rws = seekOnly{f}
+				}
+
+				q := NewQueue(rws, opts...)
+				assert.Nil(q.Enqueue([]byte("before")))
+
+				payload := strings.Repeat("streamed payload ", 20)
+				assert.Nil(q.EnqueueReader(strings.NewReader(payload), uint32(len(payload))))
+				assert.Nil(q.Enqueue([]byte("after")))
+
+				for _, want := range []string{"before", payload, "after"} {
+					front, err := q.Dequeue()
+					assert.Nil(err)
+					assert.Equal([]byte(want), front)
+				}
+			})
+		}
+	})
+
+	t.Run("short reader leaves the queue unchanged", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+		assert.Nil(q.Enqueue([]byte("a")))
+		header := q.header
+
+		err := q.EnqueueReader(strings.NewReader("short"), 10)
+		assert.True(errors.Is(err, io.ErrUnexpectedEOF))
+		assert.Equal(header, q.header)
+
+		assert.Nil(q.Enqueue([]byte("b")))
+		elements, err := q.Elements()
+		assert.Nil(err)
+		assert.Equal([][]byte{[]byte("a"), []byte("b")}, elements)
+	})
+
+	t.Run("only size bytes are consumed", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+
+		r := strings.NewReader("helloworld")
+		assert.Nil(q.EnqueueReader(r, 5))
+		assert.Equal(5, r.Len())
+
+		front, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Equal([]byte("hello"), front)
+	})
+
+	t.Run("full queue", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithCapacity(headerLength+16))
+		assert.Nil(q.Enqueue(nBytes(8)))
+		assert.Equal(ErrQueueFull, q.EnqueueReader(strings.NewReader("12345678"), 8))
+	})
+}
+
+func flateCodecOrPanic() CompressionCodec {
+	codec, err := NewFlateCodec(1)
+	if err != nil {
+		panic(err)
+	}
+	return codec
+}
@@ -0,0 +1,106 @@
+package queue
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrSubscribed = errors.New("queue already has an active subscription")
+	ErrClosed     = errors.New("queue is closed")
+)
+
+// Subscribe returns a channel on which elements are delivered in FIFO
+// order as they become available
+//
+// Each element is removed from the queue only once it has been received
+// from the channel, so cancelling ctx never loses an element. The channel
+// is closed when ctx is done, when the queue is closed, or when an element
+// cannot be read. Only one subscription may be active at a time; Subscribe
+// returns ErrSubscribed while another channel is open.
+//
+// The subscription is meant to be the queue's only consumer. An element
+// received from the channel may also be returned by a concurrent Dequeue.
+func (ls *Queue) Subscribe(ctx context.Context) (<-chan []byte, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if ls.readOnly {
+		return nil, ErrReadOnly
+	}
+	if ls.isClosed() {
+		return nil, ErrClosed
+	}
+	if ls.subscribed {
+		return nil, ErrSubscribed
+	}
+	ls.subscribed = true
+
+	ch := make(chan []byte)
+	go ls.publish(ctx, ch)
+	return ch, nil
+}
+
+// publish delivers elements on ch until ctx is done, the queue is closed,
+// or an element cannot be read
+func (ls *Queue) publish(ctx context.Context, ch chan<- []byte) {
+	defer func() {
+		ls.mu.Lock()
+		ls.subscribed = false
+		ls.mu.Unlock()
+		close(ch)
+	}()
+
+	for {
+		if err := ls.pace(ctx); err != nil {
+			return
+		}
+
+		b, err := ls.next(ctx)
+		if err != nil {
+			return
+		}
+
+		select {
+		case ch <- b.Elements()[0]:
+			// a concurrent dequeue already removed the element
+			if err := b.Commit(); err != nil && err != ErrStaleBatch {
+				return
+			}
+		case <-ctx.Done():
+			b.Abort()
+			return
+		case <-ls.closed:
+			b.Abort()
+			return
+		}
+	}
+}
+
+// next waits until the queue is not empty and dequeuing is not paused,
+// and returns a batch holding the head element
+func (ls *Queue) next(ctx context.Context) (*Batch, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	for ls.header.queueSize == 0 || ls.paused {
+		if ls.isClosed() {
+			return nil, ErrClosed
+		}
+		if err := ls.wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return ls.peekBatch(1, nil)
+}
+
+// isClosed reports whether Close has been called
+func (ls *Queue) isClosed() bool {
+	select {
+	case <-ls.closed:
+		return true
+	default:
+		return false
+	}
+}
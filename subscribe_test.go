@@ -0,0 +1,98 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribe(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("receives concurrent enqueues", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithCapacity(headerLength+256))
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		ch, err := q.Subscribe(ctx)
+		assert.Nil(err)
+
+		_, err = q.Subscribe(ctx)
+		assert.Equal(ErrSubscribed, err)
+
+		const producers, perProducer = 4, 50
+		var wg sync.WaitGroup
+		for p := 0; p < producers; p++ {
+			wg.Add(1)
+			go func(p int) {
+				defer wg.Done()
+				for i := 0; i < perProducer; i++ {
+					assert.Nil(q.EnqueueContext(ctx, []byte(fmt.Sprintf("%d-%d", p, i))))
+				}
+			}(p)
+		}
+
+		received := map[string]bool{}
+		next := make([]int, producers)
+		for len(received) < producers*perProducer {
+			v := <-ch
+			received[string(v)] = true
+
+			// elements from each producer arrive in order
+			var p, i int
+			_, err := fmt.Sscanf(string(v), "%d-%d", &p, &i)
+			assert.Nil(err)
+			assert.Equal(next[p], i)
+			next[p]++
+		}
+		wg.Wait()
+
+		// the last element is removed just after it is received
+		assert.Nil(q.WaitUntilBelow(ctx, 1))
+
+		cancel()
+		_, ok := <-ch
+		assert.False(ok)
+
+		// the subscription ends with the channel
+		for {
+			if ch, err = q.Subscribe(context.Background()); err != ErrSubscribed {
+				break
+			}
+		}
+		assert.Nil(err)
+		assert.Nil(q.Close())
+		_, ok = <-ch
+		assert.False(ok)
+	})
+
+	t.Run("cancel keeps undelivered elements", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+		assert.Nil(q.Enqueue([]byte("a")))
+		assert.Nil(q.Enqueue([]byte("b")))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		ch, err := q.Subscribe(ctx)
+		assert.Nil(err)
+		assert.Equal([]byte("a"), <-ch)
+
+		cancel()
+		for range ch {
+		}
+
+		elements, err := q.Elements()
+		assert.Nil(err)
+		assert.Equal([][]byte{[]byte("b")}, elements)
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+		assert.Nil(q.Close())
+
+		_, err := q.Subscribe(context.Background())
+		assert.Equal(ErrClosed, err)
+	})
+}
@@ -0,0 +1,150 @@
+package queue
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"time"
+)
+
+var ErrNotTagged = errors.New("queue does not store element tags")
+
+// WithTags makes a newly created queue store a block of key-value tags,
+// such as a content type or trace id, alongside each element payload,
+// which EnqueueWithTags writes and DequeueWithTags returns
+//
+// The tag block starts with its length followed by each key and value
+// prefixed with its length, so an element without tags grows by a single
+// byte. Other methods return the payload without its tags. Whether a
+// queue is tagged is recorded in the file, so the option has no effect
+// when reopening an existing queue, but it must be passed to Repair to
+// recover a tagged queue. Tags cannot be combined with fixed size
+// elements.
+func WithTags() Option {
+	return func(ls *Queue) {
+		ls.tags = true
+	}
+}
+
+// EnqueueWithTags adds v to the queue like Enqueue, storing tags with it,
+// or returns ErrNotTagged if the queue was not created with WithTags
+func (ls *Queue) EnqueueWithTags(v []byte, tags map[string]string) error {
+	if ls.latency != nil {
+		defer ls.latency.enqueue.since(time.Now())
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if !ls.tagged() {
+		return ErrNotTagged
+	}
+
+	_, err := ls.enqueueWithPolicy(v, tags)
+	return err
+}
+
+// DequeueWithTags removes and returns the item at the front of the queue
+// along with its tags, or ErrNotTagged if the queue was not created with
+// WithTags
+//
+// The tags are nil for an element enqueued without tags.
+func (ls *Queue) DequeueWithTags() ([]byte, map[string]string, error) {
+	if err := ls.pace(context.Background()); err != nil {
+		return nil, nil, err
+	}
+
+	if ls.latency != nil {
+		defer ls.latency.dequeue.since(time.Now())
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if !ls.tagged() {
+		return nil, nil, ErrNotTagged
+	}
+
+	v, _, tags, _, err := ls.dequeueNumbered(nil)
+	return v, tags, err
+}
+
+// tagged reports whether element payloads begin with a tag block
+func (ls *Queue) tagged() bool {
+	return ls.header.flags&headerTagged != 0
+}
+
+// appendTags appends the tag block holding tags, in key order, followed
+// by v to dst
+func appendTags(dst []byte, tags map[string]string, v []byte) []byte {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var block []byte
+	for _, k := range keys {
+		block = appendUvarintString(block, k)
+		block = appendUvarintString(block, tags[k])
+	}
+
+	dst = appendUvarint(dst, uint64(len(block)))
+	dst = append(dst, block...)
+	return append(dst, v...)
+}
+
+// splitTags splits the tag block at the start of b from the payload
+// following it
+func splitTags(b []byte) ([]byte, map[string]string, error) {
+	length, n := binary.Uvarint(b)
+	if n <= 0 || length > uint64(len(b)-n) {
+		return nil, nil, errors.New("element tag block is truncated")
+	}
+	block, v := b[n:n+int(length)], b[n+int(length):]
+
+	var tags map[string]string
+	for len(block) > 0 {
+		k, rest, ok := readUvarintString(block)
+		if !ok {
+			return nil, nil, errors.New("element tag block is corrupt")
+		}
+		val, rest, ok := readUvarintString(rest)
+		if !ok {
+			return nil, nil, errors.New("element tag block is corrupt")
+		}
+
+		if tags == nil {
+			tags = make(map[string]string)
+		}
+		tags[k] = val
+		block = rest
+	}
+
+	return v, tags, nil
+}
+
+// appendUvarint appends x as a uvarint to dst
+func appendUvarint(dst []byte, x uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], x)
+	return append(dst, buf[:n]...)
+}
+
+// appendUvarintString appends s prefixed with its uvarint length to dst
+func appendUvarintString(dst []byte, s string) []byte {
+	dst = appendUvarint(dst, uint64(len(s)))
+	return append(dst, s...)
+}
+
+// readUvarintString reads a string prefixed with its uvarint length from
+// the start of b and returns it along with the rest of b
+func readUvarintString(b []byte) (string, []byte, bool) {
+	length, n := binary.Uvarint(b)
+	if n <= 0 || length > uint64(len(b)-n) {
+		return "", nil, false
+	}
+	end := n + int(length)
+	return string(b[n:end]), b[end:], true
+}
@@ -0,0 +1,72 @@
+package queue
+
+import (
+	"bytes"
+	"compress/flate"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTags(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("round trip", func(t *testing.T) {
+		f := NewMemBuffer()
+		q := NewQueue(f, WithTags())
+
+		tags := map[string]string{"content-type": "application/json", "trace-id": "abc123"}
+		assert.Nil(q.EnqueueWithTags([]byte(`{"a":1}`), tags))
+		assert.Nil(q.Enqueue([]byte("untagged")))
+		assert.Nil(q.EnqueueWithTags([]byte("empty value"), map[string]string{"": ""}))
+
+		// other methods see only the payload
+		front, err := q.Peek()
+		assert.Nil(err)
+		assert.Equal([]byte(`{"a":1}`), front)
+
+		// tags survive reopening
+		q = NewQueue(f)
+		v, got, err := q.DequeueWithTags()
+		assert.Nil(err)
+		assert.Equal([]byte(`{"a":1}`), v)
+		assert.Equal(tags, got)
+
+		v, got, err = q.DequeueWithTags()
+		assert.Nil(err)
+		assert.Equal([]byte("untagged"), v)
+		assert.Nil(got)
+
+		v, err = q.Dequeue()
+		assert.Nil(err)
+		assert.Equal([]byte("empty value"), v)
+	})
+
+	t.Run("with compression and sequence numbers", func(t *testing.T) {
+		codec, err := NewFlateCodec(flate.BestCompression)
+		assert.Nil(err)
+		q := NewQueue(NewMemBuffer(), WithTags(), WithCompression(codec), WithSequenceNumbers())
+
+		payload := bytes.Repeat([]byte("compressible "), 10)
+		tags := map[string]string{"k": "v"}
+		assert.Nil(q.EnqueueWithTags(payload, tags))
+
+		v, got, err := q.DequeueWithTags()
+		assert.Nil(err)
+		assert.Equal(payload, v)
+		assert.Equal(tags, got)
+	})
+
+	t.Run("not tagged", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+		assert.Equal(ErrNotTagged, q.EnqueueWithTags([]byte("a"), nil))
+
+		_, _, err := q.DequeueWithTags()
+		assert.Equal(ErrNotTagged, err)
+	})
+
+	t.Run("fixed size elements", func(t *testing.T) {
+		_, err := New(NewMemBuffer(), WithTags(), WithFixedElementSize(8))
+		assert.NotNil(err)
+	})
+}
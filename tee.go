@@ -0,0 +1,101 @@
+package queue
+
+import (
+	"log"
+)
+
+// TeePolicy decides how an enqueue handles a failure to mirror its element
+// to a secondary queue
+type TeePolicy int
+
+const (
+	// TeeFailFast fails the enqueue without adding the element to the
+	// primary queue, so that both queues receive the same sequence of
+	// elements
+	TeeFailFast TeePolicy = iota
+
+	// TeeBestEffort logs the failure and enqueues to the primary queue
+	// regardless
+	TeeBestEffort
+)
+
+// WithTee mirrors every element enqueued with Enqueue, EnqueueAt,
+// EnqueueContext, EnqueueWithTags, or Transaction to secondary, handling
+// failures according to policy
+//
+// Elements are mirrored once the primary queue has committed them, and
+// with TeeFailFast are removed from the primary queue again if mirroring
+// fails; a crash in between leaves them only in the primary queue. The
+// elements of a transaction are mirrored in a single transaction on
+// secondary, so it receives all of them or none. Dequeues only ever
+// consume from the primary queue. Tags are mirrored along with their
+// elements, so secondary must be tagged to receive elements enqueued with
+// tags. The secondary queue is locked while the primary queue is locked,
+// so secondary must never tee back to the primary.
+func WithTee(secondary *Queue, policy TeePolicy) Option {
+	return func(ls *Queue) {
+		ls.tee = secondary
+		ls.teePolicy = policy
+	}
+}
+
+// commitMirrored syncs the header to commit the elements added since prev
+// and only then mirrors them with mirror, so that the tee queue never
+// receives elements the primary queue failed to commit
+//
+// If mirroring fails the elements are removed again by syncing prev.
+func (ls *Queue) commitMirrored(prev fileHeader, mirror func() error) error {
+	if err := ls.syncHeader(); err != nil {
+		ls.header = prev
+		return err
+	}
+
+	if err := mirror(); err != nil {
+		ls.header = prev
+		if syncErr := ls.syncHeader(); syncErr != nil {
+			return syncErr
+		}
+		return err
+	}
+
+	ls.cond.Broadcast()
+	return nil
+}
+
+// mirror enqueues v with its tags to the tee queue, if any, returning an
+// error only if the enqueue failed and the policy is TeeFailFast
+//
+// Tags are only dropped for an element without any, so mirroring a tagged
+// element to a queue that is not tagged fails with ErrNotTagged.
+func (ls *Queue) mirror(v []byte, tags map[string]string) error {
+	if ls.tee == nil {
+		return nil
+	}
+
+	if len(tags) == 0 {
+		return ls.teeResult(ls.tee.Enqueue(v))
+	}
+	return ls.teeResult(ls.tee.EnqueueWithTags(v, tags))
+}
+
+// mirrorAll is like mirror, but enqueues vs in a single transaction
+func (ls *Queue) mirrorAll(vs [][]byte) error {
+	if ls.tee == nil {
+		return nil
+	}
+
+	return ls.teeResult(ls.tee.Transaction(func(tx *Tx) error {
+		tx.values = vs
+		return nil
+	}))
+}
+
+// teeResult returns err, the result of mirroring to the tee queue, unless
+// the policy is TeeBestEffort, in which case a failure is only logged
+func (ls *Queue) teeResult(err error) error {
+	if err != nil && ls.teePolicy == TeeBestEffort {
+		log.Printf("fq: failed to mirror element to secondary queue: %v", err)
+		return nil
+	}
+	return err
+}
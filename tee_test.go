@@ -0,0 +1,127 @@
+package queue
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTee(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("fail fast", func(t *testing.T) {
+		secondary := NewQueue(NewMemBuffer(), WithCapacity(headerLength+16))
+		primary := NewQueue(NewMemBuffer(), WithTee(secondary, TeeFailFast))
+
+		assert.Nil(primary.Enqueue([]byte("a")))
+		assert.Nil(primary.Enqueue([]byte("bb")))
+
+		// the secondary queue is full, so neither queue takes the element
+		assert.Equal(ErrQueueFull, primary.Enqueue(nBytes(8)))
+
+		_, err := secondary.Dequeue()
+		assert.Nil(err)
+		assert.Nil(primary.Enqueue([]byte("c")))
+
+		primaryElements, err := primary.Elements()
+		assert.Nil(err)
+		assert.Equal([][]byte{[]byte("a"), []byte("bb"), []byte("c")}, primaryElements)
+
+		secondaryElements, err := secondary.Elements()
+		assert.Nil(err)
+		assert.Equal(primaryElements[1:], secondaryElements)
+		assert.Nil(primary.HealthCheck())
+
+		// dequeues only consume from the primary queue
+		_, err = primary.Dequeue()
+		assert.Nil(err)
+		assert.Equal(2, primary.Len())
+		assert.Equal(2, secondary.Len())
+	})
+
+	t.Run("best effort", func(t *testing.T) {
+		var logs bytes.Buffer
+		log.SetOutput(&logs)
+		defer log.SetOutput(os.Stderr)
+
+		secondary := NewQueue(NewMemBuffer(), WithCapacity(headerLength+16))
+		primary := NewQueue(NewMemBuffer(), WithTee(secondary, TeeBestEffort))
+
+		assert.Nil(primary.Enqueue([]byte("a")))
+		assert.Nil(primary.Enqueue(nBytes(8)))
+		assert.Contains(logs.String(), ErrQueueFull.Error())
+
+		assert.Equal(2, primary.Len())
+		assert.Equal(1, secondary.Len())
+	})
+
+	t.Run("transaction", func(t *testing.T) {
+		secondary := NewQueue(NewMemBuffer(), WithCapacity(headerLength+16))
+		primary := NewQueue(NewMemBuffer(), WithTee(secondary, TeeFailFast))
+
+		assert.Nil(primary.Transaction(func(tx *Tx) error {
+			tx.Enqueue([]byte("a"))
+			tx.Enqueue([]byte("bb"))
+			return nil
+		}))
+
+		// the secondary queue has no room for both elements, so neither
+		// queue takes either of them
+		assert.Equal(ErrQueueFull, primary.Transaction(func(tx *Tx) error {
+			tx.Enqueue([]byte("c"))
+			tx.Enqueue(nBytes(8))
+			return nil
+		}))
+
+		primaryElements, err := primary.Elements()
+		assert.Nil(err)
+		assert.Equal([][]byte{[]byte("a"), []byte("bb")}, primaryElements)
+
+		secondaryElements, err := secondary.Elements()
+		assert.Nil(err)
+		assert.Equal(primaryElements, secondaryElements)
+		assert.Nil(primary.HealthCheck())
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		secondary := NewQueue(NewMemBuffer(), WithTags())
+		primary := NewQueue(NewMemBuffer(), WithTags(), WithTee(secondary, TeeFailFast))
+
+		tags := map[string]string{"type": "order"}
+		assert.Nil(primary.EnqueueWithTags([]byte("a"), tags))
+		assert.Nil(primary.Enqueue([]byte("b")))
+
+		v, got, err := secondary.DequeueWithTags()
+		assert.Nil(err)
+		assert.Equal([]byte("a"), v)
+		assert.Equal(tags, got)
+
+		v, got, err = secondary.DequeueWithTags()
+		assert.Nil(err)
+		assert.Equal([]byte("b"), v)
+		assert.Empty(got)
+
+		// a secondary queue without tags cannot take a tagged element
+		untagged := NewQueue(NewMemBuffer())
+		primary = NewQueue(NewMemBuffer(), WithTags(), WithTee(untagged, TeeFailFast))
+		assert.Equal(ErrNotTagged, primary.EnqueueWithTags([]byte("a"), tags))
+		assert.Equal(0, primary.Len())
+		assert.Equal(0, untagged.Len())
+	})
+
+	t.Run("failed commit is not mirrored", func(t *testing.T) {
+		secondary := NewQueue(NewMemBuffer())
+		rws := newFlakyReadWriteSeeker(NewMemBuffer())
+		primary := NewQueue(rws, WithTee(secondary, TeeFailFast))
+
+		// the element is written, but the header committing it is torn
+		rws.tornWriteLength = 10
+		assert.NotNil(primary.Enqueue([]byte("a")))
+
+		assert.Equal(0, primary.Len())
+		assert.Equal(0, secondary.Len())
+	})
+}
@@ -0,0 +1,74 @@
+package queue
+
+import (
+	"errors"
+	"time"
+)
+
+// timestampLength is the length of the enqueue time stored after the
+// sequence number of each element body in a timestamped queue
+const timestampLength = 8
+
+var ErrNotTimestamped = errors.New("queue does not store timestamps")
+
+// WithTimestamps makes a newly created queue record the time each element
+// was enqueued, so that OldestAge can report how far behind consumers are
+//
+// Each element grows by 8 bytes. Whether a queue is timestamped is
+// recorded in the file, so the option has no effect when reopening an
+// existing queue, but it must be passed to Repair to recover a timestamped
+// queue.
+func WithTimestamps() Option {
+	return func(ls *Queue) {
+		ls.timestamps = true
+	}
+}
+
+// OldestAge returns how long ago the head element was enqueued, or
+// ErrNotTimestamped if the queue was not created with WithTimestamps
+func (ls *Queue) OldestAge() (time.Duration, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if !ls.timestamped() {
+		return 0, ErrNotTimestamped
+	}
+	if ls.header.queueSize == 0 {
+		return 0, ErrQueueEmpty
+	}
+
+	body, _, err := ls.readElement(ls.header.headPosition, ls.header.fileLength)
+	if err != nil {
+		return 0, err
+	}
+
+	stamp, err := ls.stamp(body)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Since(time.Unix(0, stamp)), nil
+}
+
+// timestamped reports whether element bodies carry their enqueue time
+func (ls *Queue) timestamped() bool {
+	return ls.header.flags&headerTimestamped != 0
+}
+
+// stamp returns the enqueue time, in nanoseconds since the Unix epoch,
+// recorded in body, or 0 if the queue is not timestamped
+func (ls *Queue) stamp(body []byte) (int64, error) {
+	if !ls.timestamped() {
+		return 0, nil
+	}
+
+	var offset uint32
+	if ls.sequenced() {
+		offset = sequenceLength
+	}
+	if uint32(len(body)) < offset+timestampLength {
+		return 0, errors.New("element is missing its timestamp")
+	}
+
+	return int64(ls.header.order().Uint64(body[offset:])), nil
+}
@@ -0,0 +1,67 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOldestAge(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("age of the head element", func(t *testing.T) {
+		f := NewMemBuffer()
+		q := NewQueue(f, WithTimestamps())
+
+		_, err := q.OldestAge()
+		assert.Equal(ErrQueueEmpty, err)
+
+		assert.Nil(q.Enqueue([]byte("old")))
+		time.Sleep(50 * time.Millisecond)
+		assert.Nil(q.Enqueue([]byte("new")))
+
+		age, err := q.OldestAge()
+		assert.Nil(err)
+		assert.GreaterOrEqual(int64(age), int64(50*time.Millisecond))
+		assert.Less(int64(age), int64(time.Second))
+
+		// timestamps survive reopening and do not change the payloads
+		q = NewQueue(f)
+		front, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Equal([]byte("old"), front)
+
+		age, err = q.OldestAge()
+		assert.Nil(err)
+		assert.Less(int64(age), int64(50*time.Millisecond))
+	})
+
+	t.Run("with sequence numbers", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithTimestamps(), WithSequenceNumbers())
+		assert.Nil(q.Enqueue([]byte("a")))
+		assert.Nil(q.Enqueue([]byte("b")))
+
+		age, err := q.OldestAge()
+		assert.Nil(err)
+		assert.Less(int64(age), int64(time.Second))
+
+		v, seq, err := q.DequeueWithSeq()
+		assert.Nil(err)
+		assert.Equal([]byte("a"), v)
+		assert.Equal(uint64(1), seq)
+
+		buf := make([]byte, 8)
+		n, err := q.DequeueInto(buf)
+		assert.Nil(err)
+		assert.Equal([]byte("b"), buf[:n])
+	})
+
+	t.Run("not timestamped", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+		assert.Nil(q.Enqueue([]byte("a")))
+
+		_, err := q.OldestAge()
+		assert.Equal(ErrNotTimestamped, err)
+	})
+}
@@ -0,0 +1,46 @@
+package queue
+
+// tracked is an element awaiting removal from the head of the queue
+type tracked struct {
+	index uint64        // number of elements removed before it reaches the head
+	done  chan struct{} // closed once the element is removed
+}
+
+// EnqueueTracked adds a value to the queue like Enqueue and returns a
+// channel that is closed once the element has been removed from the
+// queue, whether by a dequeue or by eviction
+//
+// Elements are matched by their position in the sequence of all elements
+// ever enqueued, which is persisted in the file header, so tracking works
+// whether or not the queue stores sequence numbers. Tracking is held in
+// memory only: the channel of an element still queued when the queue is
+// closed is never closed.
+func (ls *Queue) EnqueueTracked(v []byte) (<-chan struct{}, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if _, err := ls.enqueueWithPolicy(v, nil); err != nil {
+		return nil, err
+	}
+
+	t := tracked{
+		index: ls.header.removed + uint64(ls.header.queueSize) - 1,
+		done:  make(chan struct{}),
+	}
+	ls.tracked = append(ls.tracked, t)
+
+	return t.done, nil
+}
+
+// resolveTracked closes the channels of tracked elements that have been
+// removed according to the cached header
+func (ls *Queue) resolveTracked() {
+	n := 0
+	for n < len(ls.tracked) && ls.tracked[n].index < ls.header.removed {
+		close(ls.tracked[n].done)
+		n++
+	}
+	if n > 0 {
+		ls.tracked = ls.tracked[n:]
+	}
+}
@@ -0,0 +1,64 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnqueueTracked(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("closes once dequeued", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+
+		assert.Nil(q.Enqueue([]byte("a")))
+		done, err := q.EnqueueTracked([]byte("b"))
+		assert.Nil(err)
+
+		dequeued := make(chan []byte, 2)
+		go func() {
+			for i := 0; i < 2; i++ {
+				v, err := q.Dequeue()
+				assert.Nil(err)
+				dequeued <- v
+			}
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("tracking channel was not closed")
+		}
+
+		// the channel closes only after the tracked element is dequeued
+		assert.Equal([]byte("a"), <-dequeued)
+		assert.Equal([]byte("b"), <-dequeued)
+		assert.Equal(0, q.Len())
+	})
+
+	t.Run("stays open while queued", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+
+		first, err := q.EnqueueTracked([]byte("a"))
+		assert.Nil(err)
+		second, err := q.EnqueueTracked([]byte("b"))
+		assert.Nil(err)
+
+		_, err = q.Dequeue()
+		assert.Nil(err)
+
+		select {
+		case <-first:
+		default:
+			t.Fatal("first tracking channel was not closed")
+		}
+
+		select {
+		case <-second:
+			t.Fatal("second tracking channel closed before its element was dequeued")
+		default:
+		}
+	})
+}
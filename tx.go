@@ -0,0 +1,78 @@
+package queue
+
+// Tx buffers enqueues made within a Queue.Transaction
+type Tx struct {
+	values [][]byte
+}
+
+// Enqueue buffers v to be added to the queue when the transaction commits
+func (tx *Tx) Enqueue(v []byte) {
+	tx.values = append(tx.values, append([]byte(nil), v...))
+}
+
+// Transaction calls fn to buffer a group of enqueues and then adds them to
+// the queue atomically: either every element appears or none do, even if
+// the process crashes part way through
+//
+// If fn returns an error the buffered elements are discarded and the error
+// is returned. Otherwise the elements are written past the tail and made
+// visible by a single header update, so a crash before that update leaves
+// the queue unchanged. The commit fails with ErrQueueFull, adding nothing,
+// if the elements do not all fit; elements are never evicted to make room.
+//
+// fn is called without the queue locked, so it may use the queue, but the
+// buffered elements are not visible until fn returns.
+func (ls *Queue) Transaction(fn func(tx *Tx) error) error {
+	tx := &Tx{}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	return ls.commit(tx)
+}
+
+// commit writes the elements buffered in tx and syncs the header once
+func (ls *Queue) commit(tx *Tx) error {
+	if ls.readOnly {
+		return ErrReadOnly
+	}
+
+	if len(tx.values) == 0 {
+		return nil
+	}
+
+	original := ls.header
+	var bodies [][]byte
+	rollback := func(err error) error {
+		ls.header = original
+		for _, body := range bodies {
+			ls.removeSpilled(body)
+		}
+		return err
+	}
+
+	for _, v := range tx.values {
+		frame, body, err := ls.frameElement(v, nil)
+		if err != nil {
+			return rollback(err)
+		}
+		bodies = append(bodies, body)
+
+		if _, _, err := ls.append(frame, false); err != nil {
+			return rollback(err)
+		}
+	}
+
+	if err := ls.commitMirrored(original, func() error { return ls.mirrorAll(tx.values) }); err != nil {
+		return rollback(err)
+	}
+
+	for _, v := range tx.values {
+		ls.recordEnqueue(len(v))
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package queue
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransaction(t *testing.T) {
+	assert := assert.New(t)
+
+	values := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+
+	t.Run("commit", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+		assert.Nil(q.Enqueue([]byte("first")))
+
+		err := q.Transaction(func(tx *Tx) error {
+			for _, v := range values {
+				tx.Enqueue(v)
+			}
+			return nil
+		})
+		assert.Nil(err)
+
+		elements, err := q.Elements()
+		assert.Nil(err)
+		assert.Equal(append([][]byte{[]byte("first")}, values...), elements)
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+
+		failure := errors.New("abort")
+		err := q.Transaction(func(tx *Tx) error {
+			tx.Enqueue([]byte("a"))
+			return failure
+		})
+		assert.Equal(failure, err)
+		assert.Equal(0, q.Len())
+	})
+
+	t.Run("crash before commit", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f)
+		err = q.Transaction(func(tx *Tx) error {
+			for _, v := range values {
+				tx.Enqueue(v)
+			}
+
+			// reopening simulates a crash before the transaction commits
+			reopened := NewQueue(f)
+			assert.Equal(0, reopened.Len())
+			return errors.New("crashed")
+		})
+		assert.NotNil(err)
+
+		assert.Equal(0, NewQueue(f).Len())
+	})
+
+	t.Run("crash during commit", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		rws := newFlakyReadWriteSeeker(f)
+		q := NewQueue(rws)
+		header := q.header
+
+		// the element frames are written but the header update is torn
+		rws.tearNextWrite(5)
+		err = q.Transaction(func(tx *Tx) error {
+			for _, v := range values {
+				tx.Enqueue(v)
+			}
+			return nil
+		})
+		assert.NotNil(err)
+		assert.Equal(header, q.header)
+
+		assert.Equal(0, NewQueue(f).Len())
+	})
+
+	t.Run("elements that do not all fit are not added", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithCapacity(headerLength+16))
+
+		err := q.Transaction(func(tx *Tx) error {
+			tx.Enqueue(nBytes(6))
+			tx.Enqueue(nBytes(6))
+			return nil
+		})
+		assert.Equal(ErrQueueFull, err)
+		assert.Equal(0, q.Len())
+
+		assert.Nil(q.Enqueue(nBytes(12)))
+	})
+}
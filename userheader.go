@@ -0,0 +1,58 @@
+package queue
+
+import (
+	"fmt"
+)
+
+// WithUserHeader reserves size bytes after the queue header of a newly
+// created queue for opaque application metadata, such as a schema version
+// or producer id, accessed with ReadUserHeader and WriteUserHeader
+//
+// The reservation is recorded in the file header, so reopening a queue
+// keeps its reservation regardless of this option.
+func WithUserHeader(size uint32) Option {
+	return func(ls *Queue) {
+		ls.userHeaderLength = size
+	}
+}
+
+// ReadUserHeader returns the whole reserved user metadata block, which is
+// empty if the queue was created without WithUserHeader
+func (ls *Queue) ReadUserHeader() ([]byte, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	b := make([]byte, ls.header.userHeaderLength)
+	if len(b) == 0 {
+		return b, nil
+	}
+
+	if err := ls.readAt(b, int64(headerLength)); err != nil {
+		return nil, ioError(OpHeaderRead, int64(headerLength), err)
+	}
+
+	return b, nil
+}
+
+// WriteUserHeader replaces the user metadata block with b, zero filling
+// the rest of the block if b is shorter than the reservation
+func (ls *Queue) WriteUserHeader(b []byte) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if ls.readOnly {
+		return ErrReadOnly
+	}
+
+	if uint32(len(b)) > ls.header.userHeaderLength {
+		return fmt.Errorf("user header of %d bytes does not fit in the %d bytes reserved", len(b), ls.header.userHeaderLength)
+	}
+
+	block := make([]byte, ls.header.userHeaderLength)
+	copy(block, b)
+	if _, err := ls.writeAt(block, int64(headerLength)); err != nil {
+		return ioError(OpHeaderSync, int64(headerLength), err)
+	}
+
+	return nil
+}
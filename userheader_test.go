@@ -0,0 +1,62 @@
+package queue
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserHeader(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("metadata round trips around elements", func(t *testing.T) {
+		m := NewMemBuffer()
+		q := NewQueue(m, WithCapacity(headerLength+16+32), WithUserHeader(16))
+
+		meta, err := q.ReadUserHeader()
+		assert.Nil(err)
+		assert.Equal(make([]byte, 16), meta)
+
+		assert.Nil(q.WriteUserHeader([]byte("schema=v2")))
+
+		// fill the element region, wrapping once, without touching the block
+		offset, err := q.EnqueueAt(nBytes(12))
+		assert.Nil(err)
+		assert.Equal(headerLength+16, offset)
+		assert.Nil(q.Enqueue(nBytes(12)))
+		assert.Equal(ErrQueueFull, q.Enqueue(nBytes(12)))
+		_, err = q.Dequeue()
+		assert.Nil(err)
+		assert.Nil(q.Enqueue([]byte("wrapped")))
+		assert.True(q.isWrapped())
+		assert.Nil(q.HealthCheck())
+
+		// the reservation survives reopening without the option
+		q = NewQueue(m)
+		meta, err = q.ReadUserHeader()
+		assert.Nil(err)
+		assert.Equal([]byte("schema=v2"), meta[:9])
+		assert.Equal(make([]byte, 7), meta[9:])
+
+		for i := 0; i < 2; i++ {
+			_, err := q.Dequeue()
+			assert.Nil(err)
+		}
+		assert.Nil(q.Enqueue(nBytes(28)))
+		assert.Equal(headerLength+16, q.header.headPosition)
+	})
+
+	t.Run("metadata must fit the reservation", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer(), WithUserHeader(4))
+		assert.NotNil(q.WriteUserHeader([]byte("too long")))
+	})
+
+	t.Run("no reservation", func(t *testing.T) {
+		q := NewQueue(NewMemBuffer())
+
+		meta, err := q.ReadUserHeader()
+		assert.Nil(err)
+		assert.Empty(meta)
+		assert.NotNil(q.WriteUserHeader([]byte("x")))
+	})
+}
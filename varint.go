@@ -0,0 +1,53 @@
+package queue
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// WithVarintFraming frames elements with a uvarint length prefix instead
+// of a fixed 4-byte length, saving space for small payloads
+//
+// A queue written with varint framing must be reopened with varint framing.
+func WithVarintFraming() Option {
+	return WithFramer(uvarintFramer{})
+}
+
+// uvarintFramer frames a body with a uvarint length prefix
+type uvarintFramer struct{}
+
+func (uvarintFramer) Frame(body []byte) []byte {
+	frame := make([]byte, binary.MaxVarintLen32+len(body))
+	n := binary.PutUvarint(frame, uint64(len(body)))
+	copy(frame[n:], body)
+	return frame[:n+len(body)]
+}
+
+func (uvarintFramer) Unframe(r io.Reader) ([]byte, error) {
+	length, err := binary.ReadUvarint(byteReader{r})
+	if err != nil {
+		return nil, err
+	}
+
+	if length > math.MaxUint32 {
+		return nil, errors.New("varint element length overflows uint32")
+	}
+
+	return readBody(r, uint32(length))
+}
+
+// byteReader adapts an io.Reader to an io.ByteReader
+// without reading ahead of the requested byte
+type byteReader struct {
+	r io.Reader
+}
+
+func (br byteReader) ReadByte() (byte, error) {
+	var b [1]byte
+	if _, err := io.ReadFull(br.r, b[:]); err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
@@ -0,0 +1,72 @@
+package queue
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVarintFraming(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("frame sizes straddle varint boundaries", func(t *testing.T) {
+		cases := []struct {
+			size       int
+			prefixSize int
+		}{
+			{size: 0, prefixSize: 1},
+			{size: 127, prefixSize: 1},
+			{size: 128, prefixSize: 2},
+			{size: 16383, prefixSize: 2},
+			{size: 16384, prefixSize: 3},
+		}
+
+		for _, c := range cases {
+			body := nBytes(c.size)
+			frame := uvarintFramer{}.Frame(body)
+			assert.Equal(c.prefixSize+c.size, len(frame))
+
+			got, err := uvarintFramer{}.Unframe(bytes.NewReader(frame))
+			assert.Nil(err)
+			assert.Equal(body, got)
+		}
+	})
+
+	t.Run("queue round trips varint framed elements", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f, WithVarintFraming())
+
+		values := [][]byte{nBytes(1), nBytes(127), nBytes(128), nBytes(1000)}
+		for _, v := range values {
+			assert.Nil(q.Enqueue(v))
+		}
+		assert.Equal(headerLength+(1+1)+(1+127)+(2+128)+(2+1000), q.header.tailPosition)
+
+		q = NewQueue(f, WithVarintFraming())
+
+		for _, v := range values {
+			front, err := q.Dequeue()
+			assert.Nil(err)
+			assert.Equal(v, front)
+		}
+	})
+
+	t.Run("small elements take less space than the default framing", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f, WithVarintFraming())
+
+		count := 0
+		for q.Enqueue([]byte("x")) == nil {
+			count++
+		}
+
+		// default framing fits (4096-20)/5 single-byte elements
+		assert.Greater(count, int(q.header.fileLength-headerLength)/5)
+	})
+}
@@ -0,0 +1,39 @@
+package queue
+
+import (
+	"bytes"
+	"errors"
+)
+
+var ErrWriteVerifyFailed = errors.New("data read back does not match data written")
+
+// WithVerifyWrites reads back every element frame and header after it is
+// written and fails the operation with ErrWriteVerifyFailed if the bytes
+// differ, guarding against media that silently corrupt writes
+//
+// Verification doubles the I/O of every write, so it is off by default.
+// Payloads streamed with EnqueueReader are not verified.
+func WithVerifyWrites() Option {
+	return func(ls *Queue) {
+		ls.verifyWrites = true
+	}
+}
+
+// verifyWrite checks that the bytes at off match b when write
+// verification is enabled
+func (ls *Queue) verifyWrite(b []byte, off int64) error {
+	if !ls.verifyWrites {
+		return nil
+	}
+
+	written := make([]byte, len(b))
+	if err := ls.readAt(written, off); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(written, b) {
+		return ErrWriteVerifyFailed
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package queue
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// corruptingBuffer flips a bit in the first byte of writes for which
+// corrupt returns true
+type corruptingBuffer struct {
+	*MemBuffer
+	corrupt func(off int64) bool
+}
+
+func (b *corruptingBuffer) WriteAt(p []byte, off int64) (int, error) {
+	if b.corrupt != nil && b.corrupt(off) && len(p) > 0 {
+		p = append([]byte(nil), p...)
+		p[0] ^= 1
+	}
+	return b.MemBuffer.WriteAt(p, off)
+}
+
+func TestVerifyWrites(t *testing.T) {
+	assert := assert.New(t)
+
+	elements := func(off int64) bool { return off >= int64(headerLength) }
+	headers := func(off int64) bool { return off < int64(headerLength) }
+
+	t.Run("element", func(t *testing.T) {
+		f := &corruptingBuffer{MemBuffer: NewMemBuffer()}
+		q := NewQueue(f, WithVerifyWrites())
+		assert.Nil(q.Enqueue([]byte("a")))
+
+		f.corrupt = elements
+		err := q.Enqueue([]byte("b"))
+		assert.True(errors.Is(err, ErrWriteVerifyFailed))
+		var ioErr *IOError
+		assert.True(errors.As(err, &ioErr))
+		assert.Equal(OpElementWrite, ioErr.Op)
+
+		f.corrupt = nil
+		values, err := q.Elements()
+		assert.Nil(err)
+		assert.Equal([][]byte{[]byte("a")}, values)
+	})
+
+	t.Run("header", func(t *testing.T) {
+		f := &corruptingBuffer{MemBuffer: NewMemBuffer()}
+		q := NewQueue(f, WithVerifyWrites())
+		assert.Nil(q.Enqueue([]byte("a")))
+
+		f.corrupt = headers
+		err := q.Enqueue([]byte("b"))
+		assert.True(errors.Is(err, ErrWriteVerifyFailed))
+		var ioErr *IOError
+		assert.True(errors.As(err, &ioErr))
+		assert.Equal(OpHeaderSync, ioErr.Op)
+
+		// the previous header is still intact on disk
+		f.corrupt = nil
+		values, err := NewQueue(f).Elements()
+		assert.Nil(err)
+		assert.Equal([][]byte{[]byte("a")}, values)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		f := &corruptingBuffer{MemBuffer: NewMemBuffer(), corrupt: elements}
+		q := NewQueue(f)
+
+		assert.Nil(q.Enqueue([]byte("a")))
+		assert.Equal(1, q.Len())
+	})
+}
@@ -0,0 +1,111 @@
+package queue
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// WithZeroOnDequeue overwrites the bytes of each dequeued element with zeros
+// so that sensitive payloads do not linger in the backing file
+//
+// When a dequeue consumes the last element before the queue wraps back to
+// the front of the buffer, the unused gap at the end of the buffer is
+// zeroed as well.
+func WithZeroOnDequeue() Option {
+	return func(ls *Queue) {
+		ls.zeroOnDequeue = true
+	}
+}
+
+// zero overwrites the region [from, to) of Queue.rws with zeros
+func (ls *Queue) zero(from, to uint32) error {
+	if to <= from {
+		return nil
+	}
+	if err := ls.protect(from, to); err != nil {
+		return err
+	}
+
+	var zeros [512]byte
+	for pos := from; pos < to; {
+		n := to - pos
+		if n > uint32(len(zeros)) {
+			n = uint32(len(zeros))
+		}
+
+		if _, err := ls.writeAt(zeros[:n], int64(pos)); err != nil {
+			return ioError(OpElementWrite, int64(pos), err)
+		}
+		pos += n
+	}
+
+	return nil
+}
+
+// SecureReset removes every element and overwrites the file after the
+// header slots with zeros, so that no payload, user metadata, or cursor
+// position remains on disk
+//
+// The layout of the file, such as its capacity and options recorded in
+// the header, is kept, and sequence numbers continue where they left off.
+// Open cursors are closed and any spilled elements are deleted. The empty
+// header is written before the file is zeroed, so a crash part way through
+// leaves an empty queue that a second SecureReset finishes wiping.
+func (ls *Queue) SecureReset() error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if ls.readOnly {
+		return ErrReadOnly
+	}
+
+	var bodies [][]byte
+	if ls.spillDir != "" {
+		err := ls.walk(func(_, _ uint32, body []byte) bool {
+			bodies = append(bodies, body)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	original := ls.header
+	ls.header.removed += uint64(ls.header.queueSize)
+	ls.header.queueSize = 0
+	ls.header.headPosition = ls.dataStart()
+	ls.header.tailPosition = ls.dataStart()
+	ls.header.wrapPosition = 0
+	if err := ls.writeHeader(); err != nil {
+		ls.header = original
+		return err
+	}
+	ls.headMoves++
+	ls.cond.Broadcast()
+
+	for _, body := range bodies {
+		ls.removeSpilled(body)
+	}
+	for _, c := range ls.cursors {
+		if c != nil {
+			c.closed = true
+		}
+	}
+	ls.cursors = nil
+
+	// bytes past the end of a file that has not grown to its capacity
+	// hold nothing to wipe
+	end, err := ls.rws.Seek(0, io.SeekEnd)
+	if err != nil {
+		return &IOError{Op: OpSeek, Offset: 0, Err: err}
+	}
+	atomic.AddUint64(&ls.stats.seeks, 1)
+	if end > int64(ls.header.fileLength) {
+		end = int64(ls.header.fileLength)
+	}
+
+	if err := ls.zero(headerLength, uint32(end)); err != nil {
+		return err
+	}
+	return ls.loadConsumer()
+}
@@ -0,0 +1,121 @@
+package queue
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZeroOnDequeue(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("dequeued element is zeroed", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f, WithZeroOnDequeue())
+
+		secret := []byte("hunter2")
+		assert.Nil(q.Enqueue(secret))
+
+		front, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Equal(secret, front)
+
+		raw := make([]byte, 4+len(secret))
+		_, err = f.ReadAt(raw, int64(headerLength))
+		assert.Nil(err)
+		assert.Equal(make([]byte, len(raw)), raw)
+	})
+
+	t.Run("freed region straddling the buffer end is zeroed", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f, WithZeroOnDequeue())
+
+		first := bytes.Repeat([]byte{'a'}, 1500)
+		secret := bytes.Repeat([]byte{'s'}, 1500)
+		last := bytes.Repeat([]byte{'z'}, 1500)
+
+		assert.Nil(q.Enqueue(first))
+		assert.Nil(q.Enqueue(secret))
+		_, err = q.Dequeue()
+		assert.Nil(err)
+
+		// the third element only fits at the front of the buffer
+		assert.Nil(q.Enqueue(last))
+		assert.True(q.isWrapped())
+		secretStart := q.header.headPosition
+
+		front, err := q.Dequeue()
+		assert.Nil(err)
+		assert.Equal(secret, front)
+		assert.False(q.isWrapped())
+
+		raw := make([]byte, q.header.fileLength-secretStart)
+		_, err = f.ReadAt(raw, int64(secretStart))
+		assert.Nil(err)
+		assert.Equal(make([]byte, len(raw)), raw)
+
+		front, err = q.Dequeue()
+		assert.Nil(err)
+		assert.Equal(last, front)
+	})
+
+	t.Run("dequeued bytes remain without the option", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "test-*")
+		assert.Nil(err)
+
+		q := NewQueue(f)
+
+		secret := []byte("hunter2")
+		assert.Nil(q.Enqueue(secret))
+		_, err = q.Dequeue()
+		assert.Nil(err)
+
+		raw := make([]byte, len(secret))
+		_, err = f.ReadAt(raw, int64(headerLength+4))
+		assert.Nil(err)
+		assert.Equal(secret, raw)
+	})
+}
+
+func TestSecureReset(t *testing.T) {
+	assert := assert.New(t)
+
+	f := NewMemBuffer()
+	q := NewQueue(f, WithUserHeader(16), WithCursors(1), WithSequenceNumbers())
+	assert.Nil(q.WriteUserHeader([]byte("metadata")))
+	c, err := q.NewCursor()
+	assert.Nil(err)
+
+	secret := []byte("hunter2")
+	for i := 0; i < 3; i++ {
+		assert.Nil(q.Enqueue(secret))
+	}
+	_, err = q.Dequeue()
+	assert.Nil(err)
+
+	assert.Nil(q.SecureReset())
+	assert.Equal(0, q.Len())
+
+	raw, err := q.RawBytes()
+	assert.Nil(err)
+	assert.Equal(make([]byte, len(raw)-int(headerLength)), raw[headerLength:])
+	assert.False(bytes.Contains(f.Bytes(), secret))
+
+	_, err = c.Next()
+	assert.Equal(ErrCursorClosed, err)
+
+	// the queue is usable afterwards and keeps its numbering
+	q = NewQueue(f)
+	assert.Equal(0, q.Len())
+	assert.Nil(q.Enqueue([]byte("after")))
+	v, seq, err := q.DequeueWithSeq()
+	assert.Nil(err)
+	assert.Equal([]byte("after"), v)
+	assert.Equal(uint64(4), seq)
+}